@@ -0,0 +1,32 @@
+// Package medisynth embeds the portal's templates and static files so the
+// binaries run without the repository laid out on disk.
+package medisynth
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed templates/portal
+var templates embed.FS
+
+//go:embed static
+var static embed.FS
+
+// Templates returns the portal page templates, rooted at templates/portal
+func Templates() fs.FS {
+	sub, err := fs.Sub(templates, "templates/portal")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// Static returns the portal's static files, rooted at static
+func Static() fs.FS {
+	sub, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
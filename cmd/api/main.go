@@ -7,6 +7,7 @@ import (
 	"github.com/MediSynth-io/medisynth/internal/auth"
 	"github.com/MediSynth-io/medisynth/internal/config"
 	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/storage"
 	"github.com/MediSynth-io/medisynth/internal/store"
 )
 
@@ -20,7 +21,7 @@ func initializeAPI() (*api.Api, error) {
 	}
 
 	// Initialize database
-	if err := database.Init(cfg); err != nil {
+	if _, err := database.Init(cfg); err != nil {
 		return nil, err
 	}
 
@@ -29,9 +30,17 @@ func initializeAPI() (*api.Api, error) {
 
 	// Initialize auth with store
 	auth.SetStore(dataStore)
+	auth.SetPasswordCost(cfg.PasswordHashCost())
+	log.Printf("Hashing new passwords with bcrypt cost %d", cfg.PasswordHashCost())
+
+	// Initialize output storage
+	backend, err := storage.New(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	// Initialize API
-	api, err := api.NewApi(*cfg)
+	api, err := api.NewApi(*cfg, backend)
 	if err != nil {
 		return nil, err
 	}
@@ -24,7 +24,7 @@ func initializePortal() (http.Handler, error) {
 	}
 
 	// Initialize database
-	if err := database.Init(cfg); err != nil {
+	if _, err := database.Init(cfg); err != nil {
 		return nil, err
 	}
 
@@ -33,6 +33,8 @@ func initializePortal() (http.Handler, error) {
 
 	// Initialize auth with store
 	auth.SetStore(dataStore)
+	auth.SetPasswordCost(cfg.PasswordHashCost())
+	log.Printf("Hashing new passwords with bcrypt cost %d", cfg.PasswordHashCost())
 
 	// Initialize portal
 	portal, err := portal.New(cfg)
@@ -33,20 +33,17 @@ func main() {
 		log.Printf("Directory %s exists, mode: %v", dbDir, stat.Mode())
 	}
 
-	// Try to initialize database
-	if err := database.Init(cfg); err != nil {
+	// Try to open the database
+	db, err := database.Open(cfg)
+	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
+	defer db.Close()
 
 	log.Printf("Database initialization successful!")
 
 	// Test database connection
-	db := database.GetConnection()
-	if db == nil {
-		log.Fatalf("Database connection is nil")
-	}
-
-	if err := db.Ping(); err != nil {
+	if err := db.Conn().Ping(); err != nil {
 		log.Fatalf("Failed to ping database: %v", err)
 	}
 
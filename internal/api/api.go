@@ -1,69 +1,90 @@
+// Package api serves the MediSynth REST API. Generation jobs are
+// models.Job records kept in the database: handlers create and read them
+// through the database package and a worker pool runs each one with
+// executeSyntheaJob. There is no separate in-memory job store, so tests drive
+// the same handlers and runner the router uses, with a fake CommandRunner in
+// place of Synthea.
 package api
 
 import (
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 
 	"encoding/json"
 	"strings"
 	"time"
 
-	"bytes"
 	"context"
+	"errors"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
 	"sync"
 
 	"github.com/MediSynth-io/medisynth/internal/auth"
+	"github.com/MediSynth-io/medisynth/internal/compress"
 	"github.com/MediSynth-io/medisynth/internal/config"
 	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/estimate"
 	"github.com/MediSynth-io/medisynth/internal/models"
-	"github.com/MediSynth-io/medisynth/internal/s3"
-	awsSDKs3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/MediSynth-io/medisynth/internal/quota"
+	"github.com/MediSynth-io/medisynth/internal/storage"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 )
 
 type Api struct {
-	Config   config.Config
-	Router   *chi.Mux
-	S3Client *s3.Client
+	Config  config.Config
+	Router  *chi.Mux
+	Storage storage.Backend
+	Runner  CommandRunner // Runs Synthea; nil runs it as a child process
+	// DB holds the jobs, users and presets the API reads and writes; nil uses
+	// the database set up by database.Init. Token and session checks go
+	// through the auth package, which always uses that default.
+	DB *database.DB
+
+	jobQueue  chan *models.Job
+	jobRunner func(job *models.Job)
 }
 
-func NewApi(cfg config.Config) (*Api, error) {
-	s3Client, err := s3.NewClient(&cfg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create S3 client: %w", err)
-	}
-
+// NewApi returns an API that keeps job outputs in backend
+func NewApi(cfg config.Config, backend storage.Backend) (*Api, error) {
 	api := &Api{
-		Config:   cfg,
-		Router:   chi.NewRouter(),
-		S3Client: s3Client,
+		Config:  cfg,
+		Router:  chi.NewRouter(),
+		Storage: backend,
 	}
 	api.setupRoutes()
+	api.startJobWorkers()
 	return api, nil
 }
 
+// database returns DB, or the database set up by database.Init when none is set
+func (api *Api) database() *database.DB {
+	if api.DB == nil {
+		return database.Default()
+	}
+	return api.DB
+}
+
 func (api *Api) setupRoutes() {
 	r := api.Router
 
 	// Enhanced middleware with real IP logging
 	r.Use(middleware.RealIP)
 	r.Use(middleware.RequestID)
+	r.Use(echoRequestID)
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 
 			defer func() {
-				clientIP := middleware.GetReqID(r.Context())
-				if clientIP == "" {
-					clientIP = r.RemoteAddr
-				}
+				clientIP := r.RemoteAddr
 				// Get real client IP from headers
 				if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
 					clientIP = realIP
@@ -71,23 +92,27 @@ func (api *Api) setupRoutes() {
 					clientIP = strings.Split(forwarded, ",")[0]
 				}
 
-				log.Printf("[API] %s %s %s %d %d bytes in %v - Real IP: %s",
+				log.Printf("[API] %s %s %s %d %d bytes in %v - Real IP: %s - Request ID: %s",
 					r.Method, r.URL.Path, r.Proto, ww.Status(), ww.BytesWritten(),
-					time.Since(start), clientIP)
+					time.Since(start), clientIP, middleware.GetReqID(r.Context()))
 			}()
 
 			next.ServeHTTP(ww, r)
 		})
 	})
+	// Inside the logger so it records the bytes actually sent
+	r.Use(compress.Gzip(compress.DefaultMinSize))
 	r.Use(middleware.Recoverer)
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"http://*.local:*", "http://localhost:*", "http://127.0.0.1:*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedOrigins:   api.Config.AllowedCORSOrigins(),
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", middleware.RequestIDHeader, headerJobStatus, headerJobFilesComplete, headerJobOutputExpiresAt},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
+	// After CORS so browsers can read the 413
+	r.Use(api.limitRequestBody)
 
 	// Public root endpoint (limited info)
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
@@ -104,12 +129,19 @@ func (api *Api) setupRoutes() {
 
 	// Public routes
 	r.Get("/heartbeat", api.Heartbeat)
+	r.Get("/readyz", api.Readyz)
 	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("pong"))
 	})
 	r.Post("/register", api.RegisterHandler)
 	r.Post("/login", api.LoginHandler)
+	r.Get("/openapi.json", api.OpenAPIHandler)
+
+	// Download links of filesystem storage carry their own signature
+	if files, ok := api.Storage.(http.Handler); ok {
+		r.Mount(storage.FilesystemURLPath, http.StripPrefix(storage.FilesystemURLPath, files))
+	}
 
 	// Protected API routes
 	r.Group(func(r chi.Router) {
@@ -125,34 +157,73 @@ func (api *Api) setupRoutes() {
 				"status":  "running",
 				"endpoints": map[string]string{
 					"health":   "/heartbeat",
+					"ready":    "/readyz",
 					"docs":     "/docs",
 					"swagger":  "/swagger/",
+					"openapi":  "/openapi.json",
 					"generate": "/generate-patients",
+					"validate": "/generate-patients/validate",
+					"modules":  "/modules",
 					"status":   "/generation-status/{jobID}",
 					"jobs":     "/jobs",
+					"logs":     "/jobs/{jobID}/logs",
+					"events":   "/jobs/{jobID}/events",
+					"presets":  "/presets",
 					"tokens":   "/tokens",
+					"me":       "/me",
 				},
 				"documentation": "Access /swagger/ for interactive API documentation",
 			})
 		})
 
 		// Swagger UI (private)
-		r.Handle("/swagger/*", http.StripPrefix("/swagger/", http.FileServer(http.Dir("./swagger-ui"))))
+		r.Get("/swagger/", api.SwaggerUIHandler)
+		r.Get("/swagger/openapi.json", api.OpenAPIHandler)
+
+		// Authenticated user's profile
+		r.Get("/me", api.MeHandler)
 
 		// Token management
 		r.Post("/tokens", api.CreateTokenHandler)
 		r.Get("/tokens", api.ListTokensHandler)
+		r.Delete("/tokens", api.DeleteAllTokensHandler)
+		r.Post("/tokens/bulk-delete", api.BulkDeleteTokensHandler)
 		r.Delete("/tokens/{tokenID}", api.DeleteTokenHandler)
 
 		// Job-related routes
 		r.Post("/generate-patients", api.RunSyntheaGeneration)
+		r.Post("/generate-patients/validate", api.ValidateGenerationParams)
+		r.Get("/modules", api.ModulesHandler)
 		r.Get("/generation-status/{jobID}", api.GetGenerationStatus)
 		r.Get("/jobs", api.ListJobsHandler)
+		r.Patch("/jobs/{jobID}", api.UpdateJobHandler)
+		r.Delete("/jobs/{jobID}", api.DeleteJobHandler)
 		r.Get("/jobs/{jobID}/files", api.ListJobFilesHandler)
+		r.Get("/jobs/{jobID}/files/{fileName}/preview", api.PreviewJobFileHandler)
+		r.Get("/jobs/{jobID}/logs", api.GetJobLogsHandler)
+		r.Get("/jobs/{jobID}/events", api.JobEventsHandler)
+
+		// Saved generation parameter presets
+		r.Get("/presets", api.ListPresetsHandler)
+		r.Post("/presets", api.CreatePresetHandler)
+		r.Get("/presets/{presetID}", api.GetPresetHandler)
+		r.Put("/presets/{presetID}", api.UpdatePresetHandler)
+		r.Delete("/presets/{presetID}", api.DeletePresetHandler)
 	})
 }
 
 func (api *Api) Serve() {
+	if err := api.checkSyntheaCommand(); err != nil {
+		log.Printf("ERROR: Synthea cannot be started, generation jobs will fail: %v", err)
+	}
+
+	// Resume or fail jobs left behind by the previous process
+	if err := api.recoverJobs(); err != nil {
+		log.Printf("Error recovering jobs after restart: %v", err)
+	}
+
+	api.startRetentionCleanup()
+
 	// Start session cleanup goroutine
 	go func() {
 		ticker := time.NewTicker(1 * time.Hour)
@@ -172,46 +243,46 @@ func (api *Api) Serve() {
 	log.Fatal(http.ListenAndServe(fmt.Sprintf("0.0.0.0:%d", api.Config.APIPort), api.Router))
 }
 
+// DomainMiddleware routes each request to the portal or the API by its Host
+// header. Precedence:
+//
+//  1. A host equal to DOMAIN_PORTAL goes to the portal.
+//  2. A host equal to DOMAIN_API goes to the API.
+//  3. localhost or a loopback IP is routed by port: API_PORT to the API,
+//     PORTAL_PORT to the portal.
+//
+// Anything else gets a 404 JSON error; next is never called.
 func DomainMiddleware(portalHandler, apiHandler http.Handler, config *config.Config) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Extract host and port
-			hostParts := strings.Split(r.Host, ":")
-			host := hostParts[0]
-			port := "80"
-			if len(hostParts) > 1 {
-				port = hostParts[1]
+			host, port, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				// No port in the Host header
+				host, port = r.Host, "80"
 			}
 
-			// Try exact domain matches first
-			if strings.HasPrefix(host, config.DomainPortal) {
+			if config.DomainPortal != "" && strings.EqualFold(host, config.DomainPortal) {
 				portalHandler.ServeHTTP(w, r)
 				return
 			}
-
-			if strings.HasPrefix(host, config.DomainAPI) {
+			if config.DomainAPI != "" && strings.EqualFold(host, config.DomainAPI) {
 				apiHandler.ServeHTTP(w, r)
 				return
 			}
 
-			// If no exact match, try localhost or IP
-			if host == "localhost" || host == "127.0.0.1" {
-				// Use the port to determine which service to route to
-				if port == fmt.Sprintf("%d", config.APIPort) {
+			if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+				switch port {
+				case strconv.Itoa(config.APIPort):
 					apiHandler.ServeHTTP(w, r)
 					return
-				}
-				if port == "8082" { // Portal port
+				case strconv.Itoa(config.PortalPort):
 					portalHandler.ServeHTTP(w, r)
 					return
 				}
 			}
 
-			// Log unmatched requests for debugging
 			log.Printf("Unmatched request - Host: %s, Port: %s, Path: %s", host, port, r.URL.Path)
-
-			// If no domain matches, proceed to the next handler
-			next.ServeHTTP(w, r)
+			writeJSONError(w, http.StatusNotFound, errCodeNotFound, "No service is configured for host "+r.Host)
 		})
 	}
 }
@@ -230,13 +301,35 @@ var runningJobsMutex sync.Mutex
 func (api *Api) RunSyntheaGeneration(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value("userID").(string)
 	if !ok || userID == "" {
-		http.Error(w, "Unauthorized: User ID not found in token", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
 		return
 	}
 
 	var params models.SyntheaParams
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		writeDecodeError(w, err, "Invalid JSON payload")
+		return
+	}
+
+	if err := params.Validate(api.Config.MaxPopulation); err != nil {
+		writeValidationFailed(w, err)
+		return
+	}
+
+	usage, err := quota.ForUser(r.Context(), api.database(), &api.Config, userID, time.Now())
+	if err != nil {
+		log.Printf("ERROR: Failed to check patient quota for user %s: %v", userID, err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to check usage quota")
+		return
+	}
+	if usage != nil && !usage.Allows(*params.Population) {
+		reserved := ""
+		if usage.Reserved > 0 {
+			reserved = fmt.Sprintf(", %d reserved by unfinished jobs", usage.Reserved)
+		}
+		writeJSONError(w, http.StatusTooManyRequests, errCodeQuotaExceeded, fmt.Sprintf(
+			"Monthly patient quota exceeded: %d of %d patients used%s, %d remaining, this job requests %d. The quota resets on %s.",
+			usage.Used, usage.Limit, reserved, usage.Remaining(), *params.Population, usage.ResetsAt.Format("January 2, 2006")))
 		return
 	}
 
@@ -247,28 +340,84 @@ func (api *Api) RunSyntheaGeneration(w http.ResponseWriter, r *http.Request) {
 		Status:       models.JobStatusPending,
 		Parameters:   params.ToMap(),
 		OutputFormat: params.GetOutputFormat(),
+		RequestID:    requestID(r),
 	}
 
 	if err := job.MarshalParameters(); err != nil {
-		http.Error(w, "Failed to process job parameters", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to process job parameters")
 		return
 	}
 
-	if err := database.CreateJob(job); err != nil {
+	if err := api.database().CreateJob(r.Context(), job); err != nil {
 		log.Printf("ERROR: Failed to create job in database: %v", err)
-		http.Error(w, "Failed to create job", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to create job")
+		return
+	}
+
+	if err := api.enqueueJob(job); err != nil {
+		log.Printf("ERROR: Failed to enqueue job %s: %v", job.ID, err)
+		errMsg := "job queue is full"
+		api.database().UpdateJobStatus(r.Context(), job.ID, models.JobStatusFailed, &errMsg, nil, nil, nil)
+		writeJSONError(w, http.StatusServiceUnavailable, errCodeUnavailable, "Too many jobs are queued, please try again later")
 		return
 	}
 
-	go api.executeSyntheaJob(job)
+	resp := map[string]interface{}{
+		"jobID":      job.ID,
+		"status":     job.Status,
+		"message":    "Job accepted and is pending execution.",
+		"statusUrl":  fmt.Sprintf("/generation-status/%s", job.ID),
+		"queueDepth": api.QueueDepth(),
+	}
+	// The exact expiry is only known on completion; tell the client the window up front
+	if api.Config.JobOutputRetentionDays > 0 {
+		resp["outputRetentionDays"] = api.Config.JobOutputRetentionDays
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ValidateGenerationParams checks generation parameters without creating a
+// job and, when they are valid, estimates the job's run time and output size
+func (api *Api) ValidateGenerationParams(w http.ResponseWriter, r *http.Request) {
+	var params models.SyntheaParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		writeDecodeError(w, err, "Invalid JSON payload")
+		return
+	}
+
+	if err := params.Validate(api.Config.MaxPopulation); err != nil {
+		writeValidationResult(w, err)
+		return
+	}
+
+	// ForParams falls back to the heuristic when the history can't be read
+	jobEstimate, err := estimate.ForParams(r.Context(), api.database(), &params)
+	if err != nil {
+		log.Printf("WARNING: Failed to estimate job duration from history: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"valid": true, "estimate": jobEstimate})
+}
+
+// writeValidationResult reports a failed dry-run validation as a 422 result
+// rather than an error, since the request itself succeeded
+func writeValidationResult(w http.ResponseWriter, err error) {
+	var fieldErrs models.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		writeJSONError(w, http.StatusBadRequest, errCodeValidationFailed, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"jobID":     job.ID,
-		"status":    job.Status,
-		"message":   "Job accepted and is pending execution.",
-		"statusUrl": fmt.Sprintf("/generation-status/%s", job.ID),
+		"valid":   false,
+		"message": fieldErrs.Error(),
+		"errors":  fieldErrs,
 	})
 }
 
@@ -285,15 +434,34 @@ func (api *Api) executeSyntheaJob(job *models.Job) {
 		cancel()
 	}()
 
+	// The output path is recorded as soon as the job starts so files that are
+	// already uploaded can be listed while Synthea is still running.
+	started := time.Now()
+	s3KeyPrefix := jobS3Prefix(job)
+	claimed, err := api.database().ClaimJob(ctx, job.ID, &s3KeyPrefix)
+	if err != nil {
+		// Left pending, the job would wait for the next restart; fail it so
+		// the user can resubmit
+		log.Printf("ERROR: Failed to claim job %s: %v", job.ID, err)
+		errMsg := "failed to start job"
+		if err := api.database().UpdateJobStatus(context.Background(), job.ID, models.JobStatusFailed, &errMsg, nil, nil, nil); err != nil {
+			log.Printf("ERROR: Failed to mark job %s failed: %v", job.ID, err)
+		}
+		return
+	}
+	if !claimed {
+		log.Printf("Skipping job %s: it is no longer pending", job.ID)
+		return
+	}
 	log.Printf("Starting Synthea generation for job %s", job.ID)
-	database.UpdateJobStatus(job.ID, models.JobStatusRunning, nil, nil, nil, nil)
+	go api.keepJobAlive(ctx, job.ID)
 
 	// --- Synthea Execution ---
 	outputDir, err := os.MkdirTemp("", "synthea-output-"+job.ID)
 	if err != nil {
 		log.Printf("ERROR: Failed to create temp dir for job %s: %v", job.ID, err)
 		errMsg := "failed to create temp dir"
-		database.UpdateJobStatus(job.ID, models.JobStatusFailed, &errMsg, nil, nil, nil)
+		api.database().UpdateJobStatus(context.Background(), job.ID, models.JobStatusFailed, &errMsg, nil, nil, nil)
 		return
 	}
 	defer os.RemoveAll(outputDir)
@@ -304,7 +472,7 @@ func (api *Api) executeSyntheaJob(job *models.Job) {
 	if err != nil {
 		log.Printf("ERROR: Failed to build Synthea args for job %s: %v", job.ID, err)
 		errMsg := "failed to build synthea args"
-		database.UpdateJobStatus(job.ID, models.JobStatusFailed, &errMsg, nil, nil, nil)
+		api.database().UpdateJobStatus(context.Background(), job.ID, models.JobStatusFailed, &errMsg, nil, nil, nil)
 		return
 	}
 
@@ -321,59 +489,184 @@ func (api *Api) executeSyntheaJob(job *models.Job) {
 	if syntheaArgs.City != "" {
 		cmdArgs = append(cmdArgs, "--city", syntheaArgs.City)
 	}
+	if syntheaArgs.Modules != "" {
+		cmdArgs = append(cmdArgs, "-m", syntheaArgs.Modules)
+	}
 
 	cmdArgs = append(cmdArgs, "--exporter.base_directory", outputDir)
+	cmdArgs = append(cmdArgs, exporterArgs(job.OutputFormat)...)
 
 	log.Printf("Running Synthea for job %s with args: %v", job.ID, cmdArgs)
 
-	cmd := exec.CommandContext(ctx, "synthea", cmdArgs...)
-	var out, errOut bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &errOut
+	program, programArgs := api.syntheaInvocation(cmdArgs)
+	// The full output is kept (capped) for the logs endpoint; the stderr
+	// tail is only used for the job's error message.
+	jobLog := newTailBuffer(maxJobLogSize)
+	errOut := newTailBuffer(4096)
+	patients := &patientCounter{}
+	stdout := io.MultiWriter(jobLog, patients)
+	stderr := io.MultiWriter(jobLog, errOut)
+
+	// Synthea writes each exporter's files to <base_directory>/<format>/, next to
+	// metadata and any other exporters that happen to be enabled. Only the
+	// requested format is published.
+	formatDir := filepath.Join(outputDir, job.OutputFormat)
+	formatPrefix := s3KeyPrefix + job.OutputFormat + "/"
+	allowed := api.Config.AllowedOutputExtensions(job.OutputFormat)
+
+	// Bytes uploaded while Synthea was running; only read after uploadsDone
+	var partialBytes int64
+
+	stopUploads := make(chan struct{})
+	uploadsDone := make(chan struct{})
+	go func() {
+		partialBytes = api.uploadWhileRunning(ctx, formatDir, formatPrefix, jobObjectMetadata(job), allowed, stopUploads)
+		close(uploadsDone)
+	}()
+
+	err = api.commandRunner().Run(ctx, program, programArgs, stdout, stderr)
+	close(stopUploads)
+	<-uploadsDone
+
+	if logErr := api.uploadJobLog(job, jobLog); logErr != nil {
+		log.Printf("WARNING: Failed to store Synthea log for job %s: %v", job.ID, logErr)
+	}
 
-	err = cmd.Run()
 	if err != nil {
 		errMsg := fmt.Sprintf("Synthea execution failed: %s", errOut.String())
 		log.Printf("ERROR: Job %s failed: %s", job.ID, errMsg)
-		log.Printf("Synthea stdout: %s", out.String())
-		database.UpdateJobStatus(job.ID, models.JobStatusFailed, &errMsg, nil, nil, nil)
+		api.database().UpdateJobStatus(context.Background(), job.ID, models.JobStatusFailed, &errMsg, nil, nil, nil)
 		return
 	}
 
 	log.Printf("Synthea execution successful for job %s.", job.ID)
 
+	api.publishJobOutput(ctx, job, formatDir, allowed, partialBytes, patients.Count())
+
+	// Only completed jobs are used for estimates, so a failed upload's
+	// duration is recorded but never read
+	if err := api.database().SetJobDuration(context.Background(), job.ID, time.Since(started)); err != nil {
+		log.Printf("WARNING: Failed to record duration of job %s: %v", job.ID, err)
+	}
+}
+
+// publishJobOutput uploads whatever output is still on disk and marks the job
+// completed with its total output size and generated patient count, or failed
+// if any upload could not be verified or Synthea wrote no output at all, which
+// usually means its arguments were wrong. uploadedBytes counts files already
+// uploaded while Synthea ran.
+func (api *Api) publishJobOutput(ctx context.Context, job *models.Job, formatDir string, allowed []string, uploadedBytes int64, patientCount int) {
+	s3KeyPrefix := jobS3Prefix(job)
+	formatPrefix := s3KeyPrefix + job.OutputFormat + "/"
+
 	// --- S3 Upload ---
-	s3KeyPrefix := fmt.Sprintf("synthea_output/%s/", job.JobID)
-	log.Printf("Uploading Synthea output for job %s to S3 path %s", job.ID, s3KeyPrefix)
+	// Most files were already uploaded while Synthea ran; this pass picks up
+	// whatever was written last.
+	log.Printf("Uploading remaining Synthea output for job %s to S3 path %s", job.ID, s3KeyPrefix)
 
-	err = api.uploadDirectoryToS3(ctx, outputDir, s3KeyPrefix)
+	finalBytes, err := api.uploadDirectoryToS3(ctx, formatDir, formatPrefix, jobObjectMetadata(job), allowed, 0)
 	if err != nil {
 		errMsg := fmt.Sprintf("S3 upload failed: %v", err)
 		log.Printf("ERROR: Job %s failed: %v", job.ID, errMsg)
-		database.UpdateJobStatus(job.ID, models.JobStatusFailed, &errMsg, nil, nil, nil)
+		api.database().UpdateJobStatus(context.Background(), job.ID, models.JobStatusFailed, &errMsg, nil, nil, nil)
 		return
 	}
+	outputSize := uploadedBytes + finalBytes
 
-	population, _ := job.Parameters["population"].(float64)
-	patientCount := int(population)
+	if outputSize == 0 {
+		errMsg := fmt.Sprintf("Synthea completed but produced no %s output; check the job parameters", job.OutputFormat)
+		log.Printf("ERROR: Job %s failed: no output files in %s", job.ID, formatDir)
+		api.database().UpdateJobStatus(context.Background(), job.ID, models.JobStatusFailed, &errMsg, nil, nil, nil)
+		return
+	}
 
-	err = database.UpdateJobStatus(job.ID, models.JobStatusCompleted, nil, &s3KeyPrefix, nil, &patientCount)
+	if patientCount == 0 {
+		log.Printf("WARNING: No generated patients found in Synthea output for job %s", job.ID)
+	}
+
+	err = api.database().UpdateJobStatus(context.Background(), job.ID, models.JobStatusCompleted, nil, &s3KeyPrefix, &outputSize, &patientCount)
 	if err != nil {
 		log.Printf("ERROR: Failed to update job %s to completed: %v", job.ID, err)
 		return
 	}
 
-	log.Printf("Job %s completed successfully", job.ID)
+	log.Printf("Job %s completed successfully (%d bytes of output)", job.ID, outputSize)
+}
+
+// exporterArgs returns the Synthea flags that make it export the requested
+// format. FHIR is Synthea's default exporter, so other formats switch it off.
+func exporterArgs(format string) []string {
+	switch format {
+	case "ccda", "csv":
+		return []string{
+			"--exporter.fhir.export", "false",
+			fmt.Sprintf("--exporter.%s.export", format), "true",
+		}
+	default:
+		return nil
+	}
 }
 
-func (api *Api) uploadDirectoryToS3(ctx context.Context, dir, s3KeyPrefix string) error {
-	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+// Incremental uploads while Synthea is running: every partialUploadInterval the
+// output directory is scanned and files untouched for partialUploadSettle are
+// uploaded, on the assumption that Synthea has finished writing them.
+const (
+	partialUploadInterval = 15 * time.Second
+	partialUploadSettle   = 5 * time.Second
+)
+
+// uploadWhileRunning periodically uploads finished output files until stop is
+// closed and returns the number of bytes uploaded. Failures are only logged;
+// the final upload pass retries anything left.
+func (api *Api) uploadWhileRunning(ctx context.Context, dir, s3KeyPrefix string, metadata map[string]string, allowedExts []string, stop <-chan struct{}) int64 {
+	ticker := time.NewTicker(partialUploadInterval)
+	defer ticker.Stop()
+
+	var uploaded int64
+	for {
+		select {
+		case <-stop:
+			return uploaded
+		case <-ctx.Done():
+			return uploaded
+		case <-ticker.C:
+			n, err := api.uploadDirectoryToS3(ctx, dir, s3KeyPrefix, metadata, allowedExts, partialUploadSettle)
+			uploaded += n
+			if err != nil {
+				log.Printf("WARNING: Partial upload of %s failed, will retry: %v", dir, err)
+			}
+		}
+	}
+}
+
+// uploadDirectoryToS3 uploads every file under dir whose extension is in
+// allowedExts. Anything else (Synthea internals, stray temp files) is skipped
+// so it never becomes reachable through a presigned URL. Files modified within
+// minAge are left for a later pass. Uploaded files are removed locally so
+// repeated passes don't upload them twice and disk usage stays low. Up to
+// MAX_CONCURRENT_UPLOADS files are uploaded at once; the first failure
+// cancels the rest. It returns the number of bytes uploaded, including when
+// another file fails.
+func (api *Api) uploadDirectoryToS3(ctx context.Context, dir, s3KeyPrefix string, metadata map[string]string, allowedExts []string, minAge time.Duration) (int64, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	var files []pendingUpload
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 		if info.IsDir() {
 			return nil
 		}
+		if !hasAllowedExtension(path, allowedExts) {
+			log.Printf("Skipping upload of %s: extension not in output allowlist", path)
+			return nil
+		}
+		if minAge > 0 && time.Since(info.ModTime()) < minAge {
+			return nil
+		}
 
 		relPath, err := filepath.Rel(dir, path)
 		if err != nil {
@@ -381,92 +674,260 @@ func (api *Api) uploadDirectoryToS3(ctx context.Context, dir, s3KeyPrefix string
 		}
 
 		s3Key := filepath.ToSlash(filepath.Join(s3KeyPrefix, relPath))
+		files = append(files, pendingUpload{path: path, s3Key: s3Key, size: info.Size(), metadata: metadata})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return api.uploadFiles(ctx, files)
+}
 
-		file, err := os.Open(path)
-		if err != nil {
-			return err
+// pendingUpload is a local output file and the key and metadata it is
+// uploaded with
+type pendingUpload struct {
+	path     string
+	s3Key    string
+	size     int64
+	metadata map[string]string
+}
+
+// uploadFiles uploads files with a pool of UploadConcurrency workers and
+// removes each one locally once it is stored. The first failure cancels the
+// uploads still in flight and stops new ones from starting.
+func (api *Api) uploadFiles(ctx context.Context, files []pendingUpload) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		uploaded int64
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
 		}
-		defer file.Close()
+	}
 
-		log.Printf("Uploading %s to s3://%s/%s", path, api.S3Client.BucketName, s3Key)
+	work := make(chan pendingUpload)
+	for i := 0; i < min(api.Config.UploadConcurrency(), len(files)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range work {
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := api.uploadVerifiedFile(ctx, file); err != nil {
+					fail(err)
+					continue
+				}
+				mu.Lock()
+				uploaded += file.size
+				mu.Unlock()
+				if err := os.Remove(file.path); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
 
-		_, err = api.S3Client.PutObject(ctx, &awsSDKs3.PutObjectInput{
-			Bucket: &api.S3Client.BucketName,
-			Key:    &s3Key,
-			Body:   file,
-		})
+feed:
+	for _, file := range files {
+		select {
+		case work <- file:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	return uploaded, firstErr
+}
+
+// errUploadSizeMismatch is returned when S3 stores a different number of bytes
+// than the local file holds
+var errUploadSizeMismatch = errors.New("uploaded object size does not match local file")
+
+// uploadVerifiedFile uploads upload.path to upload.s3Key and confirms the
+// stored size. A size mismatch is retried once before giving up.
+func (api *Api) uploadVerifiedFile(ctx context.Context, upload pendingUpload) error {
+	err := api.putFileObject(ctx, upload)
+	if errors.Is(err, errUploadSizeMismatch) {
+		log.Printf("WARNING: %v, retrying upload", err)
+		err = api.putFileObject(ctx, upload)
+	}
+	return err
+}
+
+// putFileObject uploads one file and checks the size of the stored object
+func (api *Api) putFileObject(ctx context.Context, upload pendingUpload) error {
+	file, err := os.Open(upload.path)
+	if err != nil {
 		return err
-	})
+	}
+	defer file.Close()
+
+	log.Printf("Uploading %s to %s", upload.path, upload.s3Key)
+	if err := api.Storage.Put(ctx, upload.s3Key, file, upload.size, storage.PutOptions{Metadata: upload.metadata}); err != nil {
+		return err
+	}
+
+	stored, err := api.Storage.Stat(ctx, upload.s3Key)
+	if err != nil {
+		return fmt.Errorf("failed to verify %s: %w", upload.s3Key, err)
+	}
+	if stored.Size != upload.size {
+		return fmt.Errorf("%w: %s has %d bytes, expected %d", errUploadSizeMismatch, upload.s3Key, stored.Size, upload.size)
+	}
+	return nil
+}
+
+// hasAllowedExtension reports whether path ends in one of the given extensions
+func hasAllowedExtension(path string, allowedExts []string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, allowed := range allowedExts {
+		if ext == strings.ToLower(allowed) {
+			return true
+		}
+	}
+	return false
 }
 
 func (api *Api) GetGenerationStatus(w http.ResponseWriter, r *http.Request) {
 	jobID := chi.URLParam(r, "jobID")
-	job, err := database.GetJobByID(jobID)
+	job, err := api.database().GetJobByID(r.Context(), jobID)
 	if err != nil {
-		http.Error(w, "Job not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Job not found")
 		return
 	}
 
 	userID, _ := r.Context().Value("userID").(string)
 	if job.UserID != userID {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+		writeJSONError(w, http.StatusForbidden, errCodeForbidden, "Forbidden")
 		return
 	}
 
+	// ?wait= long-polls until the job finishes instead of the client looping
+	wait, err := parseStatusWait(r.URL.Query().Get("wait"))
+	if err != nil {
+		writeValidationFailed(w, err)
+		return
+	}
+	job = api.waitForJob(r.Context(), job, wait)
+	if r.Context().Err() != nil {
+		// The client gave up waiting
+		return
+	}
+
+	job.SetOutputExpiry(api.Config.JobOutputRetention())
+	job.SetDuration()
+
+	// Pending jobs report how many jobs are waiting ahead of the workers
+	resp := struct {
+		*models.Job
+		QueueDepth *int `json:"queue_depth,omitempty"`
+	}{Job: job}
+	if job.Status == models.JobStatusPending {
+		depth := api.QueueDepth()
+		resp.QueueDepth = &depth
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(job)
+	json.NewEncoder(w).Encode(resp)
 }
 
 func (api *Api) ListJobsHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value("userID").(string)
 	if !ok || userID == "" {
-		http.Error(w, "Unauthorized: User ID not found in token", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
 		return
 	}
 
-	jobs, err := database.GetJobsByUserID(userID)
+	query := r.URL.Query()
+	filter, err := models.ParseJobFilter(query.Get("status"), query.Get("from"), query.Get("to"))
+	if err != nil {
+		writeValidationFailed(w, err)
+		return
+	}
+
+	jobs, err := api.database().GetJobsFiltered(r.Context(), userID, string(filter.Status), filter.From, filter.To)
 	if err != nil {
 		log.Printf("ERROR: Failed to get jobs for user %s: %v", userID, err)
-		http.Error(w, "Failed to retrieve job history", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to retrieve job history")
 		return
 	}
 
+	for _, job := range jobs {
+		job.SetOutputExpiry(api.Config.JobOutputRetention())
+		job.SetDuration()
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(jobs)
 }
 
+// Response headers of ListJobFilesHandler describing the job the files belong to
+const (
+	headerJobStatus          = "X-Job-Status"
+	headerJobFilesComplete   = "X-Job-Files-Complete"
+	headerJobOutputExpiresAt = "X-Job-Output-Expires-At"
+)
+
 func (api *Api) ListJobFilesHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value("userID").(string)
 	if !ok || userID == "" {
-		http.Error(w, "Unauthorized: User ID not found in token", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
 		return
 	}
 
 	jobID := chi.URLParam(r, "jobID")
-	job, err := database.GetJobByID(jobID)
+	job, err := api.database().GetJobByID(r.Context(), jobID)
 	if err != nil {
-		http.Error(w, "Job not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Job not found")
 		return
 	}
 
 	if job.UserID != userID {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+		writeJSONError(w, http.StatusForbidden, errCodeForbidden, "Forbidden")
 		return
 	}
 
 	if job.OutputPath == nil || *job.OutputPath == "" {
-		http.Error(w, "Job has no output path", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Job has no output path")
 		return
 	}
 
-	files, err := api.S3Client.ListFiles(r.Context(), *job.OutputPath)
+	files, err := storage.ListJobFiles(r.Context(), api.Storage, job.JobID)
 	if err != nil {
 		log.Printf("ERROR: Failed to list files for job %s: %v", jobID, err)
-		http.Error(w, "Failed to list job files", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to list job files")
 		return
 	}
 
+	job.SetOutputExpiry(api.Config.JobOutputRetention())
+	if files == nil {
+		files = []models.JobFile{}
+	}
+
+	// Files are uploaded while the job runs, so a listing is only complete
+	// once the job has reached a terminal state. The body stays a plain array
+	// of files; the job's state travels in headers.
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(headerJobStatus, string(job.Status))
+	w.Header().Set(headerJobFilesComplete, strconv.FormatBool(job.Status.IsTerminal()))
+	if job.OutputExpiresAt != nil {
+		w.Header().Set(headerJobOutputExpiresAt, job.OutputExpiresAt.UTC().Format(time.RFC3339))
+	}
 	json.NewEncoder(w).Encode(files)
 }
 
@@ -478,18 +939,30 @@ func (api *Api) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		Password string `json:"password"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		writeDecodeError(w, err, "Invalid request payload")
 		return
 	}
 
 	if req.Email == "" || req.Password == "" {
-		http.Error(w, "Email and password are required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, errCodeValidationFailed, "Email and password are required")
+		return
+	}
+	if !auth.ValidateEmail(req.Email) {
+		writeValidationFailed(w, models.ValidationErrors{"email": "must be a valid email address"})
+		return
+	}
+	if problems := auth.PasswordProblems(req.Password); len(problems) > 0 {
+		writeValidationFailed(w, models.ValidationErrors{"password": strings.Join(problems, ", ")})
 		return
 	}
 
 	user, err := auth.RegisterUser(req.Email, req.Password)
 	if err != nil {
-		http.Error(w, "Registration failed", http.StatusInternalServerError)
+		if errors.Is(err, auth.ErrEmailAlreadyExists) {
+			writeJSONError(w, http.StatusConflict, errCodeConflict, "This email is already registered")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Registration failed")
 		return
 	}
 
@@ -509,13 +982,13 @@ func (api *Api) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		Password string `json:"password"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		writeDecodeError(w, err, "Invalid request payload")
 		return
 	}
 
 	user, err := auth.ValidateUser(req.Email, req.Password)
 	if err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Invalid credentials")
 		return
 	}
 
@@ -536,7 +1009,7 @@ func (api *Api) LoginHandler(w http.ResponseWriter, r *http.Request) {
 func (api *Api) CreateTokenHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value("userID").(string)
 	if !ok || userID == "" {
-		http.Error(w, "Unauthorized: User ID not found in token", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
 		return
 	}
 
@@ -544,18 +1017,18 @@ func (api *Api) CreateTokenHandler(w http.ResponseWriter, r *http.Request) {
 		Name string `json:"name"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		writeDecodeError(w, err, "Invalid request payload")
 		return
 	}
 
 	if req.Name == "" {
-		http.Error(w, "Token name is required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, errCodeValidationFailed, "Token name is required")
 		return
 	}
 
 	token, err := auth.CreateToken(userID, req.Name)
 	if err != nil {
-		http.Error(w, "Failed to create token", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to create token")
 		return
 	}
 
@@ -567,13 +1040,13 @@ func (api *Api) CreateTokenHandler(w http.ResponseWriter, r *http.Request) {
 func (api *Api) ListTokensHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value("userID").(string)
 	if !ok || userID == "" {
-		http.Error(w, "Unauthorized: User ID not found in token", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
 		return
 	}
 
 	tokens, err := auth.ListTokens(userID)
 	if err != nil {
-		http.Error(w, "Failed to list tokens", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to list tokens")
 		return
 	}
 
@@ -584,7 +1057,7 @@ func (api *Api) ListTokensHandler(w http.ResponseWriter, r *http.Request) {
 func (api *Api) DeleteTokenHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value("userID").(string)
 	if !ok || userID == "" {
-		http.Error(w, "Unauthorized: User ID not found in token", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
 		return
 	}
 	tokenID := chi.URLParam(r, "tokenID")
@@ -592,43 +1065,101 @@ func (api *Api) DeleteTokenHandler(w http.ResponseWriter, r *http.Request) {
 	// Validate that the user owns the token before deleting
 	token, err := auth.ValidateToken(tokenID)
 	if err != nil {
-		http.Error(w, "Token not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Token not found")
 		return
 	}
 
 	if token.UserID != userID {
-		http.Error(w, "Forbidden: You can only delete your own tokens", http.StatusForbidden)
+		writeJSONError(w, http.StatusForbidden, errCodeForbidden, "Forbidden: You can only delete your own tokens")
 		return
 	}
 
 	if err := auth.DeleteToken(userID, tokenID); err != nil {
-		http.Error(w, "Failed to delete token", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to delete token")
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// maxBulkTokenIDs caps how many tokens one bulk-delete request may name
+const maxBulkTokenIDs = 100
+
+// DeleteAllTokensHandler revokes every API token of the authenticated user,
+// including the one the request was made with
+func (api *Api) DeleteAllTokensHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
+		return
+	}
+
+	deleted, err := auth.DeleteUserTokens(userID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to delete tokens")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"deleted": deleted})
+}
+
+// BulkDeleteTokensHandler revokes the listed API tokens. IDs that do not
+// belong to the authenticated user are skipped, so "deleted" may be lower
+// than the number of IDs sent.
+func (api *Api) BulkDeleteTokensHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
+		return
+	}
+
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err, "Invalid request payload")
+		return
+	}
+	if len(req.IDs) == 0 {
+		writeValidationFailed(w, models.ValidationErrors{"ids": "at least one token ID is required"})
+		return
+	}
+	if len(req.IDs) > maxBulkTokenIDs {
+		writeValidationFailed(w, models.ValidationErrors{"ids": fmt.Sprintf("at most %d token IDs may be deleted at once", maxBulkTokenIDs)})
+		return
+	}
+
+	deleted, err := auth.DeleteTokensByIDs(userID, req.IDs)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to delete tokens")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"deleted": deleted})
+}
+
 // --- Middleware ---
 
 func (api *Api) UnifiedAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
+			writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Authorization header required")
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			http.Error(w, "Authorization header format must be Bearer {token}", http.StatusUnauthorized)
+			writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Authorization header format must be Bearer {token}")
 			return
 		}
 
 		tokenStr := parts[1]
 		token, err := auth.ValidateToken(tokenStr)
 		if err != nil {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			writeJSONError(w, http.StatusUnauthorized, errCodeInvalidToken, "Invalid token")
 			return
 		}
 
@@ -12,20 +12,20 @@ func (api *Api) TokenAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
+			writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Authorization header required")
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			http.Error(w, "Authorization header format must be Bearer {token}", http.StatusUnauthorized)
+			writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Authorization header format must be Bearer {token}")
 			return
 		}
 
 		tokenString := parts[1]
 		token, err := auth.ValidateToken(tokenString)
 		if err != nil {
-			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+			writeJSONError(w, http.StatusUnauthorized, errCodeInvalidToken, "Invalid or expired token")
 			return
 		}
 
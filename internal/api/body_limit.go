@@ -0,0 +1,21 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// limitRequestBody caps request bodies at MAX_REQUEST_BODY_BYTES. Bodies that
+// declare a larger Content-Length are refused with 413 straight away; others
+// are cut off while being read, which writeDecodeError reports as 413.
+func (api *Api) limitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := api.Config.MaxRequestBody()
+		if r.ContentLength > limit {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, errCodePayloadTooLarge, fmt.Sprintf("Request body must not exceed %d bytes", limit))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
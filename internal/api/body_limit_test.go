@@ -0,0 +1,71 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitRequestBody(t *testing.T) {
+	initTestDatabase(t)
+	api := &Api{Config: config.Config{MaxRequestBodyBytes: 64, MaxPopulation: 100}}
+	handler := api.limitRequestBody(http.HandlerFunc(api.ValidateGenerationParams))
+
+	post := func(body io.Reader, contentLength int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/generate-patients/validate", body)
+		req.ContentLength = contentLength
+		req = req.WithContext(context.WithValue(req.Context(), "userID", "body-limit-user"))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	assertTooLarge := func(rec *httptest.ResponseRecorder) {
+		t.Helper()
+		require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		var body errorBody
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, errCodePayloadTooLarge, body.Error.Code)
+		assert.Contains(t, body.Error.Message, "64 bytes")
+	}
+
+	small := `{"population": 10}`
+	rec := post(strings.NewReader(small), int64(len(small)))
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	oversized := `{"population": 10, "city": "` + strings.Repeat("x", 200) + `"}`
+	assertTooLarge(post(strings.NewReader(oversized), int64(len(oversized))))
+
+	// Chunked uploads declare no length and are cut off while decoding
+	assertTooLarge(post(io.MultiReader(bytes.NewBufferString(oversized)), -1))
+
+	// Malformed bodies under the limit are still a 400
+	rec = post(strings.NewReader("{"), 1)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestRequestBodyLimitKeepsCORSHeaders(t *testing.T) {
+	api, err := NewApi(config.Config{APIPort: 8081, MaxRequestBodyBytes: 64}, newTestBackend(t))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/generate-patients", strings.NewReader(strings.Repeat("x", 200)))
+	req.Header.Set("Origin", "http://localhost:3000")
+	rec := httptest.NewRecorder()
+	api.Router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Equal(t, "http://localhost:3000", rec.Header().Get("Access-Control-Allow-Origin"), "browsers can read the 413")
+}
+
+func TestMaxRequestBodyDefault(t *testing.T) {
+	assert.Equal(t, int64(1<<20), (&config.Config{}).MaxRequestBody())
+	assert.Equal(t, int64(512), (&config.Config{MaxRequestBodyBytes: 512}).MaxRequestBody())
+}
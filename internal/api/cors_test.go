@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORSAllowedOrigins(t *testing.T) {
+	api, err := NewApi(config.Config{
+		APIPort:            8081,
+		CORSAllowedOrigins: "https://portal.medisynth.io, https://*.medisynth.io",
+	}, newTestBackend(t))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://portal.medisynth.io", true},
+		{"https://sdk.medisynth.io", true},
+		{"https://evil.example.com", false},
+		{"http://localhost:3000", false}, // defaults are replaced once origins are configured
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/heartbeat", nil)
+			req.Header.Set("Origin", tt.origin)
+			rec := httptest.NewRecorder()
+			api.Router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			if tt.allowed {
+				assert.Equal(t, tt.origin, rec.Header().Get("Access-Control-Allow-Origin"))
+			} else {
+				assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+			}
+		})
+	}
+}
+
+func TestCORSDefaultsToLocalOrigins(t *testing.T) {
+	api, err := NewApi(config.Config{APIPort: 8081}, newTestBackend(t))
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/heartbeat", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	rec := httptest.NewRecorder()
+	api.Router.ServeHTTP(rec, req)
+
+	assert.Equal(t, "http://localhost:3000", rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSPreflightAllowsJobUpdates(t *testing.T) {
+	api, err := NewApi(config.Config{APIPort: 8081}, newTestBackend(t))
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodOptions, "/jobs/job-1", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPatch)
+	req.Header.Set("Access-Control-Request-Headers", "Authorization, Content-Type")
+	rec := httptest.NewRecorder()
+	api.Router.ServeHTTP(rec, req)
+
+	assert.Equal(t, "http://localhost:3000", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, rec.Header().Get("Access-Control-Allow-Methods"), http.MethodPatch, "PATCH /jobs/{jobID} passes preflight")
+}
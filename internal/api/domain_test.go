@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDomainMiddleware(t *testing.T) {
+	cfg := &config.Config{
+		APIPort:      8081,
+		PortalPort:   8082,
+		DomainPortal: "portal.medisynth.io",
+		DomainAPI:    "api.medisynth.io",
+	}
+	named := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		})
+	}
+	handler := DomainMiddleware(named("portal"), named("api"), cfg)(named("next"))
+
+	tests := []struct {
+		name string
+		host string
+		want string // empty means a 404
+	}{
+		{"portal domain", "portal.medisynth.io", "portal"},
+		{"portal domain with port", "portal.medisynth.io:443", "portal"},
+		{"portal domain is case-insensitive", "Portal.MediSynth.io", "portal"},
+		{"api domain", "api.medisynth.io", "api"},
+		{"api domain with port", "api.medisynth.io:8443", "api"},
+		{"localhost api port", "localhost:8081", "api"},
+		{"localhost portal port", "localhost:8082", "portal"},
+		{"loopback ip portal port", "127.0.0.1:8082", "portal"},
+		{"ipv6 loopback api port", "[::1]:8081", "api"},
+		{"localhost unknown port", "localhost:9999", ""},
+		{"localhost without port", "localhost", ""},
+		{"domain prefix is not a match", "api.medisynth.io.example.com", ""},
+		{"unknown host", "example.com", ""},
+		{"non-loopback ip", "10.0.0.5:8081", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if tt.want != "" {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, tt.want, rec.Body.String())
+				return
+			}
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			var body errorBody
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, errCodeNotFound, body.Error.Code)
+		})
+	}
+}
+
+func TestDomainMiddlewareIgnoresEmptyDomains(t *testing.T) {
+	cfg := &config.Config{APIPort: 8081, PortalPort: 8082}
+	portal := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("an empty DOMAIN_PORTAL must not match every host")
+	})
+	handler := DomainMiddleware(portal, http.NotFoundHandler(), cfg)(nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "example.com"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
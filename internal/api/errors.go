@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Stable machine-readable error codes returned in the "code" field of error responses
+const (
+	errCodeUnauthorized     = "unauthorized"
+	errCodeInvalidToken     = "invalid_token"
+	errCodeForbidden        = "forbidden"
+	errCodeValidationFailed = "validation_failed"
+	errCodeNotFound         = "not_found"
+	errCodeConflict         = "conflict"
+	errCodePayloadTooLarge  = "payload_too_large"
+	errCodeRateLimited      = "rate_limited"
+	errCodeQuotaExceeded    = "quota_exceeded"
+	errCodeUnavailable      = "unavailable"
+	errCodeInternal         = "internal"
+)
+
+// errorBody is the JSON shape of every API error response
+type errorBody struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+
+	// RequestID matches the X-Request-Id response header and the access log
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// writeJSONError writes {"error":{"code":...,"message":...}} with the given status
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	writeErrorBody(w, status, errorDetail{Code: code, Message: message})
+}
+
+func writeErrorBody(w http.ResponseWriter, status int, detail errorDetail) {
+	detail.RequestID = w.Header().Get(middleware.RequestIDHeader)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorBody{Error: detail})
+}
+
+// writeDecodeError reports a request body that could not be decoded: 413 when
+// it went over the size limit, otherwise 400 with message
+func writeDecodeError(w http.ResponseWriter, err error, message string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, errCodePayloadTooLarge, fmt.Sprintf("Request body must not exceed %d bytes", tooLarge.Limit))
+		return
+	}
+	writeJSONError(w, http.StatusBadRequest, errCodeValidationFailed, message)
+}
+
+// writeValidationFailed writes a 400 validation_failed error, listing each
+// invalid field when err comes from SyntheaParams.Validate
+func writeValidationFailed(w http.ResponseWriter, err error) {
+	detail := errorDetail{Code: errCodeValidationFailed, Message: err.Error()}
+	var fieldErrs models.ValidationErrors
+	if errors.As(err, &fieldErrs) {
+		detail.Fields = fieldErrs
+	}
+	writeErrorBody(w, http.StatusBadRequest, detail)
+}
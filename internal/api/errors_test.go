@@ -0,0 +1,61 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeErrorBody(t *testing.T, rec *httptest.ResponseRecorder) errorBody {
+	t.Helper()
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var body errorBody
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return body
+}
+
+func TestUnauthorizedErrorShape(t *testing.T) {
+	api := &Api{}
+	handler := api.UnifiedAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler should not be reached without credentials")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/jobs", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	body := decodeErrorBody(t, rec)
+	assert.Equal(t, errCodeUnauthorized, body.Error.Code)
+	assert.NotEmpty(t, body.Error.Message)
+}
+
+func TestBadRequestErrorShape(t *testing.T) {
+	api := &Api{}
+
+	req := httptest.NewRequest("POST", "/generate-patients", bytes.NewBufferString(`{"population":`))
+	req = req.WithContext(context.WithValue(req.Context(), "userID", "user-1"))
+	rec := httptest.NewRecorder()
+	api.RunSyntheaGeneration(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	body := decodeErrorBody(t, rec)
+	assert.Equal(t, errCodeValidationFailed, body.Error.Code)
+	assert.Equal(t, "Invalid JSON payload", body.Error.Message)
+
+	// Parameter validation failures also name the offending fields
+	req = httptest.NewRequest("POST", "/generate-patients", bytes.NewBufferString(`{"population":0,"gender":"X"}`))
+	req = req.WithContext(context.WithValue(req.Context(), "userID", "user-1"))
+	rec = httptest.NewRecorder()
+	api.RunSyntheaGeneration(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	body = decodeErrorBody(t, rec)
+	assert.Equal(t, errCodeValidationFailed, body.Error.Code)
+	assert.Contains(t, body.Error.Fields, "population")
+	assert.Contains(t, body.Error.Fields, "gender")
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DeleteJobHandler deletes a finished job together with its stored output.
+// The job record is only removed once its output is gone, so a failed
+// deletion can be retried without leaving orphaned objects behind.
+func (api *Api) DeleteJobHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
+		return
+	}
+
+	jobID := chi.URLParam(r, "jobID")
+	job, err := api.database().GetJobByID(r.Context(), jobID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Job not found")
+		return
+	}
+
+	if job.UserID != userID {
+		writeJSONError(w, http.StatusForbidden, errCodeForbidden, "Forbidden")
+		return
+	}
+
+	if !job.Status.IsTerminal() {
+		writeJSONError(w, http.StatusConflict, errCodeConflict, "Only completed or failed jobs can be deleted")
+		return
+	}
+
+	deleted, err := api.Storage.Delete(r.Context(), jobS3Prefix(job))
+	if err != nil {
+		log.Printf("ERROR: Failed to delete output of job %s after %d objects: %v", jobID, deleted, err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to delete job output, please try again")
+		return
+	}
+
+	if err := api.database().DeleteJob(r.Context(), userID, jobID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Job not found")
+			return
+		}
+		log.Printf("ERROR: Failed to delete job %s: %v", jobID, err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to delete job")
+		return
+	}
+
+	log.Printf("Deleted job %s and %d output objects", jobID, deleted)
+	w.WriteHeader(http.StatusNoContent)
+}
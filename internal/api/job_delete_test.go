@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/MediSynth-io/medisynth/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deleteFuncBackend is a storage backend whose Delete calls fn
+type deleteFuncBackend struct {
+	storage.Backend
+	fn func(ctx context.Context, prefix string) (int, error)
+}
+
+func (b *deleteFuncBackend) Delete(ctx context.Context, prefix string) (int, error) {
+	return b.fn(ctx, prefix)
+}
+
+func TestDeleteJobHandler(t *testing.T) {
+	initTestDatabase(t)
+
+	owner, err := database.CreateUser(fmt.Sprintf("delete-job-%d@example.com", time.Now().UnixNano()), "password")
+	require.NoError(t, err)
+	other, err := database.CreateUser(fmt.Sprintf("delete-job-other-%d@example.com", time.Now().UnixNano()), "password")
+	require.NoError(t, err)
+
+	newJob := func(status models.JobStatus) *models.Job {
+		job := &models.Job{ID: database.GenerateID(), UserID: owner.ID, JobID: "synthea-" + database.GenerateID(), Status: status, OutputFormat: "fhir"}
+		require.NoError(t, job.MarshalParameters())
+		require.NoError(t, database.CreateJob(job))
+		return job
+	}
+	jobExists := func(job *models.Job) bool {
+		_, err := database.GetJobByID(job.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false
+		}
+		require.NoError(t, err)
+		return true
+	}
+
+	// steps records the prefixes the handler asked to delete
+	var steps []string
+	var deleteErr error
+	api := &Api{Storage: &deleteFuncBackend{fn: func(ctx context.Context, prefix string) (int, error) {
+		steps = append(steps, "storage "+prefix)
+		return 2, deleteErr
+	}}}
+	del := func(userID, jobID string) *httptest.ResponseRecorder {
+		r := chi.NewRouter()
+		r.Delete("/jobs/{jobID}", api.DeleteJobHandler)
+		req := asUser(httptest.NewRequest(http.MethodDelete, "/jobs/"+jobID, nil), userID)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("another user's job", func(t *testing.T) {
+		steps = nil
+		job := newJob(models.JobStatusCompleted)
+
+		rec := del(other.ID, job.ID)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Empty(t, steps, "no output is deleted")
+		assert.True(t, jobExists(job))
+	})
+
+	t.Run("unknown job", func(t *testing.T) {
+		rec := del(owner.ID, "missing")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("job still running", func(t *testing.T) {
+		steps = nil
+		job := newJob(models.JobStatusRunning)
+
+		rec := del(owner.ID, job.ID)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, errCodeConflict, decodeErrorBody(t, rec).Error.Code)
+		assert.Empty(t, steps)
+		assert.True(t, jobExists(job))
+	})
+
+	t.Run("output deletion fails", func(t *testing.T) {
+		steps = nil
+		deleteErr = errors.New("access denied")
+		defer func() { deleteErr = nil }()
+		job := newJob(models.JobStatusCompleted)
+
+		rec := del(owner.ID, job.ID)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, []string{"storage " + jobS3Prefix(job)}, steps)
+		assert.True(t, jobExists(job), "the record is kept so the deletion can be retried")
+	})
+
+	t.Run("deletes output then record", func(t *testing.T) {
+		steps = nil
+		job := newJob(models.JobStatusFailed)
+		api.Storage = &deleteFuncBackend{fn: func(ctx context.Context, prefix string) (int, error) {
+			steps = append(steps, "storage "+prefix)
+			assert.True(t, jobExists(job), "output is deleted before the record")
+			return 2, nil
+		}}
+
+		rec := del(owner.ID, job.ID)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, []string{"storage " + jobS3Prefix(job)}, steps)
+		assert.False(t, jobExists(job))
+	})
+}
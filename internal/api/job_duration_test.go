@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobResponsesReportDuration(t *testing.T) {
+	initTestDatabase(t)
+
+	user, err := database.CreateUser(fmt.Sprintf("duration-%d@example.com", time.Now().UnixNano()), "password")
+	require.NoError(t, err)
+	finished := &models.Job{ID: database.GenerateID(), UserID: user.ID, JobID: database.GenerateID(), Status: models.JobStatusPending, OutputFormat: "fhir", CreatedAt: time.Now()}
+	running := &models.Job{ID: database.GenerateID(), UserID: user.ID, JobID: database.GenerateID(), Status: models.JobStatusPending, OutputFormat: "fhir", CreatedAt: time.Now()}
+	for _, job := range []*models.Job{finished, running} {
+		require.NoError(t, job.MarshalParameters())
+		require.NoError(t, database.CreateJob(job))
+		require.NoError(t, database.UpdateJobStatus(job.ID, models.JobStatusRunning, nil, nil, nil, nil))
+	}
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, database.UpdateJobStatus(finished.ID, models.JobStatusCompleted, nil, nil, nil, nil))
+
+	api := &Api{}
+	r := chi.NewRouter()
+	r.Get("/generation-status/{jobID}", api.GetGenerationStatus)
+	r.Get("/jobs", api.ListJobsHandler)
+	get := func(target string, v interface{}) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req = req.WithContext(context.WithValue(req.Context(), "userID", user.ID))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), v))
+	}
+	assertDuration := func(job *models.Job) {
+		t.Helper()
+		require.NotNil(t, job.StartedAt)
+		require.NotNil(t, job.CompletedAt)
+		require.NotNil(t, job.DurationSeconds)
+		assert.InDelta(t, job.CompletedAt.Sub(*job.StartedAt).Seconds(), *job.DurationSeconds, 1e-6)
+		assert.GreaterOrEqual(t, *job.DurationSeconds, 0.05)
+	}
+
+	var status models.Job
+	get("/generation-status/"+finished.ID, &status)
+	assertDuration(&status)
+
+	var unfinished models.Job
+	get("/generation-status/"+running.ID, &unfinished)
+	assert.NotNil(t, unfinished.StartedAt)
+	assert.Nil(t, unfinished.CompletedAt, "running jobs have not completed")
+	assert.Nil(t, unfinished.DurationSeconds)
+
+	var jobs []*models.Job
+	get("/jobs", &jobs)
+	require.Len(t, jobs, 2)
+	for _, job := range jobs {
+		if job.ID == finished.ID {
+			assertDuration(job)
+		} else {
+			assert.Nil(t, job.DurationSeconds)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// jobEventHeartbeat is how often an idle event stream sends a comment, which
+// keeps proxies from closing it and lets the server notice a gone client
+var jobEventHeartbeat = 15 * time.Second
+
+// JobEventsHandler streams a job's status as server-sent events. A "status"
+// event carrying the job is sent on connect and on every status change; the
+// stream ends once the job completes or fails.
+func (api *Api) JobEventsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
+		return
+	}
+
+	jobID := chi.URLParam(r, "jobID")
+	job, err := api.database().GetJobByID(r.Context(), jobID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Job not found")
+		return
+	}
+
+	if job.UserID != userID {
+		writeJSONError(w, http.StatusForbidden, errCodeForbidden, "Forbidden")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+
+	send := func(job *models.Job) error {
+		job.SetOutputExpiry(api.Config.JobOutputRetention())
+		job.SetDuration()
+		data, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: status\ndata: %s\n\n", data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	if err := send(job); err != nil {
+		log.Printf("[EVENTS] Cannot stream job %s: %v", job.ID, err)
+		return
+	}
+
+	heartbeat := time.NewTicker(jobEventHeartbeat)
+	defer heartbeat.Stop()
+	poll := time.NewTicker(statusWaitPoll)
+	defer poll.Stop()
+
+	for !job.Status.IsTerminal() {
+		changed, stop := database.WatchJob(job.ID)
+		// Re-read after subscribing so no update is missed in between
+		if latest, err := api.database().GetJobByID(r.Context(), job.ID); err == nil && latest.Status != job.Status {
+			stop()
+			job = latest
+			if err := send(job); err != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-changed:
+		case <-poll.C:
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil || rc.Flush() != nil {
+				stop()
+				return
+			}
+		case <-r.Context().Done():
+			stop()
+			return
+		}
+		stop()
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jobEventsServer serves JobEventsHandler as userID
+func jobEventsServer(t *testing.T, userID string) *httptest.Server {
+	t.Helper()
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "userID", userID)))
+		})
+	})
+	r.Get("/jobs/{jobID}/events", (&Api{}).JobEventsHandler)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// readStatusEvent returns the job carried by the next status event
+func readStatusEvent(t *testing.T, events *bufio.Scanner) models.Job {
+	t.Helper()
+	var event string
+	for events.Scan() {
+		line := events.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: ") && event == "status":
+			var job models.Job
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &job))
+			return job
+		}
+	}
+	t.Fatalf("stream ended before a status event: %v", events.Err())
+	return models.Job{}
+}
+
+func TestJobEventsStreamsStatusChanges(t *testing.T) {
+	initTestDatabase(t)
+
+	user, err := database.CreateUser(fmt.Sprintf("events-%d@example.com", time.Now().UnixNano()), "password")
+	require.NoError(t, err)
+	job := &models.Job{ID: database.GenerateID(), UserID: user.ID, JobID: database.GenerateID(), Status: models.JobStatusPending, OutputFormat: "fhir", CreatedAt: time.Now()}
+	require.NoError(t, job.MarshalParameters())
+	require.NoError(t, database.CreateJob(job))
+
+	srv := jobEventsServer(t, user.ID)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/jobs/"+job.ID+"/events", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	events := bufio.NewScanner(resp.Body)
+
+	assert.Equal(t, models.JobStatusPending, readStatusEvent(t, events).Status, "the current status is sent on connect")
+
+	require.NoError(t, database.UpdateJobStatus(job.ID, models.JobStatusRunning, nil, nil, nil, nil))
+	assert.Equal(t, models.JobStatusRunning, readStatusEvent(t, events).Status)
+
+	require.NoError(t, database.UpdateJobStatus(job.ID, models.JobStatusCompleted, nil, nil, nil, nil))
+	assert.Equal(t, models.JobStatusCompleted, readStatusEvent(t, events).Status)
+
+	for events.Scan() {
+	}
+	assert.NoError(t, events.Err(), "the stream closes once the job has finished")
+}
+
+func TestJobEventsChecksOwnership(t *testing.T) {
+	initTestDatabase(t)
+
+	owner, err := database.CreateUser(fmt.Sprintf("events-owner-%d@example.com", time.Now().UnixNano()), "password")
+	require.NoError(t, err)
+	job := &models.Job{ID: database.GenerateID(), UserID: owner.ID, JobID: database.GenerateID(), Status: models.JobStatusPending, OutputFormat: "fhir", CreatedAt: time.Now()}
+	require.NoError(t, job.MarshalParameters())
+	require.NoError(t, database.CreateJob(job))
+
+	srv := jobEventsServer(t, "someone-else")
+
+	resp, err := http.Get(srv.URL + "/jobs/" + job.ID + "/events")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, err = http.Get(srv.URL + "/jobs/missing/events")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,275 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/MediSynth-io/medisynth/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSynthea stands in for the Synthea process: it writes files under the
+// output directory it is given, prints stdout and stderr, and returns err
+type fakeSynthea struct {
+	files  map[string]string // Relative to --exporter.base_directory
+	stdout string
+	stderr string
+	err    error
+
+	name string
+	args []string
+}
+
+func (f *fakeSynthea) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	f.name, f.args = name, args
+	i := slices.Index(args, "--exporter.base_directory")
+	if i < 0 || i+1 >= len(args) {
+		return errors.New("no output directory")
+	}
+	for file, content := range f.files {
+		path := filepath.Join(args[i+1], file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	io.WriteString(stdout, f.stdout)
+	io.WriteString(stderr, f.stderr)
+	return f.err
+}
+
+// failingPutBackend is a storage backend that refuses to store output files
+type failingPutBackend struct {
+	storage.Backend
+}
+
+func (b *failingPutBackend) Put(ctx context.Context, key string, body io.ReadSeeker, size int64, opts storage.PutOptions) error {
+	if strings.HasSuffix(key, storage.JobLogFilename) {
+		return b.Backend.Put(ctx, key, body, size, opts)
+	}
+	return errors.New("bucket unavailable")
+}
+
+func newExecuteTestApi(t *testing.T, backend storage.Backend, runner CommandRunner) *Api {
+	t.Helper()
+	api, err := NewApi(config.Config{APIPort: 8081, SyntheaCommand: "synthea-test", OutputExtensionsFHIR: ".json"}, backend)
+	require.NoError(t, err)
+	api.Runner = runner
+	return api
+}
+
+func createExecuteTestJob(t *testing.T) *models.Job {
+	t.Helper()
+	user, err := database.CreateUser(fmt.Sprintf("execute-%d@example.com", time.Now().UnixNano()), "password")
+	require.NoError(t, err)
+
+	job := &models.Job{
+		ID:           database.GenerateID(),
+		UserID:       user.ID,
+		JobID:        database.GenerateID(),
+		Status:       models.JobStatusPending,
+		Parameters:   map[string]interface{}{"population": 2, "gender": "F"},
+		OutputFormat: "fhir",
+	}
+	require.NoError(t, job.MarshalParameters())
+	require.NoError(t, database.CreateJob(job))
+	return job
+}
+
+// jobLog fetches the job's Synthea log through the logs endpoint
+func jobLog(t *testing.T, api *Api, job *models.Job) string {
+	t.Helper()
+	r := chi.NewRouter()
+	r.Get("/jobs/{jobID}/logs", api.GetJobLogsHandler)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, asUser(httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID+"/logs", nil), job.UserID))
+	require.Equal(t, http.StatusOK, rec.Code)
+	return rec.Body.String()
+}
+
+func TestExecuteSyntheaJob(t *testing.T) {
+	initTestDatabase(t)
+
+	t.Run("success", func(t *testing.T) {
+		backend := newTestBackend(t)
+		synthea := &fakeSynthea{
+			files: map[string]string{
+				"fhir/patient1.json": `{"resourceType":"Bundle"}`,
+				"fhir/patient2.json": `{"resourceType":"Bundle","id":"2"}`,
+				"fhir/notes.txt":     "not an output",
+				"metadata/run.json":  `{}`,
+			},
+			stdout: "1 -- Jane Doe (34 y/o F) Boston, Massachusetts\n2 -- Ann Roe (61 y/o F) Salem, Massachusetts\n",
+		}
+		api := newExecuteTestApi(t, backend, synthea)
+		job := createExecuteTestJob(t)
+
+		api.executeSyntheaJob(job)
+
+		assert.Equal(t, "synthea-test", synthea.name)
+		assert.Subset(t, synthea.args, []string{"-p", "2", "-g", "F"})
+
+		stored, err := database.GetJobByID(job.ID)
+		require.NoError(t, err)
+		require.Equal(t, models.JobStatusCompleted, stored.Status, "error: %v", stored.ErrorMessage)
+		require.NotNil(t, stored.PatientCount)
+		assert.Equal(t, 2, *stored.PatientCount)
+		require.NotNil(t, stored.OutputSize)
+		assert.Equal(t, int64(len(synthea.files["fhir/patient1.json"])+len(synthea.files["fhir/patient2.json"])), *stored.OutputSize)
+		assert.NotNil(t, stored.StartedAt)
+
+		objects, err := backend.List(context.Background(), jobS3Prefix(job)+"fhir/")
+		require.NoError(t, err)
+		var keys []string
+		for _, object := range objects {
+			keys = append(keys, strings.TrimPrefix(object.Key, jobS3Prefix(job)))
+		}
+		assert.Equal(t, []string{"fhir/patient1.json", "fhir/patient2.json"}, keys, "only allowed files of the requested format are published")
+		assert.Contains(t, jobLog(t, api, job), "Jane Doe")
+	})
+
+	t.Run("own database", func(t *testing.T) {
+		ctx := context.Background()
+		db := openTestDatabase(t)
+		synthea := &fakeSynthea{files: map[string]string{"fhir/patient1.json": `{"resourceType":"Bundle"}`}, stdout: "1 -- Jane Doe (34 y/o F) Boston, Massachusetts\n"}
+		api := newExecuteTestApi(t, newTestBackend(t), synthea)
+		api.DB = db
+		user, err := db.CreateUser(ctx, "own-database@example.com", "password")
+		require.NoError(t, err)
+		job := &models.Job{ID: database.GenerateID(), UserID: user.ID, JobID: database.GenerateID(), Status: models.JobStatusPending,
+			Parameters: map[string]interface{}{"population": 1}, OutputFormat: "fhir"}
+		require.NoError(t, job.MarshalParameters())
+		require.NoError(t, db.CreateJob(ctx, job))
+
+		api.executeSyntheaJob(job)
+
+		stored, err := db.GetJobByID(ctx, job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.JobStatusCompleted, stored.Status, "error: %v", stored.ErrorMessage)
+		_, err = database.GetJobByID(job.ID)
+		assert.ErrorIs(t, err, sql.ErrNoRows, "nothing is written to the default database")
+	})
+
+	t.Run("claimed elsewhere", func(t *testing.T) {
+		synthea := &fakeSynthea{}
+		api := newExecuteTestApi(t, newTestBackend(t), synthea)
+		job := createExecuteTestJob(t)
+		require.NoError(t, database.UpdateJobStatus(job.ID, models.JobStatusRunning, nil, nil, nil, nil))
+
+		api.executeSyntheaJob(job)
+
+		assert.Empty(t, synthea.name, "a job that is no longer pending is not run again")
+		stored, err := database.GetJobByID(job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.JobStatusRunning, stored.Status)
+	})
+
+	t.Run("claim failure", func(t *testing.T) {
+		ctx := context.Background()
+		db := openTestDatabase(t)
+		synthea := &fakeSynthea{}
+		api := newExecuteTestApi(t, newTestBackend(t), synthea)
+		api.DB = db
+		user, err := db.CreateUser(ctx, "claim-failure@example.com", "password")
+		require.NoError(t, err)
+		job := &models.Job{ID: database.GenerateID(), UserID: user.ID, JobID: database.GenerateID(), Status: models.JobStatusPending,
+			Parameters: map[string]interface{}{"population": 1}, OutputFormat: "fhir"}
+		require.NoError(t, job.MarshalParameters())
+		require.NoError(t, db.CreateJob(ctx, job))
+		// Refuse the move to running, as a failing database would
+		_, err = db.Conn().Exec(`CREATE TRIGGER refuse_claim BEFORE UPDATE OF status ON jobs WHEN NEW.status = 'running'
+			BEGIN SELECT RAISE(ABORT, 'claim refused'); END`)
+		require.NoError(t, err)
+
+		api.executeSyntheaJob(job)
+
+		assert.Empty(t, synthea.name, "Synthea is not run for an unclaimed job")
+		stored, err := db.GetJobByID(ctx, job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.JobStatusFailed, stored.Status, "the job does not stay pending until the next restart")
+	})
+
+	t.Run("synthea failure", func(t *testing.T) {
+		synthea := &fakeSynthea{
+			stdout: "Running with options:\n",
+			stderr: "Exception in thread \"main\" java.lang.OutOfMemoryError",
+			err:    errors.New("exit status 1"),
+		}
+		api := newExecuteTestApi(t, newTestBackend(t), synthea)
+		job := createExecuteTestJob(t)
+
+		api.executeSyntheaJob(job)
+
+		stored, err := database.GetJobByID(job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.JobStatusFailed, stored.Status)
+		require.NotNil(t, stored.ErrorMessage)
+		assert.Contains(t, *stored.ErrorMessage, "OutOfMemoryError", "the stderr tail explains the failure")
+		assert.Contains(t, jobLog(t, api, job), "Running with options", "the log of a failed run is kept")
+	})
+
+	t.Run("no output", func(t *testing.T) {
+		for name, files := range map[string]map[string]string{
+			"missing directory": nil,
+			"empty directory":   {"fhir/notes.txt": "not an output", "metadata/run.json": `{}`},
+		} {
+			t.Run(name, func(t *testing.T) {
+				api := newExecuteTestApi(t, newTestBackend(t), &fakeSynthea{files: files})
+				job := createExecuteTestJob(t)
+
+				api.executeSyntheaJob(job)
+
+				stored, err := database.GetJobByID(job.ID)
+				require.NoError(t, err)
+				assert.Equal(t, models.JobStatusFailed, stored.Status, "a run that exits 0 without output is not a success")
+				require.NotNil(t, stored.ErrorMessage)
+				assert.Contains(t, *stored.ErrorMessage, "produced no fhir output")
+			})
+		}
+	})
+
+	t.Run("upload failure", func(t *testing.T) {
+		backend := &failingPutBackend{Backend: newTestBackend(t)}
+		synthea := &fakeSynthea{
+			files:  map[string]string{"fhir/patient1.json": `{"resourceType":"Bundle"}`},
+			stdout: "1 -- Jane Doe (34 y/o F) Boston, Massachusetts\n",
+		}
+		api := newExecuteTestApi(t, backend, synthea)
+		job := createExecuteTestJob(t)
+
+		done := make(chan struct{})
+		go func() {
+			api.executeSyntheaJob(job)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("executeSyntheaJob did not return")
+		}
+
+		stored, err := database.GetJobByID(job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.JobStatusFailed, stored.Status)
+		require.NotNil(t, stored.ErrorMessage)
+		assert.Contains(t, *stored.ErrorMessage, "bucket unavailable")
+	})
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerationStatusReportsOutputExpiry(t *testing.T) {
+	initTestDatabase(t)
+
+	user, err := database.CreateUser(fmt.Sprintf("expiry-%d@example.com", time.Now().UnixNano()), "password")
+	assert.NoError(t, err)
+
+	completed := &models.Job{ID: database.GenerateID(), UserID: user.ID, JobID: database.GenerateID(), Status: models.JobStatusPending, OutputFormat: "fhir", CreatedAt: time.Now()}
+	pending := &models.Job{ID: database.GenerateID(), UserID: user.ID, JobID: database.GenerateID(), Status: models.JobStatusPending, OutputFormat: "fhir", CreatedAt: time.Now()}
+	for _, job := range []*models.Job{completed, pending} {
+		assert.NoError(t, job.MarshalParameters())
+		assert.NoError(t, database.CreateJob(job))
+	}
+	assert.NoError(t, database.UpdateJobStatus(completed.ID, models.JobStatusCompleted, nil, nil, nil, nil))
+
+	api := &Api{Config: config.Config{JobOutputRetentionDays: 7}}
+	r := chi.NewRouter()
+	r.Get("/generation-status/{jobID}", api.GetGenerationStatus)
+
+	status := func(jobID string) *models.Job {
+		req := httptest.NewRequest("GET", "/generation-status/"+jobID, nil)
+		req = req.WithContext(context.WithValue(req.Context(), "userID", user.ID))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var job models.Job
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+		return &job
+	}
+
+	got := status(completed.ID)
+	if assert.NotNil(t, got.CompletedAt) && assert.NotNil(t, got.OutputExpiresAt) {
+		assert.WithinDuration(t, got.CompletedAt.Add(7*24*time.Hour), *got.OutputExpiresAt, time.Second)
+	}
+
+	assert.Nil(t, status(pending.ID).OutputExpiresAt, "unfinished jobs have no expiry yet")
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/MediSynth-io/medisynth/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxJobLogSize caps how much Synthea output is kept per job. Only the
+// last maxJobLogSize bytes are stored, since the tail is where failures show up.
+const maxJobLogSize = 1024 * 1024
+
+// tailBuffer is an io.Writer that keeps only the last max bytes written to it.
+// Once full it overwrites its oldest bytes in place, so each write costs the
+// size of the write however long the output runs. It is safe for concurrent
+// use, as exec.Cmd copies stdout and stderr from separate goroutines.
+type tailBuffer struct {
+	mu        sync.Mutex
+	buf       []byte // Grows to max, then wraps around at next
+	next      int    // Where the next byte goes once buf is full
+	max       int
+	truncated bool
+}
+
+func newTailBuffer(max int) *tailBuffer {
+	return &tailBuffer{max: max}
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := len(p)
+	if n >= t.max {
+		p = p[n-t.max:]
+		t.truncated = true
+	}
+	if room := t.max - len(t.buf); room > 0 {
+		fill := min(room, len(p))
+		t.buf = append(t.buf, p[:fill]...)
+		p = p[fill:]
+	}
+	for len(p) > 0 {
+		copied := copy(t.buf[t.next:], p)
+		p = p[copied:]
+		t.next = (t.next + copied) % t.max
+		t.truncated = true
+	}
+	return n, nil
+}
+
+// Bytes returns a copy of the retained output, oldest byte first
+func (t *tailBuffer) Bytes() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]byte, 0, len(t.buf))
+	out = append(out, t.buf[t.next:]...)
+	return append(out, t.buf[:t.next]...)
+}
+
+// String returns the retained output as a string
+func (t *tailBuffer) String() string {
+	return string(t.Bytes())
+}
+
+// jobS3Prefix returns the key prefix under which a job's artifacts are stored
+func jobS3Prefix(job *models.Job) string {
+	return storage.JobOutputPrefix(job.JobID)
+}
+
+// jobObjectMetadata returns the metadata stored with every object of job, so
+// an object found in the bucket can be traced back to its job and owner
+func jobObjectMetadata(job *models.Job) map[string]string {
+	return storage.JobMetadata(job.UserID, job.ID, job.OutputFormat)
+}
+
+// uploadJobLog gzip-compresses the captured Synthea output and stores it under the job prefix
+func (api *Api) uploadJobLog(job *models.Job, output *tailBuffer) error {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if output.truncated {
+		fmt.Fprintf(gz, "[log truncated to the last %d bytes]\n", maxJobLogSize)
+	}
+	if _, err := gz.Write(output.Bytes()); err != nil {
+		return fmt.Errorf("failed to compress log: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress log: %w", err)
+	}
+
+	// The job context may already be cancelled or timed out, which is
+	// exactly when the log matters most, so use a fresh one.
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	key := storage.JobLogKey(job.JobID)
+	return api.Storage.Put(ctx, key, bytes.NewReader(compressed.Bytes()), int64(compressed.Len()), storage.PutOptions{
+		ContentType: "application/gzip",
+		Metadata:    jobObjectMetadata(job),
+	})
+}
+
+// GetJobLogsHandler streams the decompressed Synthea log of a finished job to its owner
+func (api *Api) GetJobLogsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
+		return
+	}
+
+	jobID := chi.URLParam(r, "jobID")
+	job, err := api.database().GetJobByID(r.Context(), jobID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Job not found")
+		return
+	}
+
+	if job.UserID != userID {
+		writeJSONError(w, http.StatusForbidden, errCodeForbidden, "Forbidden")
+		return
+	}
+
+	if job.Status == models.JobStatusPending || job.Status == models.JobStatusRunning {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jobID":   job.ID,
+			"status":  job.Status,
+			"message": "Logs are not available until the job has finished.",
+		})
+		return
+	}
+
+	key := storage.JobLogKey(job.JobID)
+	body, err := api.Storage.Get(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			writeJSONError(w, http.StatusNotFound, errCodeNotFound, "No logs recorded for this job")
+			return
+		}
+		log.Printf("ERROR: Failed to fetch logs for job %s: %v", jobID, err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to retrieve job logs")
+		return
+	}
+	defer body.Close()
+
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		log.Printf("ERROR: Stored log for job %s is not valid gzip: %v", jobID, err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to retrieve job logs")
+		return
+	}
+	defer gz.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := io.Copy(w, gz); err != nil {
+		log.Printf("ERROR: Failed to stream logs for job %s: %v", jobID, err)
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailBuffer(t *testing.T) {
+	tail := newTailBuffer(8)
+	io.WriteString(tail, "abc")
+	io.WriteString(tail, "def")
+	assert.Equal(t, "abcdef", tail.String())
+	assert.False(t, tail.truncated)
+
+	io.WriteString(tail, "ghij")
+	assert.Equal(t, "cdefghij", tail.String(), "the oldest bytes are dropped once full")
+	assert.True(t, tail.truncated)
+
+	// Wrapping more than once keeps the order
+	for i := 0; i < 5; i++ {
+		io.WriteString(tail, fmt.Sprint(i))
+	}
+	assert.Equal(t, "hij01234", tail.String())
+
+	io.WriteString(tail, "0123456789xyz")
+	assert.Equal(t, "56789xyz", tail.String(), "a write longer than the buffer keeps its own tail")
+
+	// Line-by-line output, as Synthea writes it, keeps exactly the tail
+	lines := newTailBuffer(1024)
+	var all strings.Builder
+	for i := 0; i < 1000; i++ {
+		line := fmt.Sprintf("%d -- Patient %d\n", i, i)
+		io.WriteString(lines, line)
+		all.WriteString(line)
+	}
+	assert.Equal(t, all.String()[all.Len()-1024:], lines.String())
+}
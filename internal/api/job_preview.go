@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/MediSynth-io/medisynth/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxPreviewSize is the largest output file that can be previewed inline
+const maxPreviewSize = 256 << 10
+
+// previewContentTypes are the text formats that can be previewed, by extension
+var previewContentTypes = map[string]string{
+	".json":   "application/json",
+	".ndjson": "application/x-ndjson",
+	".csv":    "text/csv; charset=utf-8",
+}
+
+// PreviewJobFileHandler streams one small JSON or CSV output file of a job to
+// its owner inline, so a single record can be inspected without downloading
+// the dataset. Larger and binary files are refused with a 413 pointing at the
+// download URLs from /jobs/{jobID}/files.
+func (api *Api) PreviewJobFileHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
+		return
+	}
+
+	jobID := chi.URLParam(r, "jobID")
+	job, err := api.database().GetJobByID(r.Context(), jobID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Job not found")
+		return
+	}
+
+	if job.UserID != userID {
+		writeJSONError(w, http.StatusForbidden, errCodeForbidden, "Forbidden")
+		return
+	}
+
+	if job.OutputPath == nil || *job.OutputPath == "" {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Job has no output path")
+		return
+	}
+
+	fileName, err := url.PathUnescape(chi.URLParam(r, "fileName"))
+	if err != nil || fileName == "" || strings.Contains(fileName, "/") || strings.Contains(fileName, "..") {
+		writeJSONError(w, http.StatusBadRequest, errCodeValidationFailed, "Invalid file name")
+		return
+	}
+
+	object, err := api.statJobFile(r.Context(), job, fileName)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			writeJSONError(w, http.StatusNotFound, errCodeNotFound, "File not found")
+			return
+		}
+		log.Printf("ERROR: Failed to look up %s of job %s: %v", fileName, jobID, err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to retrieve file")
+		return
+	}
+
+	contentType, ok := previewContentTypes[strings.ToLower(path.Ext(fileName))]
+	if !ok || object.Size > maxPreviewSize {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, errCodePayloadTooLarge, fmt.Sprintf(
+			"Only JSON and CSV files up to %d KB can be previewed; download this file using its URL from /jobs/%s/files",
+			maxPreviewSize>>10, job.ID))
+		return
+	}
+
+	body, err := api.Storage.Get(r.Context(), object.Key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			writeJSONError(w, http.StatusNotFound, errCodeNotFound, "File not found")
+			return
+		}
+		log.Printf("ERROR: Failed to fetch %s for preview: %v", object.Key, err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to retrieve file")
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "inline")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := io.Copy(w, io.LimitReader(body, maxPreviewSize)); err != nil {
+		log.Printf("ERROR: Failed to stream preview of %s: %v", object.Key, err)
+	}
+}
+
+// statJobFile looks up the output file of job called fileName. Synthea writes
+// each format into a directory of its own, which is tried before the top level
+// of the job's output. The Synthea log is not an output file.
+func (api *Api) statJobFile(ctx context.Context, job *models.Job, fileName string) (storage.Object, error) {
+	prefix := jobS3Prefix(job)
+	for _, key := range []string{prefix + job.OutputFormat + "/" + fileName, prefix + fileName} {
+		if key == storage.JobLogKey(job.JobID) {
+			continue
+		}
+		object, err := api.Storage.Stat(ctx, key)
+		if !errors.Is(err, storage.ErrNotFound) {
+			return object, err
+		}
+	}
+	return storage.Object{}, storage.ErrNotFound
+}
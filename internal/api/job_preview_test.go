@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/MediSynth-io/medisynth/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewJobFileHandler(t *testing.T) {
+	initTestDatabase(t)
+	backend := newTestBackend(t)
+	api := &Api{Storage: backend}
+
+	job := createUploadTestJob(t)
+	prefix := jobS3Prefix(job)
+	require.NoError(t, database.UpdateJobStatus(job.ID, models.JobStatusCompleted, nil, &prefix, nil, nil))
+	other, err := database.CreateUser(fmt.Sprintf("preview-other-%d@example.com", time.Now().UnixNano()), "password")
+	require.NoError(t, err)
+
+	store := func(name, content string) {
+		require.NoError(t, backend.Put(context.Background(), prefix+"fhir/"+name, strings.NewReader(content), int64(len(content)), storage.PutOptions{}))
+	}
+	patient := `{"resourceType":"Patient","id":"1"}`
+	store("patient.json", patient)
+	store("hospitals.json", "["+strings.Repeat(`{"resourceType":"Organization"},`, maxPreviewSize/32)+"{}]")
+	store("bundle.zip", "PK")
+
+	preview := func(userID, fileName string) *httptest.ResponseRecorder {
+		r := chi.NewRouter()
+		r.Get("/jobs/{jobID}/files/{fileName}/preview", api.PreviewJobFileHandler)
+		req := asUser(httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID+"/files/"+fileName+"/preview", nil), userID)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("small JSON file", func(t *testing.T) {
+		rec := preview(job.UserID, "patient.json")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "inline", rec.Header().Get("Content-Disposition"))
+		assert.Equal(t, patient, rec.Body.String())
+	})
+
+	t.Run("oversized file", func(t *testing.T) {
+		rec := preview(job.UserID, "hospitals.json")
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		body := decodeErrorBody(t, rec)
+		assert.Equal(t, errCodePayloadTooLarge, body.Error.Code)
+		assert.Contains(t, body.Error.Message, "/jobs/"+job.ID+"/files", "the download endpoint is suggested")
+	})
+
+	t.Run("binary file", func(t *testing.T) {
+		rec := preview(job.UserID, "bundle.zip")
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("unknown file", func(t *testing.T) {
+		rec := preview(job.UserID, "missing.json")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("job log", func(t *testing.T) {
+		require.NoError(t, backend.Put(context.Background(), storage.JobLogKey(job.JobID), strings.NewReader("log"), 3, storage.PutOptions{}))
+		rec := preview(job.UserID, storage.JobLogFilename)
+		assert.Equal(t, http.StatusNotFound, rec.Code, "the log is not an output file")
+	})
+
+	t.Run("top-level file", func(t *testing.T) {
+		require.NoError(t, backend.Put(context.Background(), prefix+"summary.csv", strings.NewReader("id\n1\n"), 5, storage.PutOptions{}))
+		rec := preview(job.UserID, "summary.csv")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "id\n1\n", rec.Body.String())
+	})
+
+	t.Run("path in file name", func(t *testing.T) {
+		for _, name := range []string{"fhir%2Fpatient.json", "..", "..%2Fother-job%2Fpatient.json"} {
+			rec := preview(job.UserID, name)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, name)
+		}
+	})
+
+	t.Run("another user's job", func(t *testing.T) {
+		rec := preview(other.ID, "patient.json")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/models"
+)
+
+// errJobQueueFull is returned by enqueueJob when no more jobs can be accepted
+var errJobQueueFull = errors.New("job queue is full")
+
+// startJobWorkers sets up the job queue and the worker pool that drains it.
+// Each Synthea run is a heavy JVM process, so the number of workers, and with
+// it the number of jobs running at once, is capped by MAX_CONCURRENT_JOBS.
+// At most MAX_QUEUED_JOBS accepted jobs wait for a free worker.
+func (api *Api) startJobWorkers() {
+	workers := api.Config.MaxConcurrentJobs
+	if workers <= 0 {
+		workers = 1
+	}
+
+	api.jobQueue = make(chan *models.Job, api.Config.JobQueueSize())
+	if api.jobRunner == nil {
+		api.jobRunner = api.executeSyntheaJob
+	}
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range api.jobQueue {
+				api.jobRunner(job)
+			}
+		}()
+	}
+	log.Printf("Started %d Synthea job workers", workers)
+}
+
+// enqueueJob hands a pending job to the worker pool without blocking
+func (api *Api) enqueueJob(job *models.Job) error {
+	select {
+	case api.jobQueue <- job:
+		return nil
+	default:
+		return errJobQueueFull
+	}
+}
+
+// QueueDepth returns the number of jobs waiting for a free worker
+func (api *Api) QueueDepth() int {
+	return len(api.jobQueue)
+}
+
+// interruptedJobMessage is recorded on jobs that were running when the API stopped
+const interruptedJobMessage = "interrupted by restart"
+
+// The process running a job refreshes its heartbeat every jobHeartbeatInterval.
+// A running job whose heartbeat is older than staleJobAfter has lost its
+// process; younger ones may belong to another API instance.
+const (
+	jobHeartbeatInterval = 30 * time.Second
+	staleJobAfter        = 5 * jobHeartbeatInterval
+)
+
+// keepJobAlive refreshes the job's heartbeat until ctx is done
+func (api *Api) keepJobAlive(ctx context.Context, jobID string) {
+	ticker := time.NewTicker(jobHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := api.database().TouchJobHeartbeat(ctx, jobID); err != nil {
+				log.Printf("WARNING: Failed to record heartbeat of job %s: %v", jobID, err)
+			}
+		}
+	}
+}
+
+// recoverJobs picks up jobs orphaned by a previous process. Pending jobs never
+// started and are queued again; executeSyntheaJob claims each one, so a job
+// queued by several instances runs once. Running jobs whose heartbeat has
+// gone stale lost their Synthea process and temp directory, so they are
+// marked failed. Other running jobs are left to the instance running them.
+func (api *Api) recoverJobs() error {
+	ctx := context.Background()
+	db := api.database()
+
+	running, err := db.GetStaleRunningJobs(ctx, time.Now().Add(-staleJobAfter))
+	if err != nil {
+		return fmt.Errorf("failed to load running jobs: %w", err)
+	}
+	for _, job := range running {
+		errMsg := interruptedJobMessage
+		if err := db.UpdateJobStatus(ctx, job.ID, models.JobStatusFailed, &errMsg, nil, nil, nil); err != nil {
+			log.Printf("ERROR: Failed to mark interrupted job %s as failed: %v", job.ID, err)
+			continue
+		}
+		log.Printf("Marked job %s as failed: %s", job.ID, interruptedJobMessage)
+	}
+
+	pending, err := db.GetJobsByStatus(ctx, models.JobStatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to load pending jobs: %w", err)
+	}
+	for _, job := range pending {
+		if err := api.enqueueJob(job); err != nil {
+			log.Printf("ERROR: Failed to re-enqueue pending job %s: %v", job.ID, err)
+			continue
+		}
+		log.Printf("Re-enqueued pending job %s", job.ID)
+	}
+
+	return nil
+}
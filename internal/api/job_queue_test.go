@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobQueueRespectsConcurrencyLimit(t *testing.T) {
+	api, err := NewApi(config.Config{APIPort: 8081, MaxConcurrentJobs: 2}, newTestBackend(t))
+	assert.NoError(t, err)
+
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+	api.jobRunner = func(job *models.Job) {
+		defer wg.Done()
+
+		now := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if now <= max || atomic.CompareAndSwapInt32(&maxRunning, max, now) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+	}
+
+	const jobs = 6
+	wg.Add(jobs)
+	for i := 0; i < jobs; i++ {
+		err := api.enqueueJob(&models.Job{ID: fmt.Sprintf("queued-job-%d", i)})
+		assert.NoError(t, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued jobs did not finish in time")
+	}
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxRunning), "jobs should run at most MAX_CONCURRENT_JOBS at a time")
+	assert.Equal(t, 0, api.QueueDepth())
+}
+
+func TestJobQueueSize(t *testing.T) {
+	api, err := NewApi(config.Config{APIPort: 8081, MaxConcurrentJobs: 1, MaxQueuedJobs: 2}, newTestBackend(t))
+	require.NoError(t, err)
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	api.jobRunner = func(job *models.Job) {
+		started <- struct{}{}
+		<-release
+	}
+	defer close(release)
+
+	// One job occupies the only worker; two more fill the queue
+	require.NoError(t, api.enqueueJob(&models.Job{ID: "running-job"}))
+	<-started
+	for i := 0; i < 2; i++ {
+		require.NoError(t, api.enqueueJob(&models.Job{ID: fmt.Sprintf("waiting-job-%d", i)}))
+	}
+	assert.Equal(t, 2, api.QueueDepth())
+	assert.ErrorIs(t, api.enqueueJob(&models.Job{ID: "refused-job"}), errJobQueueFull)
+
+	assert.Equal(t, 1000, (&config.Config{}).JobQueueSize(), "the queue size defaults when MAX_QUEUED_JOBS is unset")
+}
+
+var testDBOnce sync.Once
+
+// initTestDatabase opens a throwaway SQLite database shared by the package's tests
+func initTestDatabase(t *testing.T) {
+	t.Helper()
+	var err error
+	testDBOnce.Do(func() {
+		var dir string
+		dir, err = os.MkdirTemp("", "medisynth-api-test")
+		if err != nil {
+			return
+		}
+		_, err = database.Init(&config.Config{DatabaseType: "sqlite", DatabasePath: filepath.Join(dir, "test.db")})
+	})
+	if err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+}
+
+// openTestDatabase opens a SQLite database of the test's own, for tests that
+// must see exactly the jobs they create
+func openTestDatabase(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.Open(&config.Config{DatabaseType: "sqlite", DatabasePath: filepath.Join(t.TempDir(), "test.db")})
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRecoverJobsAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	user, err := db.CreateUser(ctx, "recover@example.com", "password")
+	require.NoError(t, err)
+
+	createJob := func(status models.JobStatus) *models.Job {
+		job := &models.Job{ID: database.GenerateID(), UserID: user.ID, JobID: database.GenerateID(), Status: models.JobStatusPending, OutputFormat: "fhir"}
+		require.NoError(t, job.MarshalParameters())
+		require.NoError(t, db.CreateJob(ctx, job))
+		if status != models.JobStatusPending {
+			require.NoError(t, db.UpdateJobStatus(ctx, job.ID, status, nil, nil, nil, nil))
+		}
+		return job
+	}
+	stale := createJob(models.JobStatusRunning)
+	legacy := createJob(models.JobStatusRunning)
+	alive := createJob(models.JobStatusRunning)
+	pending := createJob(models.JobStatusPending)
+
+	long := time.Now().Add(-2 * staleJobAfter)
+	_, err = db.Conn().Exec("UPDATE jobs SET heartbeat_at = ? WHERE id = ?", long, stale.ID)
+	require.NoError(t, err)
+	_, err = db.Conn().Exec("UPDATE jobs SET heartbeat_at = NULL, updated_at = ? WHERE id = ?", long, legacy.ID)
+	require.NoError(t, err)
+
+	api, err := NewApi(config.Config{APIPort: 8081, MaxConcurrentJobs: 1}, newTestBackend(t))
+	require.NoError(t, err)
+	api.DB = db
+	resumed := make(chan string, 1)
+	api.jobRunner = func(job *models.Job) {
+		resumed <- job.ID
+	}
+
+	require.NoError(t, api.recoverJobs())
+
+	for _, job := range []*models.Job{stale, legacy} {
+		failed, err := db.GetJobByID(ctx, job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.JobStatusFailed, failed.Status, "a job without a recent heartbeat lost its process")
+		if assert.NotNil(t, failed.ErrorMessage) {
+			assert.Equal(t, interruptedJobMessage, *failed.ErrorMessage)
+		}
+	}
+
+	running, err := db.GetJobByID(ctx, alive.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusRunning, running.Status, "a job another instance is running is left alone")
+
+	select {
+	case id := <-resumed:
+		assert.Equal(t, pending.ID, id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending job was not re-enqueued")
+	}
+}
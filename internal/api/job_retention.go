@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// retentionInterval is how often expired job outputs are looked for
+const retentionInterval = time.Hour
+
+// startRetentionCleanup deletes expired job outputs every retentionInterval.
+// It does nothing when JOB_OUTPUT_RETENTION_DAYS keeps outputs indefinitely.
+func (api *Api) startRetentionCleanup() {
+	if api.Config.JobOutputRetention() <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(retentionInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := api.cleanupExpiredOutputs(context.Background(), time.Now()); err != nil {
+				log.Printf("Error cleaning up expired job outputs: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+	log.Printf("Deleting job outputs %d days after completion", api.Config.JobOutputRetentionDays)
+}
+
+// cleanupExpiredOutputs deletes the stored output of every completed job that
+// finished more than the retention window before now and is not marked
+// keep_output. Each job keeps its record, with its output path cleared. A
+// job whose output cannot be deleted is left as is and retried next time.
+// It returns how many jobs were cleaned up.
+func (api *Api) cleanupExpiredOutputs(ctx context.Context, now time.Time) (int, error) {
+	retention := api.Config.JobOutputRetention()
+	if retention <= 0 {
+		return 0, nil
+	}
+
+	jobs, err := api.database().GetJobsWithExpiredOutput(ctx, now.Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find jobs with expired output: %w", err)
+	}
+
+	cleaned := 0
+	for _, job := range jobs {
+		deleted, err := api.Storage.Delete(ctx, jobS3Prefix(job))
+		if err != nil {
+			log.Printf("ERROR: Failed to delete expired output of job %s after %d objects: %v", job.ID, deleted, err)
+			continue
+		}
+		if err := api.database().ClearJobOutput(ctx, job.ID); err != nil {
+			log.Printf("ERROR: Failed to clear output path of job %s: %v", job.ID, err)
+			continue
+		}
+		log.Printf("Deleted %d expired output objects of job %s", deleted, job.ID)
+		cleaned++
+	}
+	return cleaned, nil
+}
+
+// UpdateJobHandler changes the user-editable settings of a job. Currently
+// that is keep_output, which exempts the job's output from retention.
+func (api *Api) UpdateJobHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
+		return
+	}
+
+	var req struct {
+		KeepOutput *bool `json:"keep_output"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err, "Invalid request payload")
+		return
+	}
+	if req.KeepOutput == nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeValidationFailed, "keep_output is required")
+		return
+	}
+
+	jobID := chi.URLParam(r, "jobID")
+	if err := api.database().SetJobKeepOutput(r.Context(), userID, jobID, *req.KeepOutput); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Job not found")
+			return
+		}
+		log.Printf("ERROR: Failed to update job %s: %v", jobID, err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to update job")
+		return
+	}
+
+	job, err := api.database().GetJobByID(r.Context(), jobID)
+	if err != nil {
+		log.Printf("ERROR: Failed to reload job %s: %v", jobID, err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to update job")
+		return
+	}
+	job.SetOutputExpiry(api.Config.JobOutputRetention())
+	job.SetDuration()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
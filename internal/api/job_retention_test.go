@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanupExpiredOutputs(t *testing.T) {
+	initTestDatabase(t)
+
+	user, err := database.CreateUser(fmt.Sprintf("retention-%d@example.com", time.Now().UnixNano()), "password")
+	require.NoError(t, err)
+	newJob := func(status models.JobStatus) *models.Job {
+		job := &models.Job{ID: database.GenerateID(), UserID: user.ID, JobID: "synthea-" + database.GenerateID(), Status: models.JobStatusPending, OutputFormat: "fhir"}
+		require.NoError(t, job.MarshalParameters())
+		require.NoError(t, database.CreateJob(job))
+		prefix := jobS3Prefix(job)
+		require.NoError(t, database.UpdateJobStatus(job.ID, status, nil, &prefix, nil, nil))
+		return job
+	}
+	old := newJob(models.JobStatusCompleted)
+	kept := newJob(models.JobStatusCompleted)
+	require.NoError(t, database.SetJobKeepOutput(user.ID, kept.ID, true))
+	running := newJob(models.JobStatusRunning)
+
+	var prefixes []string
+	api := &Api{
+		Config: config.Config{JobOutputRetentionDays: 30},
+		Storage: &deleteFuncBackend{fn: func(ctx context.Context, prefix string) (int, error) {
+			prefixes = append(prefixes, prefix)
+			return 3, nil
+		}},
+	}
+
+	cleaned, err := api.cleanupExpiredOutputs(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, cleaned, "nothing is older than the retention window yet")
+	assert.Empty(t, prefixes)
+
+	// Jobs from other tests may expire too, so only this user's are checked
+	_, err = api.cleanupExpiredOutputs(context.Background(), time.Now().AddDate(0, 0, 31))
+	require.NoError(t, err)
+	assert.Contains(t, prefixes, jobS3Prefix(old))
+	assert.NotContains(t, prefixes, jobS3Prefix(kept), "kept jobs are exempt")
+	assert.NotContains(t, prefixes, jobS3Prefix(running), "only completed jobs expire")
+
+	stored, err := database.GetJobByID(old.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored.OutputPath, "the output is recorded as gone")
+	assert.Equal(t, models.JobStatusCompleted, stored.Status, "the job stays in the history")
+}
+
+func TestCleanupExpiredOutputsDisabled(t *testing.T) {
+	api := &Api{Storage: &deleteFuncBackend{fn: func(ctx context.Context, prefix string) (int, error) {
+		t.Fatalf("nothing is deleted when retention is off, got %s", prefix)
+		return 0, nil
+	}}}
+	cleaned, err := api.cleanupExpiredOutputs(context.Background(), time.Now().AddDate(10, 0, 0))
+	require.NoError(t, err)
+	assert.Zero(t, cleaned)
+}
+
+func TestUpdateJobHandlerKeepOutput(t *testing.T) {
+	initTestDatabase(t)
+
+	owner, err := database.CreateUser(fmt.Sprintf("keep-%d@example.com", time.Now().UnixNano()), "password")
+	require.NoError(t, err)
+	other, err := database.CreateUser(fmt.Sprintf("keep-other-%d@example.com", time.Now().UnixNano()), "password")
+	require.NoError(t, err)
+	job := &models.Job{ID: database.GenerateID(), UserID: owner.ID, JobID: "synthea-" + database.GenerateID(), Status: models.JobStatusPending, OutputFormat: "fhir"}
+	require.NoError(t, job.MarshalParameters())
+	require.NoError(t, database.CreateJob(job))
+	require.NoError(t, database.UpdateJobStatus(job.ID, models.JobStatusCompleted, nil, nil, nil, nil))
+
+	api := &Api{Config: config.Config{JobOutputRetentionDays: 30}}
+	patch := func(userID, body string) *httptest.ResponseRecorder {
+		r := chi.NewRouter()
+		r.Patch("/jobs/{jobID}", api.UpdateJobHandler)
+		req := asUser(httptest.NewRequest(http.MethodPatch, "/jobs/"+job.ID, strings.NewReader(body)), userID)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := patch(other.ID, `{"keep_output":true}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code, "another user's job is not found")
+
+	rec = patch(owner.ID, `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, errCodeValidationFailed, decodeErrorBody(t, rec).Error.Code)
+
+	rec = patch(owner.ID, `{"keep_output":true}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"keep_output":true`)
+	assert.NotContains(t, rec.Body.String(), "output_expires_at", "kept outputs do not expire")
+
+	rec = patch(owner.ID, `{"keep_output":false}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "output_expires_at")
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+)
+
+// maxStatusWait caps how long GET /generation-status/{jobID}?wait= holds the
+// request open
+const maxStatusWait = 60 * time.Second
+
+// statusWaitPoll is how often a waiting request re-reads the job, which
+// catches updates made by another API instance
+var statusWaitPoll = 2 * time.Second
+
+// parseStatusWait reads the wait query parameter, either a duration such as
+// "30s" or a number of seconds. Values above maxStatusWait are capped.
+func parseStatusWait(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(raw)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(raw)
+		if convErr != nil {
+			return 0, models.ValidationErrors{"wait": fmt.Sprintf("must be a duration such as 30s, got %q", raw)}
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+	if wait < 0 {
+		return 0, models.ValidationErrors{"wait": "must not be negative"}
+	}
+	return min(wait, maxStatusWait), nil
+}
+
+// waitForJob blocks until job reaches a terminal status, wait elapses or ctx
+// is done, and returns the latest copy of the job
+func (api *Api) waitForJob(ctx context.Context, job *models.Job, wait time.Duration) *models.Job {
+	if wait <= 0 || job.Status.IsTerminal() {
+		return job
+	}
+
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	poll := time.NewTicker(statusWaitPoll)
+	defer poll.Stop()
+
+	for {
+		changed, stop := database.WatchJob(job.ID)
+		// Re-read after subscribing so an update between the caller's read
+		// and the subscription is not missed
+		if latest, err := api.database().GetJobByID(ctx, job.ID); err == nil {
+			job = latest
+		}
+		if job.Status.IsTerminal() {
+			stop()
+			return job
+		}
+
+		select {
+		case <-changed:
+		case <-poll.C:
+		case <-deadline.C:
+			stop()
+			return job
+		case <-ctx.Done():
+			stop()
+			return job
+		}
+		stop()
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStatusWait(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"30s", 30 * time.Second, false},
+		{"15", 15 * time.Second, false},
+		{"10m", maxStatusWait, false},
+		{"-1s", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseStatusWait(tt.raw)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// statusWaitFixture creates a pending job and a router serving its status
+func statusWaitFixture(t *testing.T) (*models.Job, func(ctx context.Context, query string) *httptest.ResponseRecorder) {
+	t.Helper()
+	initTestDatabase(t)
+
+	user, err := database.CreateUser(fmt.Sprintf("wait-%d@example.com", time.Now().UnixNano()), "password")
+	require.NoError(t, err)
+	job := &models.Job{ID: database.GenerateID(), UserID: user.ID, JobID: database.GenerateID(), Status: models.JobStatusPending, OutputFormat: "fhir", CreatedAt: time.Now()}
+	require.NoError(t, job.MarshalParameters())
+	require.NoError(t, database.CreateJob(job))
+
+	r := chi.NewRouter()
+	r.Get("/generation-status/{jobID}", (&Api{}).GetGenerationStatus)
+	status := func(ctx context.Context, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/generation-status/"+job.ID+query, nil)
+		req = req.WithContext(context.WithValue(ctx, "userID", user.ID))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	return job, status
+}
+
+func TestGenerationStatusWaitReturnsWhenJobCompletes(t *testing.T) {
+	job, status := statusWaitFixture(t)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		database.UpdateJobStatus(job.ID, models.JobStatusCompleted, nil, nil, nil, nil)
+	}()
+
+	start := time.Now()
+	rec := status(context.Background(), "?wait=30s")
+	elapsed := time.Since(start)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var got models.Job
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, models.JobStatusCompleted, got.Status)
+	assert.GreaterOrEqual(t, elapsed, 100*time.Millisecond, "the request waited for the update")
+	assert.Less(t, elapsed, time.Second, "the request returned as soon as the job completed")
+}
+
+func TestGenerationStatusWaitTimesOut(t *testing.T) {
+	_, status := statusWaitFixture(t)
+
+	start := time.Now()
+	rec := status(context.Background(), "?wait=150ms")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+	var got models.Job
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, models.JobStatusPending, got.Status)
+}
+
+func TestGenerationStatusWaitStopsWhenClientCancels(t *testing.T) {
+	_, status := statusWaitFixture(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	status(ctx, "?wait=30s")
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestGenerationStatusRejectsInvalidWait(t *testing.T) {
+	_, status := statusWaitFixture(t)
+
+	rec := status(context.Background(), "?wait=soon")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	var body errorBody
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Contains(t, body.Error.Fields, "wait")
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListJobsHandlerFilters(t *testing.T) {
+	initTestDatabase(t)
+
+	user, err := database.CreateUser(fmt.Sprintf("list-jobs-%d@example.com", time.Now().UnixNano()), "password")
+	require.NoError(t, err)
+
+	newJob := func(status models.JobStatus, createdAt time.Time) *models.Job {
+		job := &models.Job{ID: database.GenerateID(), UserID: user.ID, JobID: database.GenerateID(), Status: status, OutputFormat: "fhir", CreatedAt: createdAt}
+		require.NoError(t, job.MarshalParameters())
+		require.NoError(t, database.CreateJob(job))
+		return job
+	}
+	now := time.Now()
+	failed := newJob(models.JobStatusFailed, now)
+	oldFailed := newJob(models.JobStatusFailed, now.AddDate(0, 0, -30))
+	newJob(models.JobStatusCompleted, now)
+
+	list := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/jobs"+query, nil)
+		req = req.WithContext(context.WithValue(req.Context(), "userID", user.ID))
+		rec := httptest.NewRecorder()
+		(&Api{}).ListJobsHandler(rec, req)
+		return rec
+	}
+	ids := func(rec *httptest.ResponseRecorder) []string {
+		var jobs []models.Job
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jobs))
+		var ids []string
+		for _, job := range jobs {
+			ids = append(ids, job.ID)
+		}
+		return ids
+	}
+
+	rec := list("?status=failed")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{failed.ID, oldFailed.ID}, ids(rec))
+
+	rec = list("?status=failed&from=" + now.AddDate(0, 0, -1).Format(time.RFC3339))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{failed.ID}, ids(rec))
+
+	rec = list("?to=" + now.AddDate(0, 0, -7).Format(models.JobFilterDateLayout))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{oldFailed.ID}, ids(rec))
+
+	for _, query := range []string{"?status=done", "?from=last-week", "?from=2024-03-02&to=2024-03-01"} {
+		rec = list(query)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		assert.Contains(t, rec.Body.String(), "validation_failed", query)
+	}
+}
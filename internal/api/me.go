@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// meResponse is the profile returned by GET /me. It is built field by field
+// so nothing sensitive on models.User can leak into the response.
+type meResponse struct {
+	ID         string    `json:"id"`
+	Email      string    `json:"email"`
+	Tier       string    `json:"tier"`
+	CreatedAt  time.Time `json:"created_at"`
+	TokenCount int       `json:"token_count"`
+}
+
+// MeHandler returns the profile of the authenticated user
+func (api *Api) MeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
+		return
+	}
+
+	user, err := api.database().GetUserByID(r.Context(), userID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "User not found")
+		return
+	}
+
+	tokens, err := api.database().GetUserTokens(r.Context(), userID)
+	if err != nil {
+		log.Printf("ERROR: Failed to count tokens for user %s: %v", userID, err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to load profile")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(meResponse{
+		ID:         user.ID,
+		Email:      user.Email,
+		Tier:       user.Tier,
+		CreatedAt:  user.CreatedAt,
+		TokenCount: len(tokens),
+	})
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeHandlerOmitsPassword(t *testing.T) {
+	initTestDatabase(t)
+
+	email := fmt.Sprintf("me-%d@example.com", time.Now().UnixNano())
+	user, err := database.CreateUser(email, "$2a$10$secret-hash")
+	assert.NoError(t, err)
+	_, err = database.CreateToken(user.ID, "cli", fmt.Sprintf("me-token-%d", time.Now().UnixNano()), nil)
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/me", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "userID", user.ID))
+	rec := httptest.NewRecorder()
+	(&Api{}).MeHandler(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "secret-hash")
+
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, email, body["email"])
+	assert.Equal(t, user.ID, body["id"])
+	assert.Equal(t, float64(1), body["token_count"])
+	assert.NotContains(t, body, "password")
+	assert.NotContains(t, body, "is_admin", "there is no admin role to report")
+}
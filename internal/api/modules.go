@@ -0,0 +1,31 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/MediSynth-io/medisynth/internal/models"
+)
+
+// The module list is fixed for the life of the process, so the response body
+// is encoded once and clients may cache it for an hour
+const modulesCacheControl = "public, max-age=3600"
+
+var (
+	modulesBodyOnce sync.Once
+	modulesBody     []byte
+)
+
+// ModulesHandler lists the Synthea modules a job may select with keepModules
+func (api *Api) ModulesHandler(w http.ResponseWriter, r *http.Request) {
+	modulesBodyOnce.Do(func() {
+		modulesBody, _ = json.Marshal(map[string]interface{}{
+			"modules": models.SyntheaModules(),
+		})
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", modulesCacheControl)
+	w.Write(modulesBody)
+}
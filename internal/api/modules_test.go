@@ -0,0 +1,31 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModulesHandler(t *testing.T) {
+	api := &Api{}
+	rec := httptest.NewRecorder()
+	api.ModulesHandler(rec, httptest.NewRequest("GET", "/modules", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, modulesCacheControl, rec.Header().Get("Cache-Control"))
+
+	var resp struct {
+		Modules []models.SyntheaModule `json:"modules"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.Modules)
+	for _, module := range resp.Modules {
+		assert.NotEmpty(t, module.Label, module.Name)
+		assert.NotEmpty(t, module.Category, module.Name)
+		assert.True(t, models.IsKnownModule(module.Name))
+	}
+}
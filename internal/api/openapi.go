@@ -0,0 +1,47 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec describes the public and authenticated API routes. Update it
+// alongside setupRoutes; TestOpenAPISpecCoversProtectedRoutes fails when a
+// protected route is missing.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// swaggerUIPage loads Swagger UI from the CDN and points it at the spec served
+// next to it, so it works both directly and through the portal's /swagger/
+// proxy
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>MediSynth API</title>
+  <link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// OpenAPIHandler serves the OpenAPI 3 description of the API
+func (api *Api) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPISpec)
+}
+
+// SwaggerUIHandler serves the interactive documentation for openAPISpec
+func (api *Api) SwaggerUIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(swaggerUIPage))
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type openAPIDocument struct {
+	OpenAPI string                                `json:"openapi"`
+	Paths   map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func TestOpenAPISpecCoversProtectedRoutes(t *testing.T) {
+	var spec openAPIDocument
+	require.NoError(t, json.Unmarshal(openAPISpec, &spec), "openapi.json must be valid JSON")
+	assert.True(t, strings.HasPrefix(spec.OpenAPI, "3."))
+
+	api, err := NewApi(config.Config{APIPort: 8081}, newTestBackend(t))
+	require.NoError(t, err)
+
+	var protected int
+	err = chi.Walk(api.Router, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if route == "/docs" || strings.HasPrefix(route, "/swagger/") {
+			return nil
+		}
+		// A protected route rejects a request without credentials before
+		// reaching its handler
+		rec := httptest.NewRecorder()
+		api.Router.ServeHTTP(rec, httptest.NewRequest(method, route, nil))
+		if rec.Code != http.StatusUnauthorized {
+			return nil
+		}
+
+		protected++
+		operations, ok := spec.Paths[route]
+		if assert.True(t, ok, "openapi.json has no path %s", route) {
+			assert.Contains(t, operations, strings.ToLower(method), "openapi.json has no %s %s", method, route)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, protected)
+
+	for _, route := range []string{"/register", "/login"} {
+		assert.Contains(t, spec.Paths, route)
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	api, err := NewApi(config.Config{APIPort: 8081}, newTestBackend(t))
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	api.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code, "the spec is public")
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, string(openAPISpec), rec.Body.String())
+}
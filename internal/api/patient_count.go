@@ -0,0 +1,56 @@
+package api
+
+import (
+	"bytes"
+	"regexp"
+	"sync"
+)
+
+// syntheaPatientLine matches the line Synthea prints to stdout for every
+// generated patient, e.g. "12 -- Jane Doe (34 y/o F) Boston, Massachusetts"
+var syntheaPatientLine = regexp.MustCompile(`^\d+ -- .+ \(\d+ y/o [MF]\)`)
+
+// patientCounter is an io.Writer that counts generated patients in Synthea's
+// stdout. Unlike tailBuffer it sees the whole stream, so the count stays exact
+// for populations whose output exceeds maxJobLogSize.
+type patientCounter struct {
+	mu      sync.Mutex
+	partial []byte
+	count   int
+}
+
+func (c *patientCounter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.partial = append(c.partial, p...)
+	rest := c.partial
+	for {
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			break
+		}
+		c.countLine(rest[:i])
+		rest = rest[i+1:]
+	}
+	c.partial = append(c.partial[:0], rest...)
+	return len(p), nil
+}
+
+func (c *patientCounter) countLine(line []byte) {
+	if syntheaPatientLine.Match(bytes.TrimSpace(line)) {
+		c.count++
+	}
+}
+
+// Count returns the number of patients seen, including an unterminated last line
+func (c *patientCounter) Count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.partial) > 0 {
+		c.countLine(c.partial)
+		c.partial = nil
+	}
+	return c.count
+}
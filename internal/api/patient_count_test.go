@@ -0,0 +1,61 @@
+package api
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const simulatedSyntheaOutput = `Scanned 83 modules and 130 submodules.
+Running with options:
+Population: 5
+Seed: 1700000000000
+Location: Massachusetts
+Min Age: 0
+Max Age: 140
+1 -- Jane Doe (34 y/o F) Boston, Massachusetts
+2 -- John Roe (61 y/o M) Worcester, Massachusetts DECEASED
+3 -- Ana Poe (7 y/o F) Springfield, Massachusetts
+{alive=2, dead=1}
+`
+
+func TestPatientCounterCountsGeneratedPatients(t *testing.T) {
+	counter := &patientCounter{}
+
+	// exec.Cmd delivers output in arbitrary chunks, so split mid-line
+	out := strings.NewReader(simulatedSyntheaOutput)
+	buf := make([]byte, 7)
+	_, err := io.CopyBuffer(counter, struct{ io.Reader }{out}, buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, counter.Count())
+}
+
+func TestPublishJobOutputRecordsParsedPatientCount(t *testing.T) {
+	initTestDatabase(t)
+	api := newFakeS3Api(t, &fakeS3{sizes: map[string]int64{}})
+	job := createUploadTestJob(t)
+	job.Parameters = map[string]interface{}{"population": float64(5)}
+
+	counter := &patientCounter{}
+	io.WriteString(counter, simulatedSyntheaOutput)
+
+	dir := t.TempDir()
+	writeOutputFile(t, dir, "patient.json", "{}")
+	api.publishJobOutput(context.Background(), job, dir, []string{".json"}, 0, counter.Count())
+
+	stored, err := database.GetJobByID(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusCompleted, stored.Status)
+	if assert.NotNil(t, stored.PatientCount) {
+		assert.Equal(t, 3, *stored.PatientCount)
+	}
+	assert.NoFileExists(t, filepath.Join(dir, "patient.json"))
+}
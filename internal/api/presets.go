@@ -0,0 +1,179 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// presetRequest is the body accepted when creating or updating a preset
+type presetRequest struct {
+	Name       string               `json:"name"`
+	Parameters models.SyntheaParams `json:"parameters"`
+}
+
+func (api *Api) ListPresetsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
+		return
+	}
+
+	presets, err := api.database().GetPresetsForUser(r.Context(), userID)
+	if err != nil {
+		log.Printf("ERROR: Failed to list presets for user %s: %v", userID, err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to list presets")
+		return
+	}
+	if presets == nil {
+		presets = []*models.JobPreset{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(presets)
+}
+
+func (api *Api) CreatePresetHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
+		return
+	}
+
+	var req presetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err, "Invalid request payload")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeJSONError(w, http.StatusBadRequest, errCodeValidationFailed, "Preset name is required")
+		return
+	}
+	if err := req.Parameters.Validate(api.Config.MaxPopulation); err != nil {
+		writeValidationFailed(w, err)
+		return
+	}
+
+	preset := &models.JobPreset{
+		UserID:     &userID,
+		Name:       req.Name,
+		Parameters: req.Parameters,
+	}
+	if err := api.database().CreatePreset(r.Context(), preset); err != nil {
+		if database.IsUniqueViolation(err) {
+			writeJSONError(w, http.StatusConflict, errCodeConflict, "A preset with this name already exists")
+			return
+		}
+		log.Printf("ERROR: Failed to create preset for user %s: %v", userID, err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to create preset")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(preset)
+}
+
+func (api *Api) GetPresetHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
+		return
+	}
+
+	preset, err := api.database().GetPresetByID(r.Context(), chi.URLParam(r, "presetID"))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Preset not found")
+		return
+	}
+
+	if !preset.IsGlobal() && *preset.UserID != userID {
+		writeJSONError(w, http.StatusForbidden, errCodeForbidden, "Forbidden")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preset)
+}
+
+func (api *Api) UpdatePresetHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
+		return
+	}
+
+	var req presetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err, "Invalid request payload")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeJSONError(w, http.StatusBadRequest, errCodeValidationFailed, "Preset name is required")
+		return
+	}
+	if err := req.Parameters.Validate(api.Config.MaxPopulation); err != nil {
+		writeValidationFailed(w, err)
+		return
+	}
+
+	// Global presets are read-only through the API
+	preset := &models.JobPreset{
+		ID:         chi.URLParam(r, "presetID"),
+		UserID:     &userID,
+		Name:       req.Name,
+		Parameters: req.Parameters,
+	}
+	if err := api.database().UpdatePreset(r.Context(), userID, preset); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Preset not found")
+			return
+		}
+		if database.IsUniqueViolation(err) {
+			writeJSONError(w, http.StatusConflict, errCodeConflict, "A preset with this name already exists")
+			return
+		}
+		log.Printf("ERROR: Failed to update preset %s: %v", preset.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to update preset")
+		return
+	}
+
+	updated, err := api.database().GetPresetByID(r.Context(), preset.ID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to load updated preset")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+func (api *Api) DeletePresetHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized: User ID not found in token")
+		return
+	}
+
+	if err := api.database().DeletePreset(r.Context(), userID, chi.URLParam(r, "presetID")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Preset not found")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to delete preset")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// presetRouter mounts the preset handlers with userID injected into the context
+func presetRouter(api *Api, userID string) http.Handler {
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "userID", userID)))
+		})
+	})
+	r.Get("/presets", api.ListPresetsHandler)
+	r.Post("/presets", api.CreatePresetHandler)
+	r.Get("/presets/{presetID}", api.GetPresetHandler)
+	r.Put("/presets/{presetID}", api.UpdatePresetHandler)
+	r.Delete("/presets/{presetID}", api.DeletePresetHandler)
+	return r
+}
+
+func TestPresetCRUD(t *testing.T) {
+	initTestDatabase(t)
+	api := &Api{}
+
+	owner, err := database.CreateUser(fmt.Sprintf("presets-%d@example.com", time.Now().UnixNano()), "password")
+	assert.NoError(t, err)
+	other, err := database.CreateUser(fmt.Sprintf("presets-other-%d@example.com", time.Now().UnixNano()), "password")
+	assert.NoError(t, err)
+
+	population := 1000
+	state := "MA"
+	global := &models.JobPreset{Name: fmt.Sprintf("Global cohort %d", time.Now().UnixNano()), Parameters: models.SyntheaParams{Population: &population, State: &state}}
+	assert.NoError(t, database.CreatePreset(global))
+
+	router := presetRouter(api, owner.ID)
+
+	// Create
+	body := `{"name":"Diabetes cohort","parameters":{"population":1000,"state":"MA"}}`
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/presets", bytes.NewBufferString(body)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var created models.JobPreset
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.Equal(t, "Diabetes cohort", created.Name)
+	if assert.NotNil(t, created.Parameters.Population) {
+		assert.Equal(t, 1000, *created.Parameters.Population)
+	}
+
+	// Duplicate names are rejected
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/presets", bytes.NewBufferString(body)))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// Parameters a job would reject are rejected for presets too
+	for method, path := range map[string]string{"POST": "/presets", "PUT": "/presets/" + created.ID} {
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewBufferString(`{"name":"Invalid cohort","parameters":{"population":0,"gender":"X"}}`)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, method)
+		detail := decodeErrorBody(t, rec).Error
+		assert.Equal(t, errCodeValidationFailed, detail.Code, method)
+		assert.Contains(t, detail.Fields, "population", method)
+		assert.Contains(t, detail.Fields, "gender", method)
+	}
+
+	// List includes own and global presets
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/presets", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var listed []models.JobPreset
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	names := make([]string, 0, len(listed))
+	for _, p := range listed {
+		names = append(names, p.Name)
+	}
+	assert.Contains(t, names, "Diabetes cohort")
+	assert.Contains(t, names, global.Name)
+
+	// Update
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("PUT", "/presets/"+created.ID, bytes.NewBufferString(`{"name":"Diabetes cohort, TX","parameters":{"population":500,"state":"TX"}}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	stored, err := database.GetPresetByID(created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Diabetes cohort, TX", stored.Name)
+	assert.Equal(t, "TX", *stored.Parameters.State)
+
+	// Global presets are readable but not writable
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/presets/"+global.ID, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("DELETE", "/presets/"+global.ID, nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Other users can neither read nor delete the preset
+	otherRouter := presetRouter(api, other.ID)
+	rec = httptest.NewRecorder()
+	otherRouter.ServeHTTP(rec, httptest.NewRequest("GET", "/presets/"+created.ID, nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = httptest.NewRecorder()
+	otherRouter.ServeHTTP(rec, httptest.NewRequest("DELETE", "/presets/"+created.ID, nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Delete
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("DELETE", "/presets/"+created.ID, nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	_, err = database.GetPresetByID(created.ID)
+	assert.Error(t, err)
+}
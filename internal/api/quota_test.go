@@ -0,0 +1,93 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// quotaUser creates a user who has already generated used patients this month
+func quotaUser(t *testing.T, used int) *models.User {
+	t.Helper()
+	initTestDatabase(t)
+	user, err := database.CreateUser(fmt.Sprintf("quota-%d@example.com", time.Now().UnixNano()), "password")
+	require.NoError(t, err)
+
+	job := &models.Job{ID: database.GenerateID(), UserID: user.ID, JobID: database.GenerateID(), Status: models.JobStatusPending, OutputFormat: "fhir"}
+	require.NoError(t, job.MarshalParameters())
+	require.NoError(t, database.CreateJob(job))
+	require.NoError(t, database.UpdateJobStatus(job.ID, models.JobStatusCompleted, nil, nil, nil, &used))
+	return user
+}
+
+func generate(cfg config.Config, userID string, population int) *httptest.ResponseRecorder {
+	api := &Api{Config: cfg, jobQueue: make(chan *models.Job, 1)}
+	body := fmt.Sprintf(`{"population":%d}`, population)
+	req := httptest.NewRequest(http.MethodPost, "/generate-patients", bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
+	rec := httptest.NewRecorder()
+	api.RunSyntheaGeneration(rec, req)
+	return rec
+}
+
+func TestRunSyntheaGenerationQuota(t *testing.T) {
+	cfg := config.Config{MonthlyPatientQuota: 100}
+
+	t.Run("under quota", func(t *testing.T) {
+		user := quotaUser(t, 60)
+		assert.Equal(t, http.StatusAccepted, generate(cfg, user.ID, 40).Code, "a job may use up the quota exactly")
+	})
+
+	t.Run("would exceed quota", func(t *testing.T) {
+		user := quotaUser(t, 60)
+		rec := generate(cfg, user.ID, 41)
+		require.Equal(t, http.StatusTooManyRequests, rec.Code)
+		body := decodeErrorBody(t, rec)
+		assert.Equal(t, errCodeQuotaExceeded, body.Error.Code)
+		assert.Contains(t, body.Error.Message, "60 of 100 patients used, 40 remaining")
+	})
+
+	t.Run("at quota", func(t *testing.T) {
+		user := quotaUser(t, 100)
+		rec := generate(cfg, user.ID, 1)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, errCodeQuotaExceeded, decodeErrorBody(t, rec).Error.Code)
+	})
+
+	t.Run("exempt email bypasses quota", func(t *testing.T) {
+		user := quotaUser(t, 100)
+		exempt := cfg
+		exempt.QuotaExemptEmails = "someone@example.com, " + user.Email
+		assert.Equal(t, http.StatusAccepted, generate(exempt, user.ID, 50).Code)
+	})
+
+	t.Run("paid tier bypasses quota", func(t *testing.T) {
+		user := quotaUser(t, 100)
+		require.NoError(t, database.SetUserTier(user.ID, models.TierPro))
+		assert.Equal(t, http.StatusAccepted, generate(cfg, user.ID, 50).Code)
+	})
+
+	t.Run("pending jobs reserve quota", func(t *testing.T) {
+		user := quotaUser(t, 60)
+		require.Equal(t, http.StatusAccepted, generate(cfg, user.ID, 30).Code)
+		rec := generate(cfg, user.ID, 20)
+		require.Equal(t, http.StatusTooManyRequests, rec.Code, "the first job is still pending but its patients count")
+		assert.Contains(t, decodeErrorBody(t, rec).Error.Message, "60 of 100 patients used, 30 reserved by unfinished jobs, 10 remaining")
+		assert.Equal(t, http.StatusAccepted, generate(cfg, user.ID, 10).Code)
+	})
+
+	t.Run("zero disables quota", func(t *testing.T) {
+		user := quotaUser(t, 100)
+		assert.Equal(t, http.StatusAccepted, generate(config.Config{}, user.ID, 50).Code)
+	})
+}
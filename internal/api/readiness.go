@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// checkDatabase pings the database connection
+func (api *Api) checkDatabase(ctx context.Context) error {
+	db := api.database()
+	if db == nil {
+		return errors.New("database connection not initialized")
+	}
+	return db.Conn().PingContext(ctx)
+}
+
+// checkStorage confirms the output storage is reachable
+func (api *Api) checkStorage(ctx context.Context) error {
+	if api.Storage == nil {
+		return errors.New("storage backend not initialized")
+	}
+	return api.Storage.Ping(ctx)
+}
+
+// Readyz reports whether the API can serve traffic. Unlike /heartbeat, which
+// is a pure liveness check, it verifies the database and storage are reachable.
+func (api *Api) Readyz(w http.ResponseWriter, r *http.Request) {
+	timeout := time.Duration(api.Config.ReadinessTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	checks := map[string]func(context.Context) error{
+		"database": api.checkDatabase,
+		"storage":  api.checkStorage,
+	}
+
+	results := make(map[string]string, len(checks))
+	var failing []string
+	for name, check := range checks {
+		if err := check(ctx); err != nil {
+			results[name] = err.Error()
+			failing = append(failing, name)
+			continue
+		}
+		results[name] = "ok"
+	}
+
+	var stats sql.DBStats
+	if db := api.database(); db != nil {
+		stats = db.Stats()
+	}
+	status := http.StatusOK
+	resp := map[string]interface{}{
+		"status": "ok",
+		"checks": results,
+		"database_pool": map[string]interface{}{
+			"max_open_connections": stats.MaxOpenConnections,
+			"open_connections":     stats.OpenConnections,
+			"in_use":               stats.InUse,
+			"idle":                 stats.Idle,
+			"wait_count":           stats.WaitCount,
+			"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+		},
+	}
+	if len(failing) > 0 {
+		sort.Strings(failing)
+		status = http.StatusServiceUnavailable
+		resp["status"] = "unavailable"
+		resp["failing"] = failing
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadyzReportsUnavailableDatabase(t *testing.T) {
+	db := openTestDatabase(t)
+	require.NoError(t, db.Close())
+
+	api := &Api{Config: config.Config{ReadinessTimeoutSeconds: 1}, DB: db}
+	rec := httptest.NewRecorder()
+	api.Readyz(rec, httptest.NewRequest("GET", "/readyz", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	var resp struct {
+		Status  string            `json:"status"`
+		Failing []string          `json:"failing"`
+		Checks  map[string]string `json:"checks"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "unavailable", resp.Status)
+	assert.Contains(t, resp.Failing, "database")
+	assert.NotEqual(t, "ok", resp.Checks["database"])
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/auth"
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterHandlerValidation(t *testing.T) {
+	initTestDatabase(t)
+	auth.SetStore(store.New())
+
+	tests := []struct {
+		name  string
+		body  string
+		field string
+		want  string
+	}{
+		{"invalid email", `{"email":"not-an-email","password":"Password1!"}`, "email", "must be a valid email address"},
+		{"weak password", `{"email":"weak@example.com","password":"password"}`, "password", "needs an uppercase letter, needs a number, needs a symbol"},
+		{"short password", `{"email":"short@example.com","password":"Pa1!"}`, "password", "too short (at least 8 characters)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			(&Api{}).RegisterHandler(rec, req)
+
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			var errBody errorBody
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &errBody))
+			assert.Equal(t, errCodeValidationFailed, errBody.Error.Code)
+			assert.Equal(t, tt.want, errBody.Error.Fields[tt.field])
+		})
+	}
+}
+
+func TestRegisterAndLoginShareOneStore(t *testing.T) {
+	initTestDatabase(t)
+	auth.SetStore(store.New())
+	email := fmt.Sprintf("single-path-%d@example.com", time.Now().UnixNano())
+
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(fmt.Sprintf(`{"email":%q,"password":"Password1!"}`, email)))
+	rec := httptest.NewRecorder()
+	(&Api{}).RegisterHandler(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var registered struct {
+		ID string `json:"id"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &registered))
+
+	stored, err := database.GetUserByEmail(email)
+	require.NoError(t, err, "registration writes to the database package's users table")
+	assert.Equal(t, registered.ID, stored.ID)
+	assert.NotEqual(t, "Password1!", stored.Password)
+
+	req = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(fmt.Sprintf(`{"email":%q,"password":"Password1!"}`, strings.ToUpper(email))))
+	rec = httptest.NewRecorder()
+	(&Api{}).LoginHandler(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var loggedIn struct {
+		ID string `json:"id"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &loggedIn))
+	assert.Equal(t, registered.ID, loggedIn.ID)
+
+	req = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(fmt.Sprintf(`{"email":%q,"password":"wrong"}`, email)))
+	rec = httptest.NewRecorder()
+	(&Api{}).LoginHandler(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
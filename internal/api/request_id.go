@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// echoRequestID returns the ID middleware.RequestID assigned to the request
+// in the X-Request-Id response header, so clients can quote it when reporting
+// a failure. Error bodies and the access log carry the same ID.
+func echoRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := middleware.GetReqID(r.Context()); id != "" {
+			w.Header().Set(middleware.RequestIDHeader, id)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestID returns the ID of the request that created the job, or nil when
+// the request has none
+func requestID(r *http.Request) *string {
+	id := middleware.GetReqID(r.Context())
+	if id == "" {
+		return nil
+	}
+	return &id
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIDIsEchoedAndLogged(t *testing.T) {
+	api, err := NewApi(config.Config{APIPort: 8081}, newTestBackend(t))
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	rec := httptest.NewRecorder()
+	api.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs", nil))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	id := rec.Header().Get("X-Request-Id")
+	require.NotEmpty(t, id)
+	var body errorBody
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, id, body.Error.RequestID, "error bodies carry the request ID")
+	assert.Contains(t, logs.String(), "Request ID: "+id, "the access log carries the request ID")
+
+	req := httptest.NewRequest(http.MethodGet, "/heartbeat", nil)
+	req.Header.Set("X-Request-Id", "client-chosen-id")
+	rec = httptest.NewRecorder()
+	api.Router.ServeHTTP(rec, req)
+	assert.Equal(t, "client-chosen-id", rec.Header().Get("X-Request-Id"), "an ID sent by the client is kept")
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/MediSynth-io/medisynth/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestBackend returns filesystem storage in a temporary directory
+func newTestBackend(t *testing.T) *storage.FilesystemBackend {
+	t.Helper()
+	backend, err := storage.NewFilesystemBackend(t.TempDir(), "http://api.test"+storage.FilesystemURLPath)
+	require.NoError(t, err)
+	return backend
+}
+
+func TestJobFlowWithFilesystemStorage(t *testing.T) {
+	initTestDatabase(t)
+	backend := newTestBackend(t)
+	api, err := NewApi(config.Config{APIPort: 8081}, backend)
+	require.NoError(t, err)
+	job := createUploadTestJob(t)
+
+	dir := t.TempDir()
+	writeOutputFile(t, dir, "patient.json", `{"resourceType":"Patient"}`)
+	writeOutputFile(t, dir, "notes.txt", "not an output")
+	api.publishJobOutput(context.Background(), job, dir, []string{".json"}, 0, 1)
+
+	stored, err := database.GetJobByID(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.JobStatusCompleted, stored.Status)
+
+	output := newTailBuffer(maxJobLogSize)
+	fmt.Fprint(output, "Synthea finished")
+	require.NoError(t, api.uploadJobLog(stored, output))
+
+	r := chi.NewRouter()
+	r.Get("/jobs/{jobID}/files", api.ListJobFilesHandler)
+	r.Get("/jobs/{jobID}/logs", api.GetJobLogsHandler)
+	r.Delete("/jobs/{jobID}", api.DeleteJobHandler)
+	call := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, asUser(httptest.NewRequest(method, path, nil), job.UserID))
+		return rec
+	}
+	download := func(link string) *httptest.ResponseRecorder {
+		u, err := url.Parse(link)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		api.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, u.RequestURI(), nil))
+		return rec
+	}
+
+	rec := call(http.MethodGet, "/jobs/"+job.ID+"/files")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, string(models.JobStatusCompleted), rec.Header().Get("X-Job-Status"))
+	assert.Equal(t, "true", rec.Header().Get("X-Job-Files-Complete"))
+	var files []models.JobFile
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&files), "the listing is a plain array of files")
+	var patient *models.JobFile
+	for i, file := range files {
+		assert.NotEqual(t, "notes.txt", file.Filename, "disallowed files are not uploaded")
+		assert.NotEqual(t, storage.JobLogFilename, file.Filename, "the job log is not an output file")
+		if file.Filename == "patient.json" {
+			patient = &files[i]
+		}
+	}
+	require.NotNil(t, patient, "got %+v", files)
+	assert.Equal(t, int64(len(`{"resourceType":"Patient"}`)), patient.Size)
+
+	object, err := backend.Stat(context.Background(), patient.S3Key)
+	require.NoError(t, err)
+	assert.Equal(t, job.ID, object.Metadata[storage.MetadataJobID])
+	assert.Equal(t, job.UserID, object.Metadata[storage.MetadataUserID])
+
+	rec = download(patient.URL)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"resourceType":"Patient"}`, rec.Body.String())
+	assert.Equal(t, `attachment; filename=patient.json`, rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	rec = download(patient.URL + "x")
+	assert.Equal(t, http.StatusForbidden, rec.Code, "a tampered link is refused")
+
+	rec = call(http.MethodGet, "/jobs/"+job.ID+"/logs")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Synthea finished")
+
+	rec = call(http.MethodDelete, "/jobs/"+job.ID)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	objects, err := backend.List(context.Background(), jobS3Prefix(job))
+	require.NoError(t, err)
+	assert.Empty(t, objects, "the job's output is deleted")
+
+	rec = download(patient.URL)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
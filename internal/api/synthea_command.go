@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// CommandRunner runs a command to completion, streaming its output to stdout
+// and stderr. Jobs start Synthea through Api.Runner so tests can replace the
+// real process with a fake.
+type CommandRunner interface {
+	Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error
+}
+
+// ExecRunner runs commands as child processes, killed when ctx is done
+type ExecRunner struct{}
+
+func (ExecRunner) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// commandRunner returns Runner, or ExecRunner when none is set
+func (api *Api) commandRunner() CommandRunner {
+	if api.Runner == nil {
+		return ExecRunner{}
+	}
+	return api.Runner
+}
+
+// lookPath resolves executables; tests replace it to avoid depending on the host
+var lookPath = exec.LookPath
+
+// javaCommand runs Synthea when SYNTHEA_JAR_PATH is set
+const javaCommand = "java"
+
+// allowedSyntheaExtraArgPrefixes lists the Synthea settings that
+// SYNTHEA_EXTRA_ARGS may override. Exporter and output settings are owned by
+// the job runner and cannot be changed this way.
+var allowedSyntheaExtraArgPrefixes = []string{"--generate."}
+
+// isAllowedSyntheaExtraArg reports whether arg is a --key=value override of an
+// allowlisted Synthea setting
+func isAllowedSyntheaExtraArg(arg string) bool {
+	if !strings.Contains(arg, "=") {
+		return false
+	}
+	for _, prefix := range allowedSyntheaExtraArgPrefixes {
+		if strings.HasPrefix(arg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// syntheaExtraArgs returns the allowlisted SYNTHEA_EXTRA_ARGS, logging and
+// dropping anything else
+func (api *Api) syntheaExtraArgs() []string {
+	var args []string
+	for _, arg := range api.Config.SyntheaExtraArgList() {
+		if !isAllowedSyntheaExtraArg(arg) {
+			log.Printf("WARNING: Ignoring Synthea extra argument %q: only --key=value for %v is allowed", arg, allowedSyntheaExtraArgPrefixes)
+			continue
+		}
+		args = append(args, arg)
+	}
+	return args
+}
+
+// syntheaInvocation returns the program and arguments that run Synthea with
+// the given job arguments: java -jar when SYNTHEA_JAR_PATH is set, otherwise
+// SYNTHEA_COMMAND (a wrapper script or the synthea binary).
+func (api *Api) syntheaInvocation(jobArgs []string) (string, []string) {
+	args := append(api.syntheaExtraArgs(), jobArgs...)
+	if jar := api.Config.SyntheaJarPath; jar != "" {
+		return javaCommand, append([]string{"-jar", jar}, args...)
+	}
+	return api.Config.SyntheaCommandName(), args
+}
+
+// checkSyntheaCommand verifies that the configured Synthea invocation can be
+// started, so a broken deployment is reported at startup instead of on the
+// first job
+func (api *Api) checkSyntheaCommand() error {
+	if jar := api.Config.SyntheaJarPath; jar != "" {
+		if _, err := os.Stat(jar); err != nil {
+			return fmt.Errorf("SYNTHEA_JAR_PATH %s is not readable: %w", jar, err)
+		}
+		if _, err := lookPath(javaCommand); err != nil {
+			return fmt.Errorf("SYNTHEA_JAR_PATH is set but %s was not found: %w", javaCommand, err)
+		}
+		return nil
+	}
+
+	command := api.Config.SyntheaCommandName()
+	if _, err := lookPath(command); err != nil {
+		return fmt.Errorf("SYNTHEA_COMMAND %s was not found: %w", command, err)
+	}
+	return nil
+}
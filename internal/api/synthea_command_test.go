@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubLookPath makes only the given commands resolvable for the duration of the test
+func stubLookPath(t *testing.T, found ...string) {
+	t.Helper()
+	original := lookPath
+	t.Cleanup(func() { lookPath = original })
+
+	lookPath = func(file string) (string, error) {
+		for _, name := range found {
+			if name == file {
+				return "/usr/bin/" + file, nil
+			}
+		}
+		return "", errors.New("executable file not found in $PATH")
+	}
+}
+
+func TestSyntheaInvocation(t *testing.T) {
+	jobArgs := []string{"-p", "10"}
+
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		wantProg string
+		wantArgs []string
+	}{
+		{
+			name:     "default command",
+			cfg:      config.Config{},
+			wantProg: "synthea",
+			wantArgs: []string{"-p", "10"},
+		},
+		{
+			name:     "wrapper script",
+			cfg:      config.Config{SyntheaCommand: "/opt/synthea/run_synthea"},
+			wantProg: "/opt/synthea/run_synthea",
+			wantArgs: []string{"-p", "10"},
+		},
+		{
+			name:     "jar takes precedence",
+			cfg:      config.Config{SyntheaCommand: "synthea", SyntheaJarPath: "/opt/synthea.jar"},
+			wantProg: "java",
+			wantArgs: []string{"-jar", "/opt/synthea.jar", "-p", "10"},
+		},
+		{
+			name:     "extra args are allowlisted",
+			cfg:      config.Config{SyntheaExtraArgs: "--generate.only_alive_patients=true, --exporter.base_directory=/tmp, -s"},
+			wantProg: "synthea",
+			wantArgs: []string{"--generate.only_alive_patients=true", "-p", "10"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &Api{Config: tt.cfg}
+			prog, args := api.syntheaInvocation(jobArgs)
+			assert.Equal(t, tt.wantProg, prog)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+func TestCheckSyntheaCommand(t *testing.T) {
+	jar := filepath.Join(t.TempDir(), "synthea.jar")
+	assert.NoError(t, os.WriteFile(jar, nil, 0o644))
+
+	stubLookPath(t, "synthea", "java")
+	assert.NoError(t, (&Api{Config: config.Config{}}).checkSyntheaCommand())
+	assert.NoError(t, (&Api{Config: config.Config{SyntheaJarPath: jar}}).checkSyntheaCommand())
+	assert.Error(t, (&Api{Config: config.Config{SyntheaJarPath: jar + ".missing"}}).checkSyntheaCommand())
+	assert.Error(t, (&Api{Config: config.Config{SyntheaCommand: "run_synthea"}}).checkSyntheaCommand())
+
+	stubLookPath(t)
+	assert.Error(t, (&Api{Config: config.Config{SyntheaJarPath: jar}}).checkSyntheaCommand())
+}
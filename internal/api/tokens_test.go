@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/auth"
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/MediSynth-io/medisynth/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenFixture creates a user with the named tokens
+func tokenFixture(t *testing.T, names ...string) (*models.User, []*models.Token) {
+	t.Helper()
+	initTestDatabase(t)
+	auth.SetStore(store.New())
+
+	user, err := database.CreateUser(fmt.Sprintf("tokens-%d@example.com", time.Now().UnixNano()), "password")
+	require.NoError(t, err)
+	var tokens []*models.Token
+	for _, name := range names {
+		token, err := auth.CreateToken(user.ID, name)
+		require.NoError(t, err)
+		tokens = append(tokens, token)
+	}
+	return user, tokens
+}
+
+// asUser returns req authenticated as userID
+func asUser(req *http.Request, userID string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), "userID", userID))
+}
+
+func deletedCount(t *testing.T, rec *httptest.ResponseRecorder) int {
+	t.Helper()
+	var body struct {
+		Deleted int `json:"deleted"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return body.Deleted
+}
+
+func TestBulkDeleteTokensHandler(t *testing.T) {
+	owner, tokens := tokenFixture(t, "ci", "laptop", "kept")
+	other, otherTokens := tokenFixture(t, "foreign")
+
+	body := fmt.Sprintf(`{"ids":[%q,%q,%q]}`, tokens[0].ID, tokens[1].ID, otherTokens[0].ID)
+	req := asUser(httptest.NewRequest(http.MethodPost, "/tokens/bulk-delete", strings.NewReader(body)), owner.ID)
+	rec := httptest.NewRecorder()
+	(&Api{}).BulkDeleteTokensHandler(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 2, deletedCount(t, rec), "another user's token is not counted")
+
+	remaining, err := auth.ListTokens(owner.ID)
+	require.NoError(t, err)
+	if assert.Len(t, remaining, 1) {
+		assert.Equal(t, tokens[2].ID, remaining[0].ID)
+	}
+	otherRemaining, err := auth.ListTokens(other.ID)
+	require.NoError(t, err)
+	assert.Len(t, otherRemaining, 1, "tokens of other users must not be deleted")
+}
+
+func TestBulkDeleteTokensHandlerValidation(t *testing.T) {
+	owner, _ := tokenFixture(t)
+	tooMany := make([]string, maxBulkTokenIDs+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("id-%d", i)
+	}
+	tooManyBody, _ := json.Marshal(map[string][]string{"ids": tooMany})
+
+	for name, body := range map[string]string{
+		"empty list":   `{"ids":[]}`,
+		"missing list": `{}`,
+		"too many ids": string(tooManyBody),
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := asUser(httptest.NewRequest(http.MethodPost, "/tokens/bulk-delete", strings.NewReader(body)), owner.ID)
+			rec := httptest.NewRecorder()
+			(&Api{}).BulkDeleteTokensHandler(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			var errBody errorBody
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &errBody))
+			assert.Equal(t, errCodeValidationFailed, errBody.Error.Code)
+			assert.Contains(t, errBody.Error.Fields, "ids")
+		})
+	}
+}
+
+func TestDeleteAllTokensHandler(t *testing.T) {
+	owner, _ := tokenFixture(t, "ci", "laptop")
+	other, _ := tokenFixture(t, "foreign")
+
+	req := asUser(httptest.NewRequest(http.MethodDelete, "/tokens", nil), owner.ID)
+	rec := httptest.NewRecorder()
+	(&Api{}).DeleteAllTokensHandler(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 2, deletedCount(t, rec))
+
+	remaining, err := auth.ListTokens(owner.ID)
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+	otherRemaining, err := auth.ListTokens(other.ID)
+	require.NoError(t, err)
+	assert.Len(t, otherRemaining, 1)
+}
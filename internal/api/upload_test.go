@@ -0,0 +1,328 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/MediSynth-io/medisynth/internal/s3"
+	"github.com/MediSynth-io/medisynth/internal/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsSDKs3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 accepts every PutObject and multipart upload and answers HeadObject
+// with the size of the local file plus sizeSkew, so a non-zero skew simulates
+// a truncated upload
+type fakeS3 struct {
+	// PutObject requests for keys ending in failKey are rejected, and those
+	// ending in hangKey wait until the client gives up
+	failKey string
+	hangKey string
+
+	mu        sync.Mutex
+	sizes     map[string]int64
+	metadata  map[string]map[string]string // x-amz-meta-* headers by path
+	puts      int
+	sizeSkew  int64
+	parts     map[string]int64 // uploaded bytes of unfinished multipart uploads
+	partPuts  int
+	completes int
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		if f.hangKey != "" && strings.HasSuffix(r.URL.Path, f.hangKey) {
+			// The server only notices the client leaving once the body is read
+			io.Copy(io.Discard, r.Body)
+			select {
+			case <-r.Context().Done():
+			case <-time.After(30 * time.Second):
+			}
+			return
+		}
+		if f.failKey != "" && strings.HasSuffix(r.URL.Path, f.failKey) {
+			io.Copy(io.Discard, r.Body)
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+			return
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.recordMetadata(r)
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><Key>%s</Key><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>`, r.URL.Path)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		f.partPuts++
+		if f.parts == nil {
+			f.parts = map[string]int64{}
+		}
+		f.parts[r.URL.Path] += uploadedSize(r)
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%s"`, query.Get("partNumber")))
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		io.Copy(io.Discard, r.Body)
+		f.completes++
+		f.sizes[r.URL.Path] = f.parts[r.URL.Path]
+		delete(f.parts, r.URL.Path)
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><Key>%s</Key><ETag>"etag"</ETag></CompleteMultipartUploadResult>`, r.URL.Path)
+	case r.Method == http.MethodPut:
+		f.puts++
+		f.recordMetadata(r)
+		f.sizes[r.URL.Path] = uploadedSize(r)
+		w.Header().Set("ETag", `"etag"`)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodHead:
+		size, ok := f.sizes[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for key, value := range f.metadata[r.URL.Path] {
+			w.Header().Set("X-Amz-Meta-"+key, value)
+		}
+		w.Header().Set("Content-Length", strconv.FormatInt(size+f.sizeSkew, 10))
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// recordMetadata keeps the object metadata r sets, keyed like S3 does with
+// the x-amz-meta- prefix removed and the rest lowercased
+func (f *fakeS3) recordMetadata(r *http.Request) {
+	metadata := map[string]string{}
+	for name := range r.Header {
+		if key, ok := strings.CutPrefix(strings.ToLower(name), "x-amz-meta-"); ok {
+			metadata[key] = r.Header.Get(name)
+		}
+	}
+	if f.metadata == nil {
+		f.metadata = map[string]map[string]string{}
+	}
+	f.metadata[r.URL.Path] = metadata
+}
+
+// uploadedSize drains r's body and returns the object bytes it carried,
+// excluding any aws-chunked framing
+func uploadedSize(r *http.Request) int64 {
+	io.Copy(io.Discard, r.Body)
+	size, _ := strconv.ParseInt(r.Header.Get("Content-Length"), 10, 64)
+	if decoded := r.Header.Get("X-Amz-Decoded-Content-Length"); decoded != "" {
+		size, _ = strconv.ParseInt(decoded, 10, 64)
+	}
+	return size
+}
+
+func newFakeS3Api(t *testing.T, fake *fakeS3) *Api {
+	t.Helper()
+	return newFakeS3ApiWithConfig(t, fake, config.Config{})
+}
+
+// newFakeS3ApiWithConfig is newFakeS3Api with cfg's multipart settings
+func newFakeS3ApiWithConfig(t *testing.T, fake *fakeS3, cfg config.Config) *Api {
+	t.Helper()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	client := awsSDKs3.New(awsSDKs3.Options{
+		BaseEndpoint: aws.String(srv.URL),
+		Region:       "us-east-1",
+		Credentials:  aws.AnonymousCredentials{},
+		UsePathStyle: true,
+	})
+	backend := storage.NewS3Backend(&s3.Client{Client: client, BucketName: "test-bucket"},
+		cfg.MultipartUploadThreshold(), cfg.MultipartUploadPartSize())
+	return &Api{Config: cfg, Storage: backend}
+}
+
+func writeOutputFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+}
+
+func createUploadTestJob(t *testing.T) *models.Job {
+	t.Helper()
+	user, err := database.CreateUser(fmt.Sprintf("upload-%d@example.com", time.Now().UnixNano()), "password")
+	require.NoError(t, err)
+
+	job := &models.Job{ID: database.GenerateID(), UserID: user.ID, JobID: database.GenerateID(), Status: models.JobStatusRunning, OutputFormat: "fhir", CreatedAt: time.Now()}
+	require.NoError(t, job.MarshalParameters())
+	require.NoError(t, database.CreateJob(job))
+	return job
+}
+
+func TestPublishJobOutputRecordsOutputSize(t *testing.T) {
+	initTestDatabase(t)
+	fake := &fakeS3{sizes: map[string]int64{}}
+	api := newFakeS3Api(t, fake)
+	job := createUploadTestJob(t)
+
+	dir := t.TempDir()
+	writeOutputFile(t, dir, "patient.json", `{"resourceType":"Patient"}`)
+	writeOutputFile(t, dir, "notes.txt", "skipped")
+
+	api.publishJobOutput(context.Background(), job, dir, []string{".json"}, 100, 1)
+
+	stored, err := database.GetJobByID(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusCompleted, stored.Status)
+	if assert.NotNil(t, stored.OutputSize) {
+		assert.Equal(t, int64(100+len(`{"resourceType":"Patient"}`)), *stored.OutputSize)
+	}
+	assert.Equal(t, 1, fake.puts)
+	assert.NoFileExists(t, filepath.Join(dir, "patient.json"))
+}
+
+func TestPublishJobOutputTagsObjectsWithJob(t *testing.T) {
+	initTestDatabase(t)
+	fake := &fakeS3{sizes: map[string]int64{}}
+	api := newFakeS3ApiWithConfig(t, fake, config.Config{
+		MultipartUploadThresholdBytes: s3.MinPartSize,
+		MultipartUploadPartSizeBytes:  s3.MinPartSize,
+	})
+	job := createUploadTestJob(t)
+
+	dir := t.TempDir()
+	writeOutputFile(t, dir, "patient.json", `{"resourceType":"Patient"}`)
+	writeOutputFile(t, dir, "bulk.ndjson", strings.Repeat("x", s3.MinPartSize+1))
+
+	api.publishJobOutput(context.Background(), job, dir, []string{".json", ".ndjson"}, 0, 1)
+
+	want := map[string]string{"user-id": job.UserID, "job-id": job.ID, "format": "fhir"}
+	prefix := "/test-bucket/" + jobS3Prefix(job) + "fhir/"
+	assert.Equal(t, want, fake.metadata[prefix+"patient.json"])
+	assert.Equal(t, want, fake.metadata[prefix+"bulk.ndjson"], "multipart uploads are tagged too")
+
+	object, err := api.Storage.Stat(context.Background(), jobS3Prefix(job)+"fhir/patient.json")
+	require.NoError(t, err)
+	assert.Equal(t, want, object.Metadata, "the metadata can be read back")
+}
+
+func TestPublishJobOutputFailsOnSizeMismatch(t *testing.T) {
+	initTestDatabase(t)
+	fake := &fakeS3{sizes: map[string]int64{}, sizeSkew: -1}
+	api := newFakeS3Api(t, fake)
+	job := createUploadTestJob(t)
+
+	dir := t.TempDir()
+	writeOutputFile(t, dir, "patient.json", `{"resourceType":"Patient"}`)
+
+	api.publishJobOutput(context.Background(), job, dir, []string{".json"}, 0, 1)
+
+	stored, err := database.GetJobByID(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusFailed, stored.Status)
+	if assert.NotNil(t, stored.ErrorMessage) {
+		assert.Contains(t, *stored.ErrorMessage, errUploadSizeMismatch.Error())
+	}
+	assert.Equal(t, 2, fake.puts, "a size mismatch should be retried once")
+	assert.FileExists(t, filepath.Join(dir, "patient.json"))
+}
+
+func TestPublishJobOutputUsesMultipartForLargeFiles(t *testing.T) {
+	initTestDatabase(t)
+	fake := &fakeS3{sizes: map[string]int64{}}
+	api := newFakeS3ApiWithConfig(t, fake, config.Config{
+		MultipartUploadThresholdBytes: s3.MinPartSize,
+		MultipartUploadPartSizeBytes:  s3.MinPartSize,
+	})
+	job := createUploadTestJob(t)
+
+	dir := t.TempDir()
+	large := strings.Repeat(`{"resourceType":"Patient"}`+"\n", (2*s3.MinPartSize)/27+1)
+	writeOutputFile(t, dir, "bulk.ndjson", large)
+	writeOutputFile(t, dir, "patient.json", `{"resourceType":"Patient"}`)
+
+	api.publishJobOutput(context.Background(), job, dir, []string{".json", ".ndjson"}, 0, 1)
+
+	stored, err := database.GetJobByID(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusCompleted, stored.Status)
+	if assert.NotNil(t, stored.OutputSize) {
+		assert.Equal(t, int64(len(large)+len(`{"resourceType":"Patient"}`)), *stored.OutputSize)
+	}
+	assert.Equal(t, 3, fake.partPuts, "the large file is split into parts")
+	assert.Equal(t, 1, fake.completes)
+	assert.Equal(t, 1, fake.puts, "small files still use a single PutObject")
+}
+
+func TestUploadDirectoryToS3UploadsEveryFile(t *testing.T) {
+	fake := &fakeS3{sizes: map[string]int64{}}
+	api := newFakeS3Api(t, fake)
+	api.Config.MaxConcurrentUploads = 4
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0o755))
+	var total int64
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("patient-%02d.json", i)
+		if i%2 == 0 {
+			name = filepath.Join("nested", name)
+		}
+		content := strings.Repeat("x", i+1)
+		writeOutputFile(t, dir, name, content)
+		total += int64(len(content))
+	}
+
+	uploaded, err := api.uploadDirectoryToS3(context.Background(), dir, "synthea_output/job-1/fhir", nil, []string{".json"}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, total, uploaded)
+	assert.Equal(t, 20, fake.puts)
+	assert.Contains(t, fake.sizes, "/test-bucket/synthea_output/job-1/fhir/nested/patient-00.json", "keys keep the relative path")
+	assert.Contains(t, fake.sizes, "/test-bucket/synthea_output/job-1/fhir/patient-01.json")
+	remaining, err := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+	require.NoError(t, err)
+	assert.Empty(t, remaining, "uploaded files are removed locally")
+}
+
+func TestUploadDirectoryToS3CancelsBatchOnFailure(t *testing.T) {
+	fake := &fakeS3{sizes: map[string]int64{}, failKey: "b.json", hangKey: "a.json"}
+	api := newFakeS3Api(t, fake)
+	api.Config.MaxConcurrentUploads = 2
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.json", "b.json", "c.json", "d.json"} {
+		writeOutputFile(t, dir, name, name)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := api.uploadDirectoryToS3(context.Background(), dir, "synthea_output/job-1/fhir", nil, []string{".json"}, 0)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "AccessDenied")
+	case <-time.After(10 * time.Second):
+		t.Fatal("the hanging upload was not cancelled by the failure")
+	}
+	assert.Zero(t, fake.puts, "no file is uploaded once the batch is cancelled")
+	for _, name := range []string{"a.json", "b.json", "c.json", "d.json"} {
+		assert.FileExists(t, filepath.Join(dir, name), "files that were not uploaded stay for a retry")
+	}
+}
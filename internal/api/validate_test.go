@@ -0,0 +1,73 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/MediSynth-io/medisynth/internal/estimate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateGenerationParams(t *testing.T) {
+	initTestDatabase(t)
+	api := &Api{Config: config.Config{MaxPopulation: 500}}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantErrors []string
+	}{
+		{"valid", `{"population":100,"gender":"F","ageMin":20,"ageMax":40,"outputFormat":"csv"}`, http.StatusOK, nil},
+		{"missing population", `{"gender":"M"}`, http.StatusUnprocessableEntity, []string{"population"}},
+		{"several invalid fields", `{"population":0,"gender":"X","outputFormat":"pdf"}`, http.StatusUnprocessableEntity, []string{"population", "gender", "outputFormat"}},
+		{"inverted age range", `{"population":10,"ageMin":60,"ageMax":30}`, http.StatusUnprocessableEntity, []string{"ageMin"}},
+		{"above configured maximum", `{"population":501}`, http.StatusUnprocessableEntity, []string{"population"}},
+		{"malformed json", `{"population":`, http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			api.ValidateGenerationParams(rec, httptest.NewRequest("POST", "/generate-patients/validate", bytes.NewBufferString(tt.body)))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+
+			if tt.wantStatus == http.StatusBadRequest {
+				return
+			}
+
+			var resp struct {
+				Valid    bool               `json:"valid"`
+				Errors   map[string]string  `json:"errors"`
+				Estimate *estimate.Estimate `json:"estimate"`
+			}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, tt.wantErrors == nil, resp.Valid)
+			assert.Len(t, resp.Errors, len(tt.wantErrors))
+			for _, field := range tt.wantErrors {
+				assert.Contains(t, resp.Errors, field)
+			}
+			if resp.Valid && assert.NotNil(t, resp.Estimate, "valid parameters are estimated") {
+				assert.Positive(t, resp.Estimate.DurationSeconds)
+				assert.Positive(t, resp.Estimate.OutputBytes)
+			}
+		})
+	}
+}
+
+func TestRunSyntheaGenerationRejectsInvalidParams(t *testing.T) {
+	api := &Api{Config: config.Config{MaxPopulation: 500}}
+
+	for _, body := range []string{`{}`, `{"population":-1}`, `{"population":501}`, `{"population":10,"ageMin":50,"ageMax":10}`, `{"population":10,"gender":"X"}`, `{"population":10,"keepModules":["unknown"]}`} {
+		req := httptest.NewRequest("POST", "/generate-patients", bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), "userID", "user-1"))
+		rec := httptest.NewRecorder()
+		api.RunSyntheaGeneration(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
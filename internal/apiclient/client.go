@@ -0,0 +1,195 @@
+// Package apiclient calls the MediSynth API on behalf of a signed-in portal
+// user. Requests carry the user's session cookie, so the API authenticates
+// them exactly as it would the browser.
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/models"
+)
+
+// DefaultTimeout bounds every call made through a Client
+const DefaultTimeout = 30 * time.Second
+
+// SessionCookieName is the portal session cookie forwarded to the API
+const SessionCookieName = "session"
+
+// maxErrorBody caps how much of an error response is read when decoding it
+const maxErrorBody = 64 << 10
+
+// Error is returned when the API answers with a 4xx or 5xx status. Code,
+// Message and Fields come from the API's JSON error body when it has one.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Fields     map[string]string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("api: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("api: status %d: %s", e.StatusCode, e.Message)
+}
+
+// IsClientError reports whether the API rejected the request itself (4xx)
+// rather than failing to handle it
+func (e *Error) IsClientError() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500
+}
+
+// Client is a typed client for the internal API URL
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New returns a Client for the API at baseURL using DefaultTimeout
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// Do sends a JSON request to path on behalf of the user who made r. A non-nil
+// in is encoded as the request body and a non-nil out receives the decoded
+// response. Error statuses are returned as *Error.
+func (c *Client) Do(r *http.Request, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("api: encode request: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := c.newRequest(r.Context(), method, path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	forwardSession(r, req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("api: %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return parseError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("api: decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+// Forward replays r against the same path on the API, keeping its method,
+// query, headers and body. The caller must close the response body.
+func (c *Client) Forward(r *http.Request) (*http.Response, error) {
+	path := r.URL.Path
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
+	}
+
+	req, err := c.newRequest(r.Context(), r.Method, path, r.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.Header.Clone()
+	if _, err := req.Cookie(SessionCookieName); err != nil {
+		forwardSession(r, req)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("api: %s %s: %w", r.Method, path, err)
+	}
+	return resp, nil
+}
+
+// JobAccepted is the API's response to a new generation job
+type JobAccepted struct {
+	JobID               string           `json:"jobID"`
+	Status              models.JobStatus `json:"status"`
+	Message             string           `json:"message"`
+	StatusURL           string           `json:"statusUrl"`
+	QueueDepth          int              `json:"queueDepth"`
+	OutputRetentionDays int              `json:"outputRetentionDays,omitempty"`
+}
+
+// CreateJob starts a generation job with params for the user who made r
+func (c *Client) CreateJob(r *http.Request, params models.SyntheaParams) (*JobAccepted, error) {
+	var accepted JobAccepted
+	if err := c.Do(r, http.MethodPost, "/generate-patients", params, &accepted); err != nil {
+		return nil, err
+	}
+	return &accepted, nil
+}
+
+// DeleteJob deletes a finished job and its output for the user who made r
+func (c *Client) DeleteJob(r *http.Request, jobID string) error {
+	return c.Do(r, http.MethodDelete, "/jobs/"+url.PathEscape(jobID), nil, nil)
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("api: build %s %s: %w", method, path, err)
+	}
+	return req, nil
+}
+
+// forwardSession copies the portal session cookie from r onto req
+func forwardSession(r *http.Request, req *http.Request) {
+	if cookie, err := r.Cookie(SessionCookieName); err == nil {
+		req.AddCookie(cookie)
+	}
+}
+
+// parseError builds an *Error from an error response, falling back to the
+// status text when the body is not the API's JSON error shape
+func parseError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode}
+
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	var body struct {
+		Error struct {
+			Code    string            `json:"code"`
+			Message string            `json:"message"`
+			Fields  map[string]string `json:"fields"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(raw, &body) == nil && body.Error.Message != "" {
+		apiErr.Code = body.Error.Code
+		apiErr.Message = body.Error.Message
+		apiErr.Fields = body.Error.Fields
+		return apiErr
+	}
+
+	if text := strings.TrimSpace(string(raw)); text != "" && !strings.HasPrefix(text, "{") && !strings.HasPrefix(text, "<") {
+		apiErr.Message = text
+	} else {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
@@ -0,0 +1,118 @@
+package apiclient
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// portalRequest is an incoming portal request from a signed-in user
+func portalRequest(method, target string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	r.AddCookie(&http.Cookie{Name: SessionCookieName, Value: "session-token"})
+	return r
+}
+
+func TestCreateJob(t *testing.T) {
+	var got models.SyntheaParams
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/generate-patients", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		cookie, err := r.Cookie(SessionCookieName)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "session-token", cookie.Value)
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, `{"jobID":"job-1","status":"pending","statusUrl":"/generation-status/job-1","queueDepth":2}`)
+	}))
+	defer stub.Close()
+
+	population := 25
+	accepted, err := New(stub.URL+"/").CreateJob(portalRequest(http.MethodPost, "/jobs/new"), models.SyntheaParams{Population: &population})
+	require.NoError(t, err)
+	assert.Equal(t, "job-1", accepted.JobID)
+	assert.Equal(t, models.JobStatusPending, accepted.Status)
+	assert.Equal(t, 2, accepted.QueueDepth)
+	if assert.NotNil(t, got.Population) {
+		assert.Equal(t, 25, *got.Population)
+	}
+}
+
+func TestDoReturnsTypedErrors(t *testing.T) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/validation":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":{"code":"validation_failed","message":"population: must be at most 100","fields":{"population":"must be at most 100"}}}`)
+		case "/plain":
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, "<html>bad gateway</html>")
+		}
+	}))
+	defer stub.Close()
+	client := New(stub.URL)
+
+	err := client.Do(portalRequest(http.MethodGet, "/"), http.MethodGet, "/validation", nil, nil)
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, "validation_failed", apiErr.Code)
+	assert.Equal(t, "population: must be at most 100", apiErr.Message)
+	assert.Equal(t, map[string]string{"population": "must be at most 100"}, apiErr.Fields)
+	assert.True(t, apiErr.IsClientError())
+
+	err = client.Do(portalRequest(http.MethodGet, "/"), http.MethodGet, "/plain", nil, nil)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "Unauthorized", apiErr.Message)
+
+	err = client.Do(portalRequest(http.MethodGet, "/"), http.MethodGet, "/html", nil, nil)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	assert.Equal(t, "Bad Gateway", apiErr.Message)
+	assert.False(t, apiErr.IsClientError())
+}
+
+func TestDoUnreachableAPI(t *testing.T) {
+	stub := httptest.NewServer(http.NotFoundHandler())
+	stub.Close()
+
+	err := New(stub.URL).Do(portalRequest(http.MethodGet, "/"), http.MethodGet, "/health", nil, nil)
+	require.Error(t, err)
+	var apiErr *Error
+	assert.False(t, errors.As(err, &apiErr), "transport failures are not API errors")
+}
+
+func TestForward(t *testing.T) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/swagger/index.html", r.URL.Path)
+		assert.Equal(t, "v=1", r.URL.RawQuery)
+		assert.Equal(t, "text/html", r.Header.Get("Accept"))
+		assert.Len(t, r.Cookies(), 1, "the session cookie is sent once")
+		io.WriteString(w, "swagger")
+	}))
+	defer stub.Close()
+
+	r := portalRequest(http.MethodGet, "/swagger/index.html?v=1")
+	r.Header.Set("Accept", "text/html")
+	resp, err := New(stub.URL).Forward(r)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "swagger", string(body))
+}
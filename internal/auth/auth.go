@@ -4,34 +4,72 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"log"
+	"net/mail"
 	"strings"
 	"time"
 
 	"github.com/MediSynth-io/medisynth/internal/models"
 	"github.com/MediSynth-io/medisynth/internal/store"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var (
-	dataStore *store.Store
+	dataStore store.Interface
+
+	// passwordCost is the bcrypt cost RegisterUser hashes passwords with
+	passwordCost = bcrypt.DefaultCost
 )
 
-// SetStore sets the store for the auth package
-func SetStore(s *store.Store) {
+// ErrEmailAlreadyExists is returned by RegisterUser when the email is taken
+var ErrEmailAlreadyExists = errors.New("email already registered")
+
+// SetStore sets the store for the auth package: store.New() in the binaries,
+// or an in-memory storetest.Fake in tests
+func SetStore(s store.Interface) {
 	dataStore = s
 }
 
-// RegisterUser creates a new user
+// SetPasswordCost sets the bcrypt cost for new password hashes, normally from
+// config.PasswordHashCost. Tests lower it to bcrypt.MinCost to stay fast.
+func SetPasswordCost(cost int) {
+	passwordCost = cost
+}
+
+// currentStore returns the store set by SetStore, failing loudly when the
+// binary forgot to wire one in
+func currentStore() store.Interface {
+	if dataStore == nil {
+		panic("auth: SetStore must be called before using the auth package")
+	}
+	return dataStore
+}
+
+// RegisterUser creates a new user. The email is stored normalized.
 func RegisterUser(email, password string) (*models.User, error) {
+	email = NormalizeEmail(email)
+	exists, err := currentStore().UserExists(email)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrEmailAlreadyExists
+	}
+
 	// Create user with hashed password
-	user, err := models.NewUser(email, password)
+	user, err := models.NewUser(email, password, passwordCost)
 	if err != nil {
 		return nil, err
 	}
 
-	// Store user in database
-	user, err = dataStore.CreateUser(user.Email, user.Password)
+	// Store user in database. A concurrent registration can still win the
+	// race after the check above, so the constraint error maps to the same sentinel.
+	user, err = currentStore().CreateUser(user.Email, user.Password)
 	if err != nil {
+		if errors.Is(err, store.ErrDuplicateEmail) {
+			return nil, ErrEmailAlreadyExists
+		}
 		return nil, err
 	}
 
@@ -40,7 +78,7 @@ func RegisterUser(email, password string) (*models.User, error) {
 
 // ValidateUser validates user credentials
 func ValidateUser(email, password string) (*models.User, error) {
-	user, err := dataStore.GetUserByEmail(email)
+	user, err := currentStore().GetUserByEmail(NormalizeEmail(email))
 	if err != nil {
 		return nil, err
 	}
@@ -54,8 +92,7 @@ func ValidateUser(email, password string) (*models.User, error) {
 
 // CreateToken creates a new API token for a user
 func CreateToken(userID string, name string) (*models.Token, error) {
-	// Generate random token
-	tokenStr, err := generateRandomToken()
+	tokenStr, err := generateAPIKey()
 	if err != nil {
 		return nil, err
 	}
@@ -64,7 +101,7 @@ func CreateToken(userID string, name string) (*models.Token, error) {
 	expiresAt := time.Now().AddDate(1, 0, 0)
 
 	// Create token in database
-	token, err := dataStore.CreateToken(userID, name, tokenStr, &expiresAt)
+	token, err := currentStore().CreateToken(userID, name, tokenStr, &expiresAt)
 	if err != nil {
 		return nil, err
 	}
@@ -74,7 +111,7 @@ func CreateToken(userID string, name string) (*models.Token, error) {
 
 // ValidateToken validates an API token
 func ValidateToken(token string) (*models.Token, error) {
-	t, err := dataStore.GetTokenByValue(token)
+	t, err := currentStore().GetTokenByValue(token)
 	if err != nil {
 		return nil, err
 	}
@@ -89,12 +126,24 @@ func ValidateToken(token string) (*models.Token, error) {
 
 // DeleteToken deletes an API token
 func DeleteToken(userID string, tokenID string) error {
-	return dataStore.DeleteToken(userID, tokenID)
+	return currentStore().DeleteToken(userID, tokenID)
+}
+
+// DeleteUserTokens deletes all of a user's API tokens and returns how many
+// were removed
+func DeleteUserTokens(userID string) (int64, error) {
+	return currentStore().DeleteUserTokens(userID)
+}
+
+// DeleteTokensByIDs deletes the listed API tokens owned by the user and
+// returns how many were removed
+func DeleteTokensByIDs(userID string, ids []string) (int64, error) {
+	return currentStore().DeleteTokensByIDs(userID, ids)
 }
 
 // ListTokens lists all tokens for a user
 func ListTokens(userID string) ([]*models.Token, error) {
-	return dataStore.GetUserTokens(userID)
+	return currentStore().GetUserTokens(userID)
 }
 
 // generateRandomToken generates a random token string
@@ -107,34 +156,36 @@ func generateRandomToken() (string, error) {
 	return base64.URLEncoding.EncodeToString(tokenBytes), nil
 }
 
-// CreateSession creates a new session for a user
-func CreateSession(userID string) (string, error) {
+// CreateSession creates a new session for a user that lasts for duration.
+// It returns the token and the expiry stored with it so callers can set a
+// matching cookie.
+func CreateSession(userID string, duration time.Duration) (string, time.Time, error) {
 	log.Printf("[AUTH] Starting session creation for user: %s", userID)
 
 	token, err := generateRandomToken()
 	if err != nil {
 		log.Printf("[AUTH] Failed to generate random token for user %s: %v", userID, err)
-		return "", err
+		return "", time.Time{}, err
 	}
 	log.Printf("[AUTH] Generated token for user %s, token length: %d", userID, len(token))
 
-	expiresAt := time.Now().Add(24 * time.Hour)
+	expiresAt := time.Now().Add(duration)
 	log.Printf("[AUTH] Session will expire at: %v", expiresAt)
 
 	log.Printf("[AUTH] Calling dataStore.CreateSession for user %s", userID)
-	err = dataStore.CreateSession(userID, token, expiresAt)
+	err = currentStore().CreateSession(userID, token, expiresAt)
 	if err != nil {
 		log.Printf("[AUTH] dataStore.CreateSession failed for user %s: %v", userID, err)
-		return "", err
+		return "", time.Time{}, err
 	}
 
 	log.Printf("[AUTH] Session created successfully for user %s", userID)
-	return token, nil
+	return token, expiresAt, nil
 }
 
 // ValidateSession validates a session token and returns the user ID
 func ValidateSession(token string) (string, error) {
-	session, err := dataStore.ValidateSession(token)
+	session, err := currentStore().ValidateSession(token)
 	if err != nil {
 		return "", err
 	}
@@ -143,12 +194,12 @@ func ValidateSession(token string) (string, error) {
 
 // DeleteSession deletes a user's session
 func DeleteSession(token string) error {
-	return dataStore.DeleteSession(token)
+	return currentStore().DeleteSession(token)
 }
 
 // CleanupExpiredSessions removes expired session records from the database.
 func CleanupExpiredSessions() error {
-	return dataStore.CleanupExpiredSessions()
+	return currentStore().CleanupExpiredSessions()
 }
 
 // --- Validation Helpers ---
@@ -173,17 +224,17 @@ func GetPasswordRequirements() PasswordRequirements {
 	}
 }
 
-// ValidatePassword checks if a password meets the complexity requirements.
-func ValidatePassword(password string) bool {
+// PasswordProblems lists the requirements a password fails to meet, in the
+// order they are shown on the registration form. It is empty for an
+// acceptable password.
+func PasswordProblems(password string) []string {
+	reqs := GetPasswordRequirements()
 	var (
 		hasUpper  bool
 		hasLower  bool
 		hasNumber bool
 		hasSymbol bool
 	)
-	if len(password) < GetPasswordRequirements().MinLength {
-		return false
-	}
 	for _, char := range password {
 		switch {
 		case 'A' <= char && char <= 'Z':
@@ -196,11 +247,71 @@ func ValidatePassword(password string) bool {
 			hasSymbol = true
 		}
 	}
-	return hasUpper && hasLower && hasNumber && hasSymbol
+
+	var problems []string
+	if len(password) < reqs.MinLength {
+		problems = append(problems, fmt.Sprintf("too short (at least %d characters)", reqs.MinLength))
+	}
+	if reqs.HasUpper && !hasUpper {
+		problems = append(problems, "needs an uppercase letter")
+	}
+	if reqs.HasLower && !hasLower {
+		problems = append(problems, "needs a lowercase letter")
+	}
+	if reqs.HasNumber && !hasNumber {
+		problems = append(problems, "needs a number")
+	}
+	if reqs.HasSymbol && !hasSymbol {
+		problems = append(problems, "needs a symbol")
+	}
+	return problems
 }
 
-// ValidateEmail checks if an email has a valid format.
+// ValidatePassword checks if a password meets the complexity requirements.
+func ValidatePassword(password string) bool {
+	return len(PasswordProblems(password)) == 0
+}
+
+// maxEmailLength is the longest address SMTP can deliver to (RFC 5321)
+const maxEmailLength = 254
+
+// ValidateEmail checks if an email has a valid format: a bare RFC 5322
+// address, without a display name, whose domain is a plausible host name.
+// Single-label domains such as user@localhost are accepted.
 func ValidateEmail(email string) bool {
-	// A very basic email validation check
-	return strings.Contains(email, "@") && strings.Contains(email, ".")
+	if email == "" || len(email) > maxEmailLength {
+		return false
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || addr.Address != email {
+		return false
+	}
+	at := strings.LastIndex(email, "@")
+	return at > 0 && validDomain(email[at+1:])
+}
+
+// validDomain checks a host name: dot-separated labels of letters, digits and
+// hyphens, none empty, longer than 63 characters or starting or ending with a
+// hyphen
+func validDomain(domain string) bool {
+	if domain == "" || len(domain) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NormalizeEmail returns the form emails are stored and looked up in, so
+// User@Example.com and user@example.com are the same account
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
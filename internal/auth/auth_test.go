@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeStore backs every test in the package, so none of them need a database
+var fakeStore *storetest.Fake
+
+func TestMain(m *testing.M) {
+	fakeStore = storetest.NewFake()
+	SetStore(fakeStore)
+	SetPasswordCost(bcrypt.MinCost)
+	os.Exit(m.Run())
+}
+
+func uniqueEmail(prefix string) string {
+	return fmt.Sprintf("%s-%d@example.com", prefix, time.Now().UnixNano())
+}
+
+func TestRegisterUserRejectsExistingEmail(t *testing.T) {
+	email := uniqueEmail("existing")
+
+	_, err := RegisterUser(email, "Password1!")
+	assert.NoError(t, err)
+
+	exists, err := fakeStore.UserExists(email)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	_, err = RegisterUser(email, "Password1!")
+	assert.ErrorIs(t, err, ErrEmailAlreadyExists)
+}
+
+func TestRegisterUserConcurrentCollision(t *testing.T) {
+	email := uniqueEmail("race")
+
+	const attempts = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := RegisterUser(email, "Password1!")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, ErrEmailAlreadyExists):
+		default:
+			t.Errorf("unexpected registration error: %v", err)
+		}
+	}
+	assert.Equal(t, 1, succeeded, "exactly one registration should win")
+}
+
+func TestValidateUser(t *testing.T) {
+	email := uniqueEmail("validate")
+	registered, err := RegisterUser(email, "Password1!")
+	require.NoError(t, err)
+	assert.NotEqual(t, "Password1!", registered.Password, "passwords are stored hashed")
+
+	user, err := ValidateUser(email, "Password1!")
+	require.NoError(t, err)
+	assert.Equal(t, registered.ID, user.ID)
+
+	_, err = ValidateUser(email, "wrong")
+	assert.Error(t, err)
+	_, err = ValidateUser(uniqueEmail("unknown"), "Password1!")
+	assert.Error(t, err)
+}
+
+func TestValidateToken(t *testing.T) {
+	token, err := CreateToken("user-1", "ci")
+	require.NoError(t, err)
+	if assert.NotNil(t, token.ExpiresAt) {
+		assert.WithinDuration(t, time.Now().AddDate(1, 0, 0), *token.ExpiresAt, time.Minute)
+	}
+
+	valid, err := ValidateToken(token.Token)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", valid.UserID)
+
+	expiredAt := time.Now().Add(-time.Hour)
+	expired, err := fakeStore.CreateToken("user-1", "old", "expired-token", &expiredAt)
+	require.NoError(t, err)
+	_, err = ValidateToken(expired.Token)
+	assert.Error(t, err)
+
+	_, err = ValidateToken("unknown-token")
+	assert.Error(t, err)
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	token, expiresAt, err := CreateSession("user-2", time.Hour)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Minute)
+
+	userID, err := ValidateSession(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user-2", userID)
+
+	require.NoError(t, DeleteSession(token))
+	_, err = ValidateSession(token)
+	assert.Error(t, err)
+
+	expired, _, err := CreateSession("user-2", -time.Minute)
+	require.NoError(t, err)
+	_, err = ValidateSession(expired)
+	assert.Error(t, err, "expired sessions are rejected")
+}
+
+func TestRegisterUserUsesConfiguredCost(t *testing.T) {
+	SetPasswordCost(bcrypt.MinCost + 1)
+	defer SetPasswordCost(bcrypt.MinCost)
+
+	user, err := RegisterUser(uniqueEmail("cost"), "Password1!")
+	require.NoError(t, err)
+
+	cost, err := bcrypt.Cost([]byte(user.Password))
+	require.NoError(t, err)
+	assert.Equal(t, bcrypt.MinCost+1, cost)
+}
+
+func TestValidateEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		valid bool
+	}{
+		{"user@example.com", true},
+		{"first.last@example.co.uk", true},
+		{"user+tag@example.com", true},
+		{"User@Example.COM", true},
+		{"o'brien@example.com", true},
+		{"user@sub-domain.example.io", true},
+		{"user@localhost", true},
+		{"user@123.example.com", true},
+		{"", false},
+		{"plainaddress", false},
+		{"@example.com", false},
+		{"user@", false},
+		{"user@@example.com", false},
+		{"user@example..com", false},
+		{"user@.example.com", false},
+		{"user@example.com.", false},
+		{"user@-example.com", false},
+		{"user@example-.com", false},
+		{"user@exa_mple.com", false},
+		{"user name@example.com", false},
+		{"Bob <bob@example.com>", false},
+		{"<bob@example.com>", false},
+		{" user@example.com", false},
+		{"user@" + strings.Repeat("a", 64) + ".com", false},
+		{strings.Repeat("a", 250) + "@example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			assert.Equal(t, tt.valid, ValidateEmail(tt.email))
+		})
+	}
+}
+
+func TestRegisterUserNormalizesEmail(t *testing.T) {
+	email := uniqueEmail("normalize")
+	mixed := " " + strings.ToUpper(email[:1]) + email[1:len(email)-len("example.com")] + "Example.COM "
+
+	user, err := RegisterUser(mixed, "Password1!")
+	require.NoError(t, err)
+	assert.Equal(t, email, user.Email)
+
+	_, err = RegisterUser(email, "Password1!")
+	assert.ErrorIs(t, err, ErrEmailAlreadyExists, "addresses differing only in case are the same account")
+
+	_, err = ValidateUser(mixed, "Password1!")
+	assert.NoError(t, err, "login accepts any casing")
+}
+
+func TestValidateUserFindsLegacyMixedCaseEmail(t *testing.T) {
+	email := "Legacy-" + uniqueEmail("user")
+	hash, err := bcrypt.GenerateFromPassword([]byte("Password1!"), bcrypt.MinCost)
+	require.NoError(t, err)
+	_, err = fakeStore.CreateUser(email, string(hash))
+	require.NoError(t, err)
+
+	_, err = ValidateUser(email, "Password1!")
+	assert.NoError(t, err, "accounts stored before normalization can still sign in")
+}
+
+func TestPasswordProblems(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{"acceptable", "Password1!", nil},
+		{"too short", "Pa1!", []string{"too short (at least 8 characters)"}},
+		{"no uppercase", "password1!", []string{"needs an uppercase letter"}},
+		{"no lowercase", "PASSWORD1!", []string{"needs a lowercase letter"}},
+		{"no number", "Password!!", []string{"needs a number"}},
+		{"no symbol", "Password12", []string{"needs a symbol"}},
+		{"empty", "", []string{
+			"too short (at least 8 characters)",
+			"needs an uppercase letter",
+			"needs a lowercase letter",
+			"needs a number",
+			"needs a symbol",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, PasswordProblems(tt.password))
+			assert.Equal(t, tt.want == nil, ValidatePassword(tt.password))
+		})
+	}
+}
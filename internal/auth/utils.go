@@ -6,6 +6,10 @@ import (
 	"fmt"
 )
 
+// apiKeyPrefix marks API keys so they are recognizable in configs and logs
+// and can be picked up by secret scanners
+const apiKeyPrefix = "ms_"
+
 // generateAPIKey generates a secure random API key
 func generateAPIKey() (string, error) {
 	// Generate 32 random bytes
@@ -16,5 +20,5 @@ func generateAPIKey() (string, error) {
 
 	// Encode as base64 and add a prefix
 	key := base64.URLEncoding.EncodeToString(b)
-	return fmt.Sprintf("ms_%s", key), nil
+	return apiKeyPrefix + key, nil
 }
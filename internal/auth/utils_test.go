@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateAPIKeyIsUnique(t *testing.T) {
+	const n = 10000
+	seen := make(map[string]bool, n)
+	for i := 0; i < n; i++ {
+		key, err := generateAPIKey()
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(key, apiKeyPrefix), "key %q lacks the prefix", key)
+		require.False(t, seen[key], "duplicate key %q", key)
+		seen[key] = true
+
+		raw, err := base64.URLEncoding.DecodeString(strings.TrimPrefix(key, apiKeyPrefix))
+		require.NoError(t, err)
+		require.Len(t, raw, 32)
+	}
+}
+
+func TestCreateTokenUsesAPIKey(t *testing.T) {
+	first, err := CreateToken("api-key-user", "first")
+	require.NoError(t, err)
+	second, err := CreateToken("api-key-user", "second")
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(first.Token, apiKeyPrefix))
+	assert.NotEqual(t, first.Token, second.Token)
+
+	validated, err := ValidateToken(first.Token)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, validated.ID)
+}
@@ -0,0 +1,208 @@
+// Package compress provides HTTP response compression shared by the API and
+// portal routers.
+package compress
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultMinSize is the smallest response worth compressing; below it the
+// gzip framing costs more than it saves
+const DefaultMinSize = 1024
+
+// compressibleTypes are the media types Gzip compresses. Anything else,
+// including already-compressed formats such as images and archives, is sent
+// as is.
+var compressibleTypes = map[string]bool{
+	"application/json":       true,
+	"application/javascript": true,
+	"application/xml":        true,
+	"application/fhir+json":  true,
+	"image/svg+xml":          true,
+	"text/css":               true,
+	"text/csv":               true,
+	"text/html":              true,
+	"text/javascript":        true,
+	"text/plain":             true,
+	"text/xml":               true,
+}
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+// Gzip compresses responses for clients that accept gzip when the body is at
+// least minSize bytes and of a compressible type. Responses that already carry
+// a Content-Encoding, and ranges of a body, are left alone.
+func Gzip(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead || !acceptsGzip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize, status: http.StatusOK}
+			defer gw.close()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		// gzip;q=0 explicitly refuses it
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+	return false
+}
+
+// gzipResponseWriter buffers the start of a response until it knows whether
+// the body is large enough to compress, then either streams it through gzip
+// or writes it unchanged
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize int
+
+	status      int
+	wroteHeader bool // WriteHeader was called by the handler
+	decided     bool // The header has been sent to the client
+	buf         []byte
+	gz          *gzip.Writer
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader || w.decided {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	// Informational and bodiless responses are never compressed
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified {
+		w.commit(false)
+	}
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.minSize {
+			return len(p), nil
+		}
+		w.commit(w.compressible())
+		return len(p), w.flushBuffer()
+	}
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// compressible reports whether the buffered response should be gzipped.
+// Partial content is never compressed: its Content-Range counts bytes of the
+// uncompressed body, so a gzipped range could not be resumed.
+func (w *gzipResponseWriter) compressible() bool {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if w.status == http.StatusPartialContent || h.Get("Content-Range") != "" {
+		return false
+	}
+	contentType := h.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(w.buf)
+		h.Set("Content-Type", contentType)
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return compressibleTypes[strings.ToLower(strings.TrimSpace(mediaType))]
+}
+
+// commit sends the header, switching to gzip when compress is set
+func (w *gzipResponseWriter) commit(compress bool) {
+	w.decided = true
+	h := w.Header()
+	if h.Get("Content-Encoding") == "" {
+		// Whether this response is compressed depends on Accept-Encoding
+		h.Add("Vary", "Accept-Encoding")
+	}
+	if compress {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		w.gz = gzipWriters.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+func (w *gzipResponseWriter) flushBuffer() error {
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.gz != nil {
+		_, err := w.gz.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// Flush sends what has been written so far. A response flushed before it
+// reaches minSize is sent uncompressed, which keeps streams such as
+// server-sent events working.
+func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		w.commit(false)
+		w.flushBuffer()
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets websocket-style handlers take over the connection
+func (w *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("compress: underlying ResponseWriter does not support hijacking")
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close finishes the response once the handler has returned
+func (w *gzipResponseWriter) close() {
+	if !w.decided {
+		if !w.wroteHeader && len(w.buf) == 0 {
+			// The handler wrote nothing; let net/http send its default response
+			return
+		}
+		w.commit(false)
+		w.flushBuffer()
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		w.gz.Reset(nil)
+		gzipWriters.Put(w.gz)
+		w.gz = nil
+	}
+}
@@ -0,0 +1,174 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// largeJSON is a job-listing sized payload well over DefaultMinSize
+func largeJSON() []byte {
+	jobs := make([]map[string]string, 200)
+	for i := range jobs {
+		jobs[i] = map[string]string{"id": "job", "status": "completed", "output_format": "fhir"}
+	}
+	body, _ := json.Marshal(jobs)
+	return body
+}
+
+func serve(h http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGzipLargeJSON(t *testing.T) {
+	body := largeJSON()
+	h := Gzip(DefaultMinSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		// Several small writes, as json.Encoder and templates produce
+		for i := 0; i < len(body); i += 100 {
+			w.Write(body[i:min(i+100, len(body))])
+		}
+	}))
+
+	rec := serve(h, "deflate, gzip;q=0.8")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	assert.Less(t, rec.Body.Len(), len(body))
+	zr, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	got, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, body, got)
+
+	for _, accept := range []string{"", "deflate", "gzip;q=0"} {
+		rec = serve(h, accept)
+		assert.Empty(t, rec.Header().Get("Content-Encoding"), accept)
+		assert.Equal(t, body, rec.Body.Bytes(), accept)
+	}
+}
+
+func TestGzipSkips(t *testing.T) {
+	large := strings.Repeat("a", 2*DefaultMinSize)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+	}{
+		{
+			name: "small response",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, `{"status":"ok"}`)
+			},
+			body: `{"status":"ok"}`,
+		},
+		{
+			name: "already compressed type",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/zip")
+				io.WriteString(w, large)
+			},
+			body: large,
+		},
+		{
+			name: "already encoded",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Encoding", "br")
+				io.WriteString(w, large)
+			},
+			body: large,
+		},
+		{
+			name: "flushed stream",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				io.WriteString(w, "data: 1\n\n")
+				w.(http.Flusher).Flush()
+				io.WriteString(w, large)
+			},
+			body: "data: 1\n\n" + large,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(Gzip(DefaultMinSize)(tt.handler), "gzip")
+			assert.NotEqual(t, "gzip", rec.Header().Get("Content-Encoding"))
+			assert.Equal(t, tt.body, rec.Body.String())
+		})
+	}
+}
+
+func TestGzipSkipsRanges(t *testing.T) {
+	body := largeJSON()
+	h := Gzip(DefaultMinSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		http.ServeContent(w, r, "jobs.json", time.Time{}, bytes.NewReader(body))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Range", fmt.Sprintf("bytes=10-%d", len(body)-1))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"), "a resumed download must get the bytes its range names")
+	assert.Equal(t, fmt.Sprintf("bytes 10-%d/%d", len(body)-1, len(body)), rec.Header().Get("Content-Range"))
+	assert.Equal(t, body[10:], rec.Body.Bytes())
+
+	// The whole file is still compressed
+	rec = serve(h, "gzip")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+}
+
+func TestGzipSniffsContentType(t *testing.T) {
+	page := "<!DOCTYPE html><html>" + strings.Repeat("<p>row</p>", 200) + "</html>"
+	h := Gzip(DefaultMinSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, page)
+	}))
+	rec := serve(h, "gzip")
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+}
+
+func TestGzipBehindWrapResponseWriter(t *testing.T) {
+	body := largeJSON()
+	var status, written int
+	logger := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			status, written = ww.Status(), ww.BytesWritten()
+		})
+	}
+	h := logger(Gzip(DefaultMinSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})))
+
+	rec := serve(h, "gzip")
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, rec.Body.Len(), written, "the logger counts compressed bytes")
+}
@@ -1,14 +1,18 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Config struct {
 	APIPort        int    `mapstructure:"API_PORT"`
+	PortalPort     int    `mapstructure:"PORTAL_PORT"` // Port the portal is reached on when addressed by localhost/IP
 	APIURL         string `mapstructure:"API_URL"`
 	APIInternalURL string `mapstructure:"API_INTERNAL_URL"`
 
@@ -31,11 +35,28 @@ type Config struct {
 	DatabaseMaxIdle         int    `mapstructure:"DB_MAX_IDLE_CONNECTIONS"`    // PostgreSQL max idle connections
 	DatabaseConnMaxLifetime string `mapstructure:"DB_CONNECTION_MAX_LIFETIME"` // PostgreSQL connection max lifetime
 
+	// Upper bound on a single database query; 0 disables the limit
+	DatabaseQueryTimeoutSeconds int `mapstructure:"DB_QUERY_TIMEOUT_SECONDS"`
+
 	// Domain configuration (flattened)
 	DomainPortal string `mapstructure:"DOMAIN_PORTAL"`
 	DomainAPI    string `mapstructure:"DOMAIN_API"`
 	DomainSecure bool   `mapstructure:"DOMAIN_SECURE"`
 
+	// Directory to load portal templates from instead of the copies embedded in
+	// the binary; meant for development
+	DevTemplateDir string `mapstructure:"DEV_TEMPLATE_DIR"`
+	// Re-parse portal templates from disk on every request. Never enable in production.
+	DevMode bool `mapstructure:"DEV_MODE"`
+
+	// bcrypt work factor for new password hashes. Raising it slows logins and
+	// brute-force attempts alike; existing hashes keep the cost they were made with.
+	BcryptCost int `mapstructure:"BCRYPT_COST"`
+
+	// Portal sessions
+	SessionDurationHours    int `mapstructure:"SESSION_DURATION_HOURS"`     // Lifetime of a normal login session
+	RememberMeDurationHours int `mapstructure:"REMEMBER_ME_DURATION_HOURS"` // Lifetime when "remember me" is checked
+
 	// DigitalOcean Spaces configuration
 	S3Endpoint        string `mapstructure:"S3_ENDPOINT"`          // e.g. https://nyc3.digitaloceanspaces.com
 	S3Region          string `mapstructure:"S3_REGION"`            // e.g. nyc3, ams3, sgp1
@@ -43,6 +64,278 @@ type Config struct {
 	S3AccessKeyID     string `mapstructure:"S3_ACCESS_KEY_ID"`     // DigitalOcean Spaces Key
 	S3SecretAccessKey string `mapstructure:"S3_SECRET_ACCESS_KEY"` // DigitalOcean Spaces Secret
 	S3UseSSL          bool   `mapstructure:"S3_USE_SSL"`
+
+	// Where job outputs are stored: "s3" for DigitalOcean Spaces or
+	// "filesystem" for files under STORAGE_DIR. Left empty, DEV_MODE without
+	// a Spaces key uses the filesystem and everything else uses Spaces.
+	StorageBackend string `mapstructure:"STORAGE_BACKEND"`
+	StorageDir     string `mapstructure:"STORAGE_DIR"`
+
+	// Job execution
+	MaxConcurrentJobs int `mapstructure:"MAX_CONCURRENT_JOBS"` // Simultaneous Synthea processes
+	MaxQueuedJobs     int `mapstructure:"MAX_QUEUED_JOBS"`     // Accepted jobs that may wait for a free worker
+	MaxPopulation     int `mapstructure:"MAX_POPULATION"`      // Largest population a single job may request
+
+	// Output files of one job uploaded to S3 at the same time
+	MaxConcurrentUploads int `mapstructure:"MAX_CONCURRENT_UPLOADS"`
+
+	// Patients a free-tier user may generate per calendar month (UTC); 0
+	// disables the quota. Users whose email is in the comma-separated
+	// QUOTA_EXEMPT_EMAILS are not limited.
+	MonthlyPatientQuota int    `mapstructure:"MONTHLY_PATIENT_QUOTA"`
+	QuotaExemptEmails   string `mapstructure:"QUOTA_EXEMPT_EMAILS"`
+
+	// Synthea invocation: SYNTHEA_JAR_PATH runs "java -jar <jar>", otherwise
+	// SYNTHEA_COMMAND (a wrapper script or binary on PATH) is executed.
+	SyntheaCommand string `mapstructure:"SYNTHEA_COMMAND"`
+	SyntheaJarPath string `mapstructure:"SYNTHEA_JAR_PATH"`
+	// Comma-separated --key=value overrides passed to every run; only
+	// --generate.* settings are accepted
+	SyntheaExtraArgs string `mapstructure:"SYNTHEA_EXTRA_ARGS"`
+
+	// Comma-separated browser origins allowed to call the API; wildcards such
+	// as https://*.medisynth.io are supported. Empty allows local development only.
+	CORSAllowedOrigins string `mapstructure:"CORS_ALLOWED_ORIGINS"`
+
+	// Comma-separated external origins the portal's Content-Security-Policy
+	// allows scripts, styles and fonts from. Empty allows the CDNs the
+	// templates use by default.
+	CSPAllowedSources string `mapstructure:"CSP_ALLOWED_SOURCES"`
+
+	// Largest request body the API accepts, in bytes
+	MaxRequestBodyBytes int64 `mapstructure:"MAX_REQUEST_BODY_BYTES"`
+
+	// Readiness probe
+	ReadinessTimeoutSeconds int `mapstructure:"READINESS_TIMEOUT_SECONDS"` // Upper bound on /readyz dependency checks
+
+	// Days job outputs are kept after completion; 0 keeps them indefinitely.
+	// The API deletes expired outputs itself so jobs marked keep_output are
+	// spared; a bucket expiration rule would delete those too.
+	JobOutputRetentionDays int `mapstructure:"JOB_OUTPUT_RETENTION_DAYS"`
+
+	// Output files of at least this many bytes are uploaded in parts of
+	// MultipartUploadPartSizeBytes rather than with a single PutObject
+	MultipartUploadThresholdBytes int64 `mapstructure:"MULTIPART_UPLOAD_THRESHOLD_BYTES"`
+	MultipartUploadPartSizeBytes  int64 `mapstructure:"MULTIPART_UPLOAD_PART_SIZE_BYTES"`
+
+	// Output upload allowlist (comma-separated file extensions per output format)
+	OutputExtensionsFHIR string `mapstructure:"OUTPUT_EXTENSIONS_FHIR"`
+	OutputExtensionsCCDA string `mapstructure:"OUTPUT_EXTENSIONS_CCDA"`
+	OutputExtensionsCSV  string `mapstructure:"OUTPUT_EXTENSIONS_CSV"`
+}
+
+// defaultOutputExtensions apply to a format whose OUTPUT_EXTENSIONS_* is unset
+var defaultOutputExtensions = map[string][]string{
+	"fhir": {".json", ".ndjson"},
+	"ccda": {".xml"},
+	"csv":  {".csv"},
+}
+
+// AllowedOutputExtensions returns the file extensions that may be uploaded for
+// the given output format, falling back to the format's defaults when none are
+// set. Unknown formats allow nothing.
+func (c *Config) AllowedOutputExtensions(format string) []string {
+	var list string
+	switch format {
+	case "fhir":
+		list = c.OutputExtensionsFHIR
+	case "ccda":
+		list = c.OutputExtensionsCCDA
+	case "csv":
+		list = c.OutputExtensionsCSV
+	}
+	if extensions := splitList(list); len(extensions) > 0 {
+		return extensions
+	}
+	return defaultOutputExtensions[format]
+}
+
+// defaultCORSOrigins are allowed when CORS_ALLOWED_ORIGINS is unset
+var defaultCORSOrigins = []string{"http://*.local:*", "http://localhost:*", "http://127.0.0.1:*"}
+
+// AllowedCORSOrigins returns the configured CORS origins, falling back to
+// local development origins when none are set
+func (c *Config) AllowedCORSOrigins() []string {
+	if origins := splitList(c.CORSAllowedOrigins); len(origins) > 0 {
+		return origins
+	}
+	return defaultCORSOrigins
+}
+
+// defaultCSPSources are the CDNs the portal templates load Tailwind, Alpine
+// and fonts from; they apply when CSP_ALLOWED_SOURCES is unset
+var defaultCSPSources = []string{
+	"https://cdnjs.cloudflare.com",
+	"https://cdn.jsdelivr.net",
+	"https://fonts.googleapis.com",
+	"https://fonts.gstatic.com",
+}
+
+// AllowedCSPSources returns the external origins the portal's CSP allows,
+// falling back to the default CDNs when none are set
+func (c *Config) AllowedCSPSources() []string {
+	if sources := splitList(c.CSPAllowedSources); len(sources) > 0 {
+		return sources
+	}
+	return defaultCSPSources
+}
+
+// defaultMaxRequestBody applies when MAX_REQUEST_BODY_BYTES is unset or invalid
+const defaultMaxRequestBody = 1 << 20
+
+// MaxRequestBody returns the largest request body the API accepts
+func (c *Config) MaxRequestBody() int64 {
+	if c.MaxRequestBodyBytes <= 0 {
+		return defaultMaxRequestBody
+	}
+	return c.MaxRequestBodyBytes
+}
+
+// defaultUploadConcurrency applies when MAX_CONCURRENT_UPLOADS is unset or invalid
+const defaultUploadConcurrency = 8
+
+// UploadConcurrency returns how many output files of a job are uploaded at once
+func (c *Config) UploadConcurrency() int {
+	if c.MaxConcurrentUploads <= 0 {
+		return defaultUploadConcurrency
+	}
+	return c.MaxConcurrentUploads
+}
+
+// defaultMaxQueuedJobs applies when MAX_QUEUED_JOBS is unset or invalid
+const defaultMaxQueuedJobs = 1000
+
+// JobQueueSize returns how many accepted jobs may wait for a free worker
+// before new jobs are refused
+func (c *Config) JobQueueSize() int {
+	if c.MaxQueuedJobs <= 0 {
+		return defaultMaxQueuedJobs
+	}
+	return c.MaxQueuedJobs
+}
+
+// Storage backends selectable with STORAGE_BACKEND
+const (
+	StorageS3         = "s3"
+	StorageFilesystem = "filesystem"
+)
+
+// StorageBackendName returns the storage backend job outputs are kept in
+func (c *Config) StorageBackendName() string {
+	if c.StorageBackend != "" {
+		return c.StorageBackend
+	}
+	if c.DevMode && c.S3AccessKeyID == "" {
+		return StorageFilesystem
+	}
+	return StorageS3
+}
+
+// Multipart upload defaults, applied when the settings are unset or invalid
+const (
+	defaultMultipartUploadThreshold = 100 << 20
+	defaultMultipartUploadPartSize  = 16 << 20
+)
+
+// MultipartUploadThreshold returns the file size from which outputs are
+// uploaded with a multipart upload
+func (c *Config) MultipartUploadThreshold() int64 {
+	if c.MultipartUploadThresholdBytes <= 0 {
+		return defaultMultipartUploadThreshold
+	}
+	return c.MultipartUploadThresholdBytes
+}
+
+// MultipartUploadPartSize returns the size of each part of a multipart upload
+func (c *Config) MultipartUploadPartSize() int64 {
+	if c.MultipartUploadPartSizeBytes <= 0 {
+		return defaultMultipartUploadPartSize
+	}
+	return c.MultipartUploadPartSizeBytes
+}
+
+// JobOutputRetention returns how long job outputs are kept after completion,
+// or 0 if they are never deleted
+func (c *Config) JobOutputRetention() time.Duration {
+	if c.JobOutputRetentionDays <= 0 {
+		return 0
+	}
+	return time.Duration(c.JobOutputRetentionDays) * 24 * time.Hour
+}
+
+// DatabaseQueryTimeout returns the limit on a single database query, or 0 if
+// queries are not bounded
+func (c *Config) DatabaseQueryTimeout() time.Duration {
+	if c.DatabaseQueryTimeoutSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(c.DatabaseQueryTimeoutSeconds) * time.Second
+}
+
+// QuotaExemptEmailList returns the lowercased emails not subject to the
+// monthly patient quota
+func (c *Config) QuotaExemptEmailList() []string {
+	emails := splitList(c.QuotaExemptEmails)
+	for i, email := range emails {
+		emails[i] = strings.ToLower(email)
+	}
+	return emails
+}
+
+// PasswordHashCost returns the bcrypt cost for new password hashes
+func (c *Config) PasswordHashCost() int {
+	if c.BcryptCost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return c.BcryptCost
+}
+
+// defaultSessionDuration applies when SESSION_DURATION_HOURS is unset or invalid
+const defaultSessionDuration = 24 * time.Hour
+
+// SessionDuration returns how long a portal session lasts
+func (c *Config) SessionDuration() time.Duration {
+	if c.SessionDurationHours <= 0 {
+		return defaultSessionDuration
+	}
+	return time.Duration(c.SessionDurationHours) * time.Hour
+}
+
+// RememberMeDuration returns how long a "remember me" session lasts. It is
+// never shorter than a normal session.
+func (c *Config) RememberMeDuration() time.Duration {
+	remember := time.Duration(c.RememberMeDurationHours) * time.Hour
+	if session := c.SessionDuration(); remember < session {
+		return session
+	}
+	return remember
+}
+
+// defaultSyntheaCommand is run when neither SYNTHEA_COMMAND nor SYNTHEA_JAR_PATH is set
+const defaultSyntheaCommand = "synthea"
+
+// SyntheaCommandName returns the command used to run Synthea when no JAR is configured
+func (c *Config) SyntheaCommandName() string {
+	if c.SyntheaCommand == "" {
+		return defaultSyntheaCommand
+	}
+	return c.SyntheaCommand
+}
+
+// SyntheaExtraArgList returns the configured extra Synthea arguments
+func (c *Config) SyntheaExtraArgList() []string {
+	return splitList(c.SyntheaExtraArgs)
+}
+
+// splitList splits a comma-separated config value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 // Database returns a database config struct for backward compatibility
@@ -87,6 +380,7 @@ func LoadConfig() (*Config, error) {
 
 	// Set defaults
 	v.SetDefault("API_PORT", 8081)
+	v.SetDefault("PORTAL_PORT", 8082)
 	v.SetDefault("DB_TYPE", "sqlite")
 	v.SetDefault("DB_PATH", "/data/medisynth.db")
 	v.SetDefault("DB_SOCKET_PATH", "/data/sqlite.sock")
@@ -102,9 +396,15 @@ func LoadConfig() (*Config, error) {
 	v.SetDefault("DB_MAX_CONNECTIONS", 10)
 	v.SetDefault("DB_MAX_IDLE_CONNECTIONS", 5)
 	v.SetDefault("DB_CONNECTION_MAX_LIFETIME", "0")
+	v.SetDefault("DB_QUERY_TIMEOUT_SECONDS", 10)
 	v.SetDefault("DOMAIN_PORTAL", "portal.medisynth.io")
 	v.SetDefault("DOMAIN_API", "api.medisynth.io")
 	v.SetDefault("DOMAIN_SECURE", true)
+	v.SetDefault("DEV_TEMPLATE_DIR", "")
+	v.SetDefault("DEV_MODE", false)
+	v.SetDefault("BCRYPT_COST", bcrypt.DefaultCost)
+	v.SetDefault("SESSION_DURATION_HOURS", 24)
+	v.SetDefault("REMEMBER_ME_DURATION_HOURS", 720)
 	v.SetDefault("API_URL", "https://api.medisynth.io")
 	v.SetDefault("API_INTERNAL_URL", "http://medisynth-api-svc:8081")
 	v.SetDefault("S3_ENDPOINT", "https://nyc3.digitaloceanspaces.com")
@@ -113,15 +413,44 @@ func LoadConfig() (*Config, error) {
 	v.SetDefault("S3_ACCESS_KEY_ID", "")
 	v.SetDefault("S3_SECRET_ACCESS_KEY", "")
 	v.SetDefault("S3_USE_SSL", true)
+	v.SetDefault("STORAGE_BACKEND", "")
+	v.SetDefault("STORAGE_DIR", "/data/storage")
+	v.SetDefault("MAX_CONCURRENT_JOBS", 2)
+	v.SetDefault("MAX_QUEUED_JOBS", defaultMaxQueuedJobs)
+	v.SetDefault("MAX_POPULATION", 10000)
+	v.SetDefault("MAX_CONCURRENT_UPLOADS", defaultUploadConcurrency)
+	v.SetDefault("MONTHLY_PATIENT_QUOTA", 0)
+	v.SetDefault("QUOTA_EXEMPT_EMAILS", "")
+	v.SetDefault("MAX_REQUEST_BODY_BYTES", 1<<20)
+	v.SetDefault("SYNTHEA_COMMAND", "synthea")
+	v.SetDefault("SYNTHEA_JAR_PATH", "")
+	v.SetDefault("SYNTHEA_EXTRA_ARGS", "")
+	v.SetDefault("READINESS_TIMEOUT_SECONDS", 5)
+	v.SetDefault("CORS_ALLOWED_ORIGINS", "")
+	v.SetDefault("CSP_ALLOWED_SOURCES", "")
+	v.SetDefault("JOB_OUTPUT_RETENTION_DAYS", 0)
+	v.SetDefault("MULTIPART_UPLOAD_THRESHOLD_BYTES", defaultMultipartUploadThreshold)
+	v.SetDefault("MULTIPART_UPLOAD_PART_SIZE_BYTES", defaultMultipartUploadPartSize)
+	v.SetDefault("OUTPUT_EXTENSIONS_FHIR", ".json,.ndjson")
+	v.SetDefault("OUTPUT_EXTENSIONS_CCDA", ".xml")
+	v.SetDefault("OUTPUT_EXTENSIONS_CSV", ".csv")
 
 	// Explicitly bind environment variables
 	envVars := []string{
-		"API_PORT", "API_URL", "API_INTERNAL_URL",
+		"API_PORT", "PORTAL_PORT", "API_URL", "API_INTERNAL_URL",
 		"DB_TYPE", "DB_PATH", "DB_SOCKET_PATH", "DB_WAL_MODE", "DB_MAX_RETRIES", "DB_RETRY_DELAY",
 		"DB_HOST", "DB_PORT", "DB_NAME", "DB_USER", "DB_PASSWORD", "DB_SSL_MODE",
-		"DB_MAX_CONNECTIONS", "DB_MAX_IDLE_CONNECTIONS", "DB_CONNECTION_MAX_LIFETIME",
+		"DB_MAX_CONNECTIONS", "DB_MAX_IDLE_CONNECTIONS", "DB_CONNECTION_MAX_LIFETIME", "DB_QUERY_TIMEOUT_SECONDS",
 		"DOMAIN_PORTAL", "DOMAIN_API", "DOMAIN_SECURE",
+		"DEV_TEMPLATE_DIR", "DEV_MODE", "BCRYPT_COST", "SESSION_DURATION_HOURS", "REMEMBER_ME_DURATION_HOURS",
 		"S3_ENDPOINT", "S3_REGION", "S3_BUCKET", "S3_ACCESS_KEY_ID", "S3_SECRET_ACCESS_KEY", "S3_USE_SSL",
+		"STORAGE_BACKEND", "STORAGE_DIR",
+		"MAX_CONCURRENT_JOBS", "MAX_QUEUED_JOBS", "MAX_POPULATION", "MAX_REQUEST_BODY_BYTES", "JOB_OUTPUT_RETENTION_DAYS",
+		"MONTHLY_PATIENT_QUOTA", "QUOTA_EXEMPT_EMAILS",
+		"SYNTHEA_COMMAND", "SYNTHEA_JAR_PATH", "SYNTHEA_EXTRA_ARGS",
+		"READINESS_TIMEOUT_SECONDS", "CORS_ALLOWED_ORIGINS", "CSP_ALLOWED_SOURCES",
+		"OUTPUT_EXTENSIONS_FHIR", "OUTPUT_EXTENSIONS_CCDA", "OUTPUT_EXTENSIONS_CSV",
+		"MULTIPART_UPLOAD_THRESHOLD_BYTES", "MULTIPART_UPLOAD_PART_SIZE_BYTES", "MAX_CONCURRENT_UPLOADS",
 	}
 
 	for _, envVar := range envVars {
@@ -134,6 +463,14 @@ func LoadConfig() (*Config, error) {
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, err
 	}
+	if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("BCRYPT_COST must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, cfg.BcryptCost)
+	}
+	switch cfg.StorageBackend {
+	case "", StorageS3, StorageFilesystem:
+	default:
+		return nil, fmt.Errorf("STORAGE_BACKEND must be %q or %q, got %q", StorageS3, StorageFilesystem, cfg.StorageBackend)
+	}
 
 	log.Printf("Configuration loaded successfully")
 	return &cfg, nil
@@ -1,25 +1,12 @@
 package config
 
 import (
-	"os"
-	"path/filepath"
 	"testing"
 )
 
 func TestInit_Success(t *testing.T) {
-	dir := t.TempDir()
-	configPath := filepath.Join(dir, "app.yml")
-	os.WriteFile(configPath, []byte("apiPort: 1234\n"), 0644)
-
-	os.Setenv("CONFIG_DIR", dir)
-	defer os.Unsetenv("CONFIG_DIR")
-
-	os.Setenv("APIPORT", "5678") // Should override file
-	defer os.Unsetenv("APIPORT")
-
-	// viper uses global state, so set config dir
-	os.Setenv("config_dir", dir)
-	defer os.Unsetenv("config_dir")
+	t.Setenv("API_PORT", "5678")
+	t.Setenv("STORAGE_BACKEND", StorageFilesystem)
 
 	cfg, err := Init()
 	if err != nil {
@@ -28,28 +15,16 @@ func TestInit_Success(t *testing.T) {
 	if cfg.APIPort != 5678 {
 		t.Errorf("expected APIPort 5678 from env, got %d", cfg.APIPort)
 	}
-}
-
-func TestInit_MissingFile(t *testing.T) {
-	dir := t.TempDir()
-	os.Setenv("config_dir", dir)
-	defer os.Unsetenv("config_dir")
-
-	_, err := Init()
-	if err == nil {
-		t.Error("expected error for missing config file, got nil")
+	if cfg.StorageBackendName() != StorageFilesystem {
+		t.Errorf("expected storage backend %q from env, got %q", StorageFilesystem, cfg.StorageBackendName())
 	}
 }
 
-func TestInit_InvalidFile(t *testing.T) {
-	dir := t.TempDir()
-	configPath := filepath.Join(dir, "app.yml")
-	os.WriteFile(configPath, []byte("apiPort: notanumber\n"), 0644)
-	os.Setenv("config_dir", dir)
-	defer os.Unsetenv("config_dir")
+func TestInit_InvalidValue(t *testing.T) {
+	t.Setenv("API_PORT", "notanumber")
 
 	_, err := Init()
 	if err == nil {
-		t.Error("expected error for invalid config file, got nil")
+		t.Error("expected error for invalid API_PORT, got nil")
 	}
 }
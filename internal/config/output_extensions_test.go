@@ -0,0 +1,18 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowedOutputExtensions(t *testing.T) {
+	var cfg Config
+	assert.Equal(t, []string{".json", ".ndjson"}, cfg.AllowedOutputExtensions("fhir"), "a zero Config falls back to the defaults")
+	assert.Equal(t, []string{".xml"}, cfg.AllowedOutputExtensions("ccda"))
+	assert.Equal(t, []string{".csv"}, cfg.AllowedOutputExtensions("csv"))
+	assert.Empty(t, cfg.AllowedOutputExtensions("hl7"), "unknown formats allow nothing")
+
+	cfg.OutputExtensionsFHIR = ".json, .gz"
+	assert.Equal(t, []string{".json", ".gz"}, cfg.AllowedOutputExtensions("fhir"))
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoadConfigBcryptCost(t *testing.T) {
+	cfg, err := LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, bcrypt.DefaultCost, cfg.PasswordHashCost())
+
+	t.Setenv("BCRYPT_COST", "12")
+	cfg, err = LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, 12, cfg.PasswordHashCost())
+
+	for _, invalid := range []string{"3", "32"} {
+		t.Setenv("BCRYPT_COST", invalid)
+		_, err = LoadConfig()
+		assert.Error(t, err, "BCRYPT_COST=%s is outside bcrypt's range", invalid)
+	}
+}
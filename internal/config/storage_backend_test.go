@@ -0,0 +1,27 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageBackendName(t *testing.T) {
+	assert.Equal(t, StorageS3, (&Config{}).StorageBackendName())
+	assert.Equal(t, StorageFilesystem, (&Config{DevMode: true}).StorageBackendName(), "development works without Spaces credentials")
+	assert.Equal(t, StorageS3, (&Config{DevMode: true, S3AccessKeyID: "key"}).StorageBackendName())
+	assert.Equal(t, StorageFilesystem, (&Config{StorageBackend: StorageFilesystem}).StorageBackendName())
+	assert.Equal(t, StorageS3, (&Config{DevMode: true, StorageBackend: StorageS3}).StorageBackendName())
+}
+
+func TestLoadConfigRejectsUnknownStorageBackend(t *testing.T) {
+	t.Setenv("STORAGE_BACKEND", "ftp")
+	_, err := LoadConfig()
+	assert.Error(t, err)
+
+	t.Setenv("STORAGE_BACKEND", StorageFilesystem)
+	cfg, err := LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, StorageFilesystem, cfg.StorageBackendName())
+}
@@ -1,90 +1,184 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/MediSynth-io/medisynth/internal/config"
 	"github.com/MediSynth-io/medisynth/internal/models"
-	_ "github.com/lib/pq" // PostgreSQL driver
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/lib/pq" // PostgreSQL driver
+	"github.com/mattn/go-sqlite3"
 )
 
+// DB is an open database connection together with the backend it talks to.
+// Each Open returns an independent instance, so tests can use a database of
+// their own. Package-level functions use the instance set up by Init while
+// callers migrate to the methods.
+type DB struct {
+	conn   *sql.DB
+	dbType string
+
+	// queryTimeout bounds each query run through queryContext; 0 means no limit
+	queryTimeout time.Duration
+}
+
+// defaultDB is the instance behind the package-level functions
+var defaultDB *DB
+
+// dbConn and dbType mirror defaultDB for the functions not yet moved to DB
 var dbConn *sql.DB
 var dbType string
 
-// Init initializes the database connection and schema
-func Init(cfg *config.Config) error {
-	if dbConn != nil {
-		return nil
+// queryContext derives the context a single query runs with from ctx, limited
+// to DB_QUERY_TIMEOUT_SECONDS. cancel must be called once the query's rows
+// have been read.
+func (db *DB) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, db.queryTimeout)
+}
+
+// Init opens the database the package-level functions use and returns it.
+// Later calls return the same instance.
+func Init(cfg *config.Config) (*DB, error) {
+	if defaultDB != nil {
+		return defaultDB, nil
+	}
+
+	db, err := Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultDB = db
+	dbConn = db.conn
+	dbType = db.dbType
+	log.Printf("Database type set to: %s", dbType)
+	return db, nil
+}
+
+// Default returns the instance set up by Init, or nil before Init
+func Default() *DB {
+	return defaultDB
+}
 
+// Open connects to the configured database and initializes its schema. The
+// returned instance is independent of the one set up by Init.
+func Open(cfg *config.Config) (*DB, error) {
 	log.Printf("=== DATABASE INITIALIZATION DEBUG ===")
 	log.Printf("Database type: %s", cfg.DatabaseType)
 
-	var db *sql.DB
+	var conn *sql.DB
 	var err error
 
 	switch cfg.DatabaseType {
 	case "postgres":
-		db, err = initPostgreSQL(cfg)
+		conn, err = initPostgreSQL(cfg)
 	case "sqlite", "":
-		db, err = initSQLite(cfg)
+		conn, err = initSQLite(cfg)
 	default:
-		return fmt.Errorf("unsupported database type: %s", cfg.DatabaseType)
+		return nil, fmt.Errorf("unsupported database type: %s", cfg.DatabaseType)
 	}
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	configurePool(conn, cfg)
+
 	// Test the connection
-	if err := db.Ping(); err != nil {
-		db.Close()
-		return fmt.Errorf("failed to ping database: %v", err)
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to ping database: %v", err)
 	}
 
 	// Check existing data BEFORE schema initialization
 	log.Printf("Checking existing database contents...")
-	if err := debugExistingData(db); err != nil {
+	if err := debugExistingData(conn); err != nil {
 		log.Printf("Warning: Could not check existing data: %v", err)
 	}
 
 	// Initialize schema
 	log.Printf("Initializing database schema")
-	if err = initSchema(db, cfg.DatabaseType); err != nil {
-		db.Close()
-		return fmt.Errorf("failed to initialize schema: %v", err)
+	if err = initSchema(conn, cfg.DatabaseType); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to initialize schema: %v", err)
 	}
 
 	// Check data AFTER schema initialization
 	log.Printf("Checking database contents after schema init...")
-	if err := debugExistingData(db); err != nil {
+	if err := debugExistingData(conn); err != nil {
 		log.Printf("Warning: Could not check data after init: %v", err)
 	}
 
-	dbConn = db
-	dbType = cfg.DatabaseType
+	db := &DB{
+		conn:         conn,
+		dbType:       cfg.DatabaseType,
+		queryTimeout: cfg.DatabaseQueryTimeout(),
+	}
 	log.Printf("=== DATABASE INITIALIZED SUCCESSFULLY ===")
-	log.Printf("Database type set to: %s", dbType)
-	log.Printf("Database connection established: %v", dbConn != nil)
 
 	// Test basic database functionality
 	var testCount int
 	testQuery := "SELECT COUNT(*) FROM sessions"
-	err = dbConn.QueryRow(testQuery).Scan(&testCount)
+	err = conn.QueryRow(testQuery).Scan(&testCount)
 	if err != nil {
 		log.Printf("WARNING: Could not query sessions table: %v", err)
 	} else {
 		log.Printf("Sessions table accessible, current count: %d", testCount)
 	}
 
-	return nil
+	return db, nil
+}
+
+// Conn returns the underlying connection pool
+func (db *DB) Conn() *sql.DB {
+	return db.conn
+}
+
+// Stats returns connection pool statistics
+func (db *DB) Stats() sql.DBStats {
+	return db.conn.Stats()
+}
+
+// Close closes the connection pool
+func (db *DB) Close() error {
+	return db.conn.Close()
+}
+
+// configurePool applies connection pool limits for the configured backend.
+// SQLite allows a single writer, so it gets exactly one connection; extra
+// connections only produce "database is locked" errors under concurrency.
+func configurePool(db *sql.DB, cfg *config.Config) {
+	if cfg.DatabaseType != "postgres" {
+		db.SetMaxOpenConns(1)
+		db.SetMaxIdleConns(1)
+		log.Printf("SQLite connection pool limited to a single connection")
+		return
+	}
+
+	if cfg.DatabaseMaxConns > 0 {
+		db.SetMaxOpenConns(cfg.DatabaseMaxConns)
+	}
+	if cfg.DatabaseMaxIdle > 0 {
+		db.SetMaxIdleConns(cfg.DatabaseMaxIdle)
+	}
+	if cfg.DatabaseConnMaxLifetime != "" && cfg.DatabaseConnMaxLifetime != "0" {
+		if duration, err := time.ParseDuration(cfg.DatabaseConnMaxLifetime); err == nil {
+			db.SetConnMaxLifetime(duration)
+		} else {
+			log.Printf("Warning: invalid DB_CONNECTION_MAX_LIFETIME %q: %v", cfg.DatabaseConnMaxLifetime, err)
+		}
+	}
+	log.Printf("PostgreSQL connection pool: max open %d, max idle %d", cfg.DatabaseMaxConns, cfg.DatabaseMaxIdle)
 }
 
 // initPostgreSQL initializes PostgreSQL connection
@@ -109,19 +203,6 @@ func initPostgreSQL(cfg *config.Config) (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to open PostgreSQL connection: %v", err)
 	}
 
-	// Configure connection pool
-	if cfg.DatabaseMaxConns > 0 {
-		db.SetMaxOpenConns(cfg.DatabaseMaxConns)
-	}
-	if cfg.DatabaseMaxIdle > 0 {
-		db.SetMaxIdleConns(cfg.DatabaseMaxIdle)
-	}
-	if cfg.DatabaseConnMaxLifetime != "" && cfg.DatabaseConnMaxLifetime != "0" {
-		if duration, err := time.ParseDuration(cfg.DatabaseConnMaxLifetime); err == nil {
-			db.SetConnMaxLifetime(duration)
-		}
-	}
-
 	log.Printf("PostgreSQL connection configured successfully")
 	return db, nil
 }
@@ -168,6 +249,14 @@ func GetConnection() *sql.DB {
 	return dbConn
 }
 
+// Stats returns connection pool statistics, or zero values before Init
+func Stats() sql.DBStats {
+	if defaultDB == nil {
+		return sql.DBStats{}
+	}
+	return defaultDB.Stats()
+}
+
 // initSchema creates the database schema if it doesn't exist
 func initSchema(db *sql.DB, dbType string) error {
 	var queries []string
@@ -180,6 +269,7 @@ func initSchema(db *sql.DB, dbType string) error {
 				id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
 				email VARCHAR(255) UNIQUE NOT NULL,
 				password VARCHAR(255) NOT NULL,
+				tier VARCHAR(50) NOT NULL DEFAULT 'free',
 				created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
 				updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
 			)`,
@@ -210,9 +300,25 @@ func initSchema(db *sql.DB, dbType string) error {
 				patient_count INTEGER,
 				error_message TEXT,
 				created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
-				completed_at TIMESTAMP WITH TIME ZONE
+				started_at TIMESTAMP WITH TIME ZONE,
+				completed_at TIMESTAMP WITH TIME ZONE,
+				updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+				keep_output BOOLEAN NOT NULL DEFAULT FALSE,
+				duration_ms BIGINT,
+				request_id VARCHAR(255),
+				heartbeat_at TIMESTAMP WITH TIME ZONE
+			)`,
+			`CREATE TABLE IF NOT EXISTS job_presets (
+				id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+				user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+				name VARCHAR(255) NOT NULL,
+				parameters JSONB NOT NULL,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+				updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+				UNIQUE (user_id, name)
 			)`,
 			`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
+			`CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users(lower(email))`,
 			`CREATE INDEX IF NOT EXISTS idx_tokens_user_id ON tokens(user_id)`,
 			`CREATE INDEX IF NOT EXISTS idx_tokens_token ON tokens(token)`,
 			`CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id)`,
@@ -220,6 +326,7 @@ func initSchema(db *sql.DB, dbType string) error {
 			`CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at)`,
 			`CREATE INDEX IF NOT EXISTS idx_jobs_user_id ON jobs(user_id)`,
 			`CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status)`,
+			`CREATE INDEX IF NOT EXISTS idx_job_presets_user_id ON job_presets(user_id)`,
 		}
 	} else {
 		// SQLite schema (original)
@@ -228,6 +335,7 @@ func initSchema(db *sql.DB, dbType string) error {
 				id TEXT PRIMARY KEY,
 				email TEXT UNIQUE NOT NULL,
 				password TEXT NOT NULL,
+				tier TEXT NOT NULL DEFAULT 'free',
 				created_at DATETIME NOT NULL,
 				updated_at DATETIME NOT NULL
 			)`,
@@ -260,22 +368,179 @@ func initSchema(db *sql.DB, dbType string) error {
 				patient_count INTEGER,
 				error_message TEXT,
 				created_at DATETIME NOT NULL,
+				started_at DATETIME,
 				completed_at DATETIME,
+				updated_at DATETIME NOT NULL,
+				keep_output BOOLEAN NOT NULL DEFAULT 0,
+				duration_ms INTEGER,
+				request_id TEXT,
+				heartbeat_at DATETIME,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			)`,
+			`CREATE TABLE IF NOT EXISTS job_presets (
+				id TEXT PRIMARY KEY,
+				user_id TEXT,
+				name TEXT NOT NULL,
+				parameters TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL,
+				UNIQUE (user_id, name),
 				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 			)`,
 			`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
+			`CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users(lower(email))`,
 			`CREATE INDEX IF NOT EXISTS idx_tokens_user_id ON tokens(user_id)`,
 			`CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id)`,
 			`CREATE INDEX IF NOT EXISTS idx_sessions_token ON sessions(token)`,
+			`CREATE INDEX IF NOT EXISTS idx_job_presets_user_id ON job_presets(user_id)`,
 		}
 	}
 
+	return applySchema(db, dbType, queries)
+}
+
+// applySchema runs the schema queries and then upgradeSchema in a single
+// transaction, so a failure part way through leaves the database as it was
+// rather than half set up. Both SQLite and PostgreSQL support transactional
+// DDL for the statements used here.
+func applySchema(db *sql.DB, dbType string, queries []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin schema transaction: %v", err)
+	}
+	defer tx.Rollback()
+
 	for _, query := range queries {
 		log.Printf("Executing schema query: %s", query[:min(len(query), 80)]+"...")
-		if _, err := db.Exec(query); err != nil {
+		if _, err := tx.Exec(query); err != nil {
 			return fmt.Errorf("failed to execute schema query: %v", err)
 		}
 	}
+	if err := upgradeSchema(tx, dbType); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit schema: %v", err)
+	}
+	return nil
+}
+
+// upgradeSchema adds columns introduced after a table was first created.
+// CREATE TABLE IF NOT EXISTS leaves existing tables alone, so databases
+// created by older versions are brought up to date here. Every step is
+// idempotent.
+func upgradeSchema(tx *sql.Tx, dbType string) error {
+	// jobs.updated_at, backfilled from created_at
+	if err := ensureColumn(tx, dbType, "jobs", "updated_at", "DATETIME", "TIMESTAMP WITH TIME ZONE"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE jobs SET updated_at = created_at WHERE updated_at IS NULL"); err != nil {
+		return fmt.Errorf("failed to backfill jobs.updated_at: %v", err)
+	}
+	if dbType == "postgres" {
+		if _, err := tx.Exec("ALTER TABLE jobs ALTER COLUMN updated_at SET DEFAULT NOW(), ALTER COLUMN updated_at SET NOT NULL"); err != nil {
+			return fmt.Errorf("failed to constrain jobs.updated_at: %v", err)
+		}
+	}
+
+	// jobs.keep_output; existing jobs follow the retention policy
+	if err := ensureColumn(tx, dbType, "jobs", "keep_output", "BOOLEAN NOT NULL DEFAULT 0", "BOOLEAN NOT NULL DEFAULT FALSE"); err != nil {
+		return err
+	}
+
+	// jobs.started_at; unknown for jobs that ran before it was recorded
+	if err := ensureColumn(tx, dbType, "jobs", "started_at", "DATETIME", "TIMESTAMP WITH TIME ZONE"); err != nil {
+		return err
+	}
+
+	// jobs.duration_ms; unknown for jobs that ran before it was recorded
+	if err := ensureColumn(tx, dbType, "jobs", "duration_ms", "INTEGER", "BIGINT"); err != nil {
+		return err
+	}
+
+	// jobs.request_id; unknown for jobs created before it was recorded
+	if err := ensureColumn(tx, dbType, "jobs", "request_id", "TEXT", "VARCHAR(255)"); err != nil {
+		return err
+	}
+
+	// jobs.heartbeat_at; running jobs without one fall back to updated_at
+	if err := ensureColumn(tx, dbType, "jobs", "heartbeat_at", "DATETIME", "TIMESTAMP WITH TIME ZONE"); err != nil {
+		return err
+	}
+
+	// users.tier; existing users start on the free tier
+	if err := ensureColumn(tx, dbType, "users", "tier", "TEXT NOT NULL DEFAULT 'free'", "VARCHAR(50) NOT NULL DEFAULT 'free'"); err != nil {
+		return err
+	}
+
+	// Emails registered before they were normalized. Accounts whose
+	// addresses differ only in case are logged and left as they are, since
+	// lowercasing them would violate the unique constraint; GetUserByEmail
+	// still finds them case-insensitively.
+	if err := logDuplicateEmails(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE users SET email = lower(email)
+		WHERE email <> lower(email)
+		AND NOT EXISTS (SELECT 1 FROM users AS other WHERE other.id <> users.id AND lower(other.email) = lower(users.email))`); err != nil {
+		return fmt.Errorf("failed to lowercase user emails: %v", err)
+	}
+	return nil
+}
+
+// logDuplicateEmails logs the ids of accounts whose emails differ only in
+// case, so an operator can merge or rename them
+func logDuplicateEmails(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT lower(email), id FROM users
+		WHERE lower(email) IN (SELECT lower(email) FROM users GROUP BY lower(email) HAVING COUNT(*) > 1)
+		ORDER BY lower(email), id`)
+	if err != nil {
+		return fmt.Errorf("failed to find duplicate user emails: %v", err)
+	}
+	defer rows.Close()
+
+	var emails []string
+	ids := make(map[string][]string)
+	for rows.Next() {
+		var email, id string
+		if err := rows.Scan(&email, &id); err != nil {
+			return fmt.Errorf("failed to scan duplicate user email: %v", err)
+		}
+		if _, ok := ids[email]; !ok {
+			emails = append(emails, email)
+		}
+		ids[email] = append(ids[email], id)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to find duplicate user emails: %v", err)
+	}
+	for _, email := range emails {
+		log.Printf("Warning: users %s share the email %s ignoring case; leaving their addresses unnormalized", strings.Join(ids[email], ", "), email)
+	}
+	return nil
+}
+
+// ensureColumn adds column to table unless it already exists, using
+// sqliteType or postgresType for its definition
+func ensureColumn(tx *sql.Tx, dbType, table, column, sqliteType, postgresType string) error {
+	if dbType == "postgres" {
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", table, column, postgresType)); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %v", table, column, err)
+		}
+		return nil
+	}
+
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count); err != nil {
+		return fmt.Errorf("failed to inspect table %s: %v", table, err)
+	}
+	if count > 0 {
+		return nil
+	}
+	log.Printf("Adding column %s.%s", table, column)
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, sqliteType)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %v", table, column, err)
+	}
 	return nil
 }
 
@@ -332,14 +597,23 @@ func checkWritePermissions(dir string) error {
 
 // CreateUser creates a new user
 func CreateUser(email, password string) (*models.User, error) {
+	return defaultDB.CreateUser(context.Background(), email, password)
+}
+
+// CreateUser creates a new user in this database, giving up when ctx is done
+func (db *DB) CreateUser(ctx context.Context, email, password string) (*models.User, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
 	user := &models.User{
 		Email:    email,
 		Password: password,
+		Tier:     models.TierFree,
 	}
 
-	if dbType == "postgres" {
+	if db.dbType == "postgres" {
 		// PostgreSQL with UUID auto-generation
-		err := dbConn.QueryRow(
+		err := db.conn.QueryRowContext(ctx,
 			"INSERT INTO users (email, password) VALUES ($1, $2) RETURNING id, created_at, updated_at",
 			user.Email, user.Password,
 		).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
@@ -353,7 +627,7 @@ func CreateUser(email, password string) (*models.User, error) {
 		user.CreatedAt = now
 		user.UpdatedAt = now
 
-		_, err := dbConn.Exec(
+		_, err := db.conn.ExecContext(ctx,
 			"INSERT INTO users (id, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
 			user.ID, user.Email, user.Password, user.CreatedAt, user.UpdatedAt,
 		)
@@ -365,21 +639,53 @@ func CreateUser(email, password string) (*models.User, error) {
 	return user, nil
 }
 
-// GetUserByEmail retrieves a user by email
+// UserExists reports whether a user with the given email is already
+// registered, ignoring case
+func UserExists(email string) (bool, error) {
+	var query string
+	if dbType == "postgres" {
+		query = "SELECT EXISTS(SELECT 1 FROM users WHERE lower(email) = lower($1))"
+	} else {
+		query = "SELECT EXISTS(SELECT 1 FROM users WHERE lower(email) = lower(?))"
+	}
+
+	var exists bool
+	if err := dbConn.QueryRow(query, email).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// IsUniqueViolation reports whether err is a UNIQUE constraint failure from
+// either SQLite or PostgreSQL
+func IsUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505" // unique_violation
+	}
+	return false
+}
+
+// GetUserByEmail retrieves a user by email, ignoring case. If accounts from
+// before emails were normalized differ only in case, the exact match wins.
 func GetUserByEmail(email string) (*models.User, error) {
 	user := &models.User{}
 	var err error
 
 	if dbType == "postgres" {
 		err = dbConn.QueryRow(
-			"SELECT id, email, password, created_at, updated_at FROM users WHERE email = $1",
+			"SELECT id, email, password, tier, created_at, updated_at FROM users WHERE lower(email) = lower($1) ORDER BY email = $1 DESC LIMIT 1",
 			email,
-		).Scan(&user.ID, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+		).Scan(&user.ID, &user.Email, &user.Password, &user.Tier, &user.CreatedAt, &user.UpdatedAt)
 	} else {
 		err = dbConn.QueryRow(
-			"SELECT id, email, password, created_at, updated_at FROM users WHERE email = ?",
-			email,
-		).Scan(&user.ID, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+			"SELECT id, email, password, tier, created_at, updated_at FROM users WHERE lower(email) = lower(?) ORDER BY email = ? DESC LIMIT 1",
+			email, email,
+		).Scan(&user.ID, &user.Email, &user.Password, &user.Tier, &user.CreatedAt, &user.UpdatedAt)
 	}
 
 	if err != nil {
@@ -388,22 +694,54 @@ func GetUserByEmail(email string) (*models.User, error) {
 	return user, nil
 }
 
+// SetUserTier changes the user's account tier. It returns sql.ErrNoRows if
+// the user doesn't exist.
+func SetUserTier(userID string, tier string) error {
+	if !models.IsValidTier(tier) {
+		return fmt.Errorf("unknown tier %q", tier)
+	}
+
+	query := "UPDATE users SET tier = ?, updated_at = ? WHERE id = ?"
+	if dbType == "postgres" {
+		query = "UPDATE users SET tier = $1, updated_at = $2 WHERE id = $3"
+	}
+	result, err := dbConn.Exec(query, tier, time.Now(), userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // GetUserByID retrieves a user by their ID
+//
+// Deprecated: use GetUserByIDContext so the query stops with the request.
 func GetUserByID(id string) (*models.User, error) {
-	user := &models.User{}
-	var err error
+	return GetUserByIDContext(context.Background(), id)
+}
 
-	if dbType == "postgres" {
-		err = dbConn.QueryRow(
-			"SELECT id, email, password, created_at, updated_at FROM users WHERE id = $1",
-			id,
-		).Scan(&user.ID, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
-	} else {
-		err = dbConn.QueryRow(
-			"SELECT id, email, password, created_at, updated_at FROM users WHERE id = ?",
-			id,
-		).Scan(&user.ID, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+// GetUserByIDContext retrieves a user by their ID, giving up when ctx is done
+func GetUserByIDContext(ctx context.Context, id string) (*models.User, error) {
+	return defaultDB.GetUserByID(ctx, id)
+}
+
+// GetUserByID retrieves a user by their ID, giving up when ctx is done
+func (db *DB) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := "SELECT id, email, password, tier, created_at, updated_at FROM users WHERE id = ?"
+	if db.dbType == "postgres" {
+		query = "SELECT id, email, password, tier, created_at, updated_at FROM users WHERE id = $1"
 	}
+	user := &models.User{}
+	err := db.conn.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Email, &user.Password, &user.Tier, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		return nil, err
@@ -481,15 +819,71 @@ func DeleteToken(userID string, tokenID string) error {
 	return nil
 }
 
+// DeleteUserTokens deletes every API token of the user and returns how many
+// were removed
+func DeleteUserTokens(userID string) (int64, error) {
+	var query string
+	if dbType == "postgres" {
+		query = "DELETE FROM tokens WHERE user_id = $1"
+	} else {
+		query = "DELETE FROM tokens WHERE user_id = ?"
+	}
+	result, err := dbConn.Exec(query, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteTokensByIDs deletes the user's tokens among ids and returns how many
+// were removed. IDs of tokens belonging to other users are ignored.
+func DeleteTokensByIDs(userID string, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, userID)
+	placeholders := make([]string, len(ids))
+	for i, id := range ids {
+		args = append(args, id)
+		if dbType == "postgres" {
+			placeholders[i] = fmt.Sprintf("$%d", i+2)
+		} else {
+			placeholders[i] = "?"
+		}
+	}
+
+	var query string
+	if dbType == "postgres" {
+		query = "DELETE FROM tokens WHERE user_id = $1 AND id IN (" + strings.Join(placeholders, ", ") + ")"
+	} else {
+		query = "DELETE FROM tokens WHERE user_id = ? AND id IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	result, err := dbConn.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 // GetUserTokens retrieves all tokens for a user
 func GetUserTokens(userID string) ([]*models.Token, error) {
+	return defaultDB.GetUserTokens(context.Background(), userID)
+}
+
+// GetUserTokens retrieves all tokens for a user, giving up when ctx is done
+func (db *DB) GetUserTokens(ctx context.Context, userID string) ([]*models.Token, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
 	var query string
-	if dbType == "postgres" {
+	if db.dbType == "postgres" {
 		query = "SELECT id, user_id, token, name, created_at, expires_at FROM tokens WHERE user_id = $1"
 	} else {
 		query = "SELECT id, user_id, token, name, created_at, expires_at FROM tokens WHERE user_id = ?"
 	}
-	rows, err := dbConn.Query(query, userID)
+	rows, err := db.conn.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -545,8 +939,8 @@ func CreateSession(userID string, token string, expiresAt time.Time) error {
 	return err
 }
 
-// ValidateSession retrieves a user by session token
-func ValidateSession(token string) (*models.Session, error) {
+// GetSessionByToken retrieves a session by its token without checking expiry
+func GetSessionByToken(token string) (*models.Session, error) {
 	var session models.Session
 	var query string
 	if dbType == "postgres" {
@@ -558,13 +952,101 @@ func ValidateSession(token string) (*models.Session, error) {
 	if err != nil {
 		return nil, err
 	}
+	return &session, nil
+}
+
+// ValidateSession retrieves a user by session token
+func ValidateSession(token string) (*models.Session, error) {
+	session, err := GetSessionByToken(token)
+	if err != nil {
+		return nil, err
+	}
 	// Check for expiration
 	if session.ExpiresAt.Before(time.Now()) {
 		// Optionally, delete the expired session
 		DeleteSession(token)
 		return nil, errors.New("session expired")
 	}
-	return &session, nil
+	return session, nil
+}
+
+// GetSessionsByUserID lists a user's unexpired sessions, newest first
+func GetSessionsByUserID(userID string) ([]*models.SessionSummary, error) {
+	var query string
+	if dbType == "postgres" {
+		query = `SELECT id, token, created_at, expires_at FROM sessions WHERE user_id = $1 AND expires_at > $2 ORDER BY created_at DESC`
+	} else {
+		query = `SELECT id, token, created_at, expires_at FROM sessions WHERE user_id = ? AND expires_at > ? ORDER BY created_at DESC`
+	}
+
+	rows, err := dbConn.Query(query, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*models.SessionSummary
+	for rows.Next() {
+		var token string
+		session := &models.SessionSummary{}
+		if err := rows.Scan(&session.ID, &token, &session.CreatedAt, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		session.MaskedToken = maskToken(token)
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// maskToken keeps only the first few characters of a secret token
+func maskToken(token string) string {
+	if len(token) <= 6 {
+		return "..."
+	}
+	return token[:6] + "..."
+}
+
+// DeleteUserSession deletes one of a user's sessions by its ID
+func DeleteUserSession(userID string, sessionID string) error {
+	var query string
+	if dbType == "postgres" {
+		query = `DELETE FROM sessions WHERE id = $1 AND user_id = $2`
+	} else {
+		query = `DELETE FROM sessions WHERE id = ? AND user_id = ?`
+	}
+	result, err := dbConn.Exec(query, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteOtherSessions deletes every session of the user except keepSessionID
+// and returns how many were removed
+func DeleteOtherSessions(userID string, keepSessionID string) (int64, error) {
+	var query string
+	if dbType == "postgres" {
+		query = `DELETE FROM sessions WHERE user_id = $1 AND id <> $2`
+	} else {
+		query = `DELETE FROM sessions WHERE user_id = ? AND id <> ?`
+	}
+	result, err := dbConn.Exec(query, userID, keepSessionID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 // DeleteSession deletes a session by its token
@@ -1,12 +1,19 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/MediSynth-io/medisynth/internal/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -43,7 +50,7 @@ func (s *DatabaseTestSuite) SetupTest() {
 		os.Remove("test_medisynth.db")
 	}
 
-	err = Init(cfg)
+	_, err = Init(cfg)
 	assert.NoError(s.T(), err, "Database initialization should succeed")
 }
 
@@ -51,11 +58,17 @@ func (s *DatabaseTestSuite) SetupTest() {
 func (s *DatabaseTestSuite) TearDownTest() {
 	if s.dbType == "sqlite" {
 		os.Remove("test_medisynth.db")
+		os.Remove("test_medisynth.db-wal")
+		os.Remove("test_medisynth.db-shm")
 	} else {
 		// Clean up tables in PostgreSQL
-		dbConn.Exec("DROP TABLE IF EXISTS sessions, tokens, users CASCADE")
+		dbConn.Exec("DROP TABLE IF EXISTS job_presets, jobs, sessions, tokens, users CASCADE")
+	}
+	if dbConn != nil {
+		dbConn.Close()
 	}
 	dbConn = nil // Reset connection
+	defaultDB = nil
 }
 
 // TestDatabaseTestSuite runs the test suite
@@ -87,6 +100,112 @@ func (s *DatabaseTestSuite) TestCreateAndGetUser() {
 	assert.Equal(s.T(), user.ID, retrievedUserByID.ID)
 }
 
+// TestSetUserTier checks the tier defaults to free and persists changes
+func (s *DatabaseTestSuite) TestSetUserTier() {
+	user, err := CreateUser("tier@example.com", "password")
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), models.TierFree, user.Tier)
+
+	found, err := GetUserByID(user.ID)
+	if assert.NoError(s.T(), err) {
+		assert.Equal(s.T(), models.TierFree, found.Tier)
+	}
+
+	assert.NoError(s.T(), SetUserTier(user.ID, models.TierPro))
+	found, err = GetUserByEmail("tier@example.com")
+	if assert.NoError(s.T(), err) {
+		assert.Equal(s.T(), models.TierPro, found.Tier)
+	}
+
+	assert.Error(s.T(), SetUserTier(user.ID, "platinum"))
+	assert.ErrorIs(s.T(), SetUserTier("no-such-user", models.TierPro), sql.ErrNoRows)
+}
+
+// TestUpgradeSchemaAddsUserTier runs the schema setup against a users table
+// from before tiers existed
+func (s *DatabaseTestSuite) TestUpgradeSchemaAddsUserTier() {
+	if s.dbType != "sqlite" {
+		s.T().Skip("the legacy table fixture is SQLite only")
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(s.T().TempDir(), "legacy.db"))
+	assert.NoError(s.T(), err)
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE users (
+		id TEXT PRIMARY KEY, email TEXT UNIQUE NOT NULL, password TEXT NOT NULL,
+		created_at DATETIME NOT NULL, updated_at DATETIME NOT NULL
+	)`)
+	assert.NoError(s.T(), err)
+	_, err = db.Exec("INSERT INTO users VALUES ('legacy', 'legacy@example.com', 'hash', ?, ?)", time.Now(), time.Now())
+	assert.NoError(s.T(), err)
+
+	assert.NoError(s.T(), initSchema(db, "sqlite"))
+	assert.NoError(s.T(), initSchema(db, "sqlite"))
+
+	var tier string
+	assert.NoError(s.T(), db.QueryRow("SELECT tier FROM users WHERE id = 'legacy'").Scan(&tier))
+	assert.Equal(s.T(), models.TierFree, tier)
+}
+
+// TestGetUserByEmailIgnoresCase checks mixed-case lookups find the user
+func (s *DatabaseTestSuite) TestGetUserByEmailIgnoresCase() {
+	user, err := CreateUser("mixed@example.com", "password")
+	assert.NoError(s.T(), err)
+
+	found, err := GetUserByEmail("Mixed@Example.COM")
+	if assert.NoError(s.T(), err) {
+		assert.Equal(s.T(), user.ID, found.ID)
+	}
+	exists, err := UserExists("MIXED@example.com")
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), exists)
+
+	// Legacy accounts differing only in case: the exact match wins
+	legacy, err := CreateUser("Mixed@example.com", "password")
+	assert.NoError(s.T(), err)
+	found, err = GetUserByEmail("Mixed@example.com")
+	if assert.NoError(s.T(), err) {
+		assert.Equal(s.T(), legacy.ID, found.ID)
+	}
+	found, err = GetUserByEmail("mixed@example.com")
+	if assert.NoError(s.T(), err) {
+		assert.Equal(s.T(), user.ID, found.ID)
+	}
+}
+
+// TestUpgradeSchemaLowercasesEmails checks stored emails are normalized
+// unless that would collide with another account
+func (s *DatabaseTestSuite) TestUpgradeSchemaLowercasesEmails() {
+	alone, err := CreateUser("Alone@Example.com", "password")
+	assert.NoError(s.T(), err)
+	_, err = CreateUser("taken@example.com", "password")
+	assert.NoError(s.T(), err)
+	collides, err := CreateUser("Taken@example.com", "password")
+	assert.NoError(s.T(), err)
+	// Legacy duplicates with no lowercase account between them
+	first, err := CreateUser("Dup@example.com", "password")
+	assert.NoError(s.T(), err)
+	second, err := CreateUser("DUP@example.com", "password")
+	assert.NoError(s.T(), err)
+
+	assert.NoError(s.T(), initSchema(dbConn, s.dbType))
+
+	found, err := GetUserByID(alone.ID)
+	if assert.NoError(s.T(), err) {
+		assert.Equal(s.T(), "alone@example.com", found.Email)
+	}
+	found, err = GetUserByID(collides.ID)
+	if assert.NoError(s.T(), err) {
+		assert.Equal(s.T(), "Taken@example.com", found.Email, "a colliding address is left alone")
+	}
+	for _, user := range []*models.User{first, second} {
+		found, err = GetUserByID(user.ID)
+		if assert.NoError(s.T(), err) {
+			assert.Equal(s.T(), user.Email, found.Email, "addresses differing only in case are left alone")
+		}
+	}
+}
+
 // TestCreateAndGetToken tests token creation and retrieval
 func (s *DatabaseTestSuite) TestCreateAndGetToken() {
 	// Create user first
@@ -123,16 +242,15 @@ func (s *DatabaseTestSuite) TestCreateAndGetSession() {
 	// Create session
 	sessionToken := "test-session-token"
 	expiresAt := time.Now().Add(24 * time.Hour)
-	session, err := CreateSession(user.ID, sessionToken, expiresAt)
+	err := CreateSession(user.ID, sessionToken, expiresAt)
 	assert.NoError(s.T(), err)
-	assert.NotNil(s.T(), session)
-	assert.NotEmpty(s.T(), session.ID)
 
 	// Get session by token
 	retrievedSession, err := GetSessionByToken(sessionToken)
 	assert.NoError(s.T(), err)
 	assert.NotNil(s.T(), retrievedSession)
-	assert.Equal(s.T(), session.ID, retrievedSession.ID)
+	assert.NotEmpty(s.T(), retrievedSession.ID)
+	assert.Equal(s.T(), sessionToken, retrievedSession.Token)
 	assert.Equal(s.T(), user.ID, retrievedSession.UserID)
 }
 
@@ -156,14 +274,60 @@ func (s *DatabaseTestSuite) TestDeleteToken() {
 	assert.Error(s.T(), err)
 }
 
+// TestDeleteTokensByIDs tests bulk deletion is limited to the owner's tokens
+func (s *DatabaseTestSuite) TestDeleteTokensByIDs() {
+	owner, _ := CreateUser("bulkowner@example.com", "password")
+	other, _ := CreateUser("bulkother@example.com", "password")
+	first, _ := CreateToken(owner.ID, "first", "bulk-token-1", nil)
+	second, _ := CreateToken(owner.ID, "second", "bulk-token-2", nil)
+	kept, _ := CreateToken(owner.ID, "kept", "bulk-token-3", nil)
+	foreign, _ := CreateToken(other.ID, "foreign", "bulk-token-4", nil)
+
+	deleted, err := DeleteTokensByIDs(owner.ID, []string{first.ID, second.ID, foreign.ID, "non-existent-id"})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), int64(2), deleted)
+
+	remaining, err := GetUserTokens(owner.ID)
+	assert.NoError(s.T(), err)
+	if assert.Len(s.T(), remaining, 1) {
+		assert.Equal(s.T(), kept.ID, remaining[0].ID)
+	}
+	_, err = GetTokenByValue("bulk-token-4")
+	assert.NoError(s.T(), err, "another user's token must survive")
+
+	deleted, err = DeleteTokensByIDs(owner.ID, nil)
+	assert.NoError(s.T(), err)
+	assert.Zero(s.T(), deleted)
+}
+
+// TestDeleteUserTokens tests deleting all of one user's tokens
+func (s *DatabaseTestSuite) TestDeleteUserTokens() {
+	owner, _ := CreateUser("alltokens@example.com", "password")
+	other, _ := CreateUser("alltokensother@example.com", "password")
+	CreateToken(owner.ID, "first", "all-token-1", nil)
+	CreateToken(owner.ID, "second", "all-token-2", nil)
+	CreateToken(other.ID, "foreign", "all-token-3", nil)
+
+	deleted, err := DeleteUserTokens(owner.ID)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), int64(2), deleted)
+
+	remaining, err := GetUserTokens(owner.ID)
+	assert.NoError(s.T(), err)
+	assert.Empty(s.T(), remaining)
+	otherTokens, err := GetUserTokens(other.ID)
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), otherTokens, 1)
+}
+
 // TestDeleteSession tests session deletion
 func (s *DatabaseTestSuite) TestDeleteSession() {
 	// Setup: Create user and session
 	user, _ := CreateUser("deletesession@example.com", "password")
-	session, _ := CreateSession(user.ID, "session-to-delete", time.Now().Add(1*time.Hour))
+	CreateSession(user.ID, "session-to-delete", time.Now().Add(1*time.Hour))
 
 	// Delete session
-	err := DeleteSession(session.Token)
+	err := DeleteSession("session-to-delete")
 	assert.NoError(s.T(), err)
 
 	// Verify deletion
@@ -194,3 +358,150 @@ func (s *DatabaseTestSuite) TestCleanupExpiredSessions() {
 	assert.NoError(s.T(), err)
 	assert.NotNil(s.T(), validSession)
 }
+
+// TestConcurrentCreateSession hammers CreateSession from many goroutines to
+// make sure the connection pool serializes SQLite writes instead of failing
+func (s *DatabaseTestSuite) TestConcurrentCreateSession() {
+	user, err := CreateUser("concurrent@example.com", "password")
+	assert.NoError(s.T(), err)
+
+	const workers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- CreateSession(user.ID, fmt.Sprintf("concurrent-session-%d", i), time.Now().Add(time.Hour))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(s.T(), err)
+	}
+
+	for i := 0; i < workers; i++ {
+		_, err := GetSessionByToken(fmt.Sprintf("concurrent-session-%d", i))
+		assert.NoError(s.T(), err)
+	}
+
+	if s.dbType == "sqlite" {
+		assert.Equal(s.T(), 1, Stats().MaxOpenConnections)
+	}
+}
+
+// TestGetSessionsByUserID tests listing a user's active sessions
+func (s *DatabaseTestSuite) TestGetSessionsByUserID() {
+	user, _ := CreateUser("listsessions@example.com", "password")
+	other, _ := CreateUser("othersessions@example.com", "password")
+	CreateSession(user.ID, "list-session-one", time.Now().Add(time.Hour))
+	CreateSession(user.ID, "list-session-two", time.Now().Add(time.Hour))
+	CreateSession(user.ID, "list-session-expired", time.Now().Add(-time.Hour))
+	CreateSession(other.ID, "list-session-other", time.Now().Add(time.Hour))
+
+	sessions, err := GetSessionsByUserID(user.ID)
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), sessions, 2)
+	for _, session := range sessions {
+		assert.NotEmpty(s.T(), session.ID)
+		assert.Equal(s.T(), "list-s...", session.MaskedToken)
+		assert.True(s.T(), session.ExpiresAt.After(time.Now()))
+	}
+}
+
+// TestDeleteUserSession tests revoking a single session and keeping the others
+func (s *DatabaseTestSuite) TestDeleteUserSession() {
+	user, _ := CreateUser("revokesession@example.com", "password")
+	other, _ := CreateUser("revokeother@example.com", "password")
+	CreateSession(user.ID, "revoke-session-keep", time.Now().Add(time.Hour))
+	CreateSession(user.ID, "revoke-session-drop", time.Now().Add(time.Hour))
+	CreateSession(other.ID, "revoke-session-other", time.Now().Add(time.Hour))
+
+	keep, _ := GetSessionByToken("revoke-session-keep")
+	drop, _ := GetSessionByToken("revoke-session-drop")
+	foreign, _ := GetSessionByToken("revoke-session-other")
+
+	// Another user's session cannot be revoked
+	err := DeleteUserSession(user.ID, foreign.ID)
+	assert.ErrorIs(s.T(), err, sql.ErrNoRows)
+
+	err = DeleteUserSession(user.ID, drop.ID)
+	assert.NoError(s.T(), err)
+
+	_, err = GetSessionByToken("revoke-session-drop")
+	assert.Error(s.T(), err)
+	_, err = GetSessionByToken("revoke-session-keep")
+	assert.NoError(s.T(), err)
+
+	// Revoking everything else leaves only the kept session
+	CreateSession(user.ID, "revoke-session-extra", time.Now().Add(time.Hour))
+	revoked, err := DeleteOtherSessions(user.ID, keep.ID)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), int64(1), revoked)
+
+	sessions, err := GetSessionsByUserID(user.ID)
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), sessions, 1)
+	assert.Equal(s.T(), keep.ID, sessions[0].ID)
+
+	_, err = GetSessionByToken("revoke-session-other")
+	assert.NoError(s.T(), err)
+}
+
+// TestApplySchemaIsAtomic checks a failing schema query rolls back the ones
+// before it
+func (s *DatabaseTestSuite) TestApplySchemaIsAtomic() {
+	if s.dbType != "sqlite" {
+		s.T().Skip("uses a scratch SQLite database")
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(s.T().TempDir(), "atomic.db"))
+	assert.NoError(s.T(), err)
+	defer db.Close()
+
+	err = applySchema(db, "sqlite", []string{
+		`CREATE TABLE first_table (id TEXT PRIMARY KEY)`,
+		`CREATE TABLE broken (`,
+	})
+	assert.Error(s.T(), err)
+
+	var count int
+	assert.NoError(s.T(), db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'first_table'").Scan(&count))
+	assert.Zero(s.T(), count, "the first statement is rolled back")
+}
+
+// TestOpenReturnsIndependentDatabases checks two instances from Open share no
+// state with each other or with the one set up by Init
+func TestOpenReturnsIndependentDatabases(t *testing.T) {
+	open := func() *DB {
+		db, err := Open(&config.Config{DatabaseType: "sqlite", DatabasePath: filepath.Join(t.TempDir(), "test.db")})
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	first, second := open(), open()
+	require.NotSame(t, first.Conn(), second.Conn())
+
+	ctx := context.Background()
+	for _, db := range []*DB{first, second} {
+		_, err := db.Conn().Exec("INSERT INTO users (id, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+			"user-1", "user@example.com", "hash", time.Now(), time.Now())
+		require.NoError(t, err)
+	}
+	job := &models.Job{ID: "job-1", UserID: "user-1", JobID: "synthea-1", Status: models.JobStatusPending, OutputFormat: "fhir"}
+	require.NoError(t, first.CreateJob(ctx, job))
+
+	stored, err := first.GetJobByID(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "synthea-1", stored.JobID)
+	_, err = second.GetJobByID(ctx, job.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows, "the job exists only in the first database")
+
+	jobs, err := second.GetJobsByUserID(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+	user, err := second.GetUserByID(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "user@example.com", user.Email)
+}
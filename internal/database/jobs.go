@@ -1,54 +1,374 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/MediSynth-io/medisynth/internal/models"
 )
 
 // CreateJob creates a new job record
+//
+// Deprecated: use CreateJobContext so the insert stops with the request.
 func CreateJob(job *models.Job) error {
+	return CreateJobContext(context.Background(), job)
+}
+
+// CreateJobContext creates a new job record, giving up when ctx is done
+func CreateJobContext(ctx context.Context, job *models.Job) error {
+	return defaultDB.CreateJob(ctx, job)
+}
+
+// CreateJob creates a new job record, giving up when ctx is done
+func (db *DB) CreateJob(ctx context.Context, job *models.Job) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
 	var query string
-	if dbType == "postgres" {
-		query = "INSERT INTO jobs (id, user_id, job_id, status, parameters, output_format) VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at"
-		return dbConn.QueryRow(query, job.ID, job.UserID, job.JobID, job.Status, job.ParametersJSON, job.OutputFormat).Scan(&job.CreatedAt)
+	if db.dbType == "postgres" {
+		query = "INSERT INTO jobs (id, user_id, job_id, status, parameters, output_format, request_id) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created_at, updated_at"
+		return db.conn.QueryRowContext(ctx, query, job.ID, job.UserID, job.JobID, job.Status, job.ParametersJSON, job.OutputFormat, job.RequestID).Scan(&job.CreatedAt, &job.UpdatedAt)
 	}
 
-	query = "INSERT INTO jobs (id, user_id, job_id, status, parameters, output_format, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
-	_, err := dbConn.Exec(query, job.ID, job.UserID, job.JobID, job.Status, job.ParametersJSON, job.OutputFormat, job.CreatedAt)
+	// SQLite has no column defaults for the timestamps, so stamp them here
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
+	job.UpdatedAt = job.CreatedAt
+	query = "INSERT INTO jobs (id, user_id, job_id, status, parameters, output_format, request_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err := db.conn.ExecContext(ctx, query, job.ID, job.UserID, job.JobID, job.Status, job.ParametersJSON, job.OutputFormat, job.RequestID, job.CreatedAt, job.UpdatedAt)
 	return err
 }
 
-// UpdateJobStatus updates the status and result of a job
+// UpdateJobStatus updates the status and result of a job. Moving to running
+// stamps started_at and heartbeat_at; completed_at is stamped when the job
+// finishes and cleared otherwise.
 func UpdateJobStatus(jobID string, status models.JobStatus, errorMessage *string, outputPath *string, outputSize *int64, patientCount *int) error {
+	return defaultDB.UpdateJobStatus(context.Background(), jobID, status, errorMessage, outputPath, outputSize, patientCount)
+}
+
+// UpdateJobStatus is the package-level UpdateJobStatus on this database,
+// giving up when ctx is done
+func (db *DB) UpdateJobStatus(ctx context.Context, jobID string, status models.JobStatus, errorMessage *string, outputPath *string, outputSize *int64, patientCount *int) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
 	var query string
 	var err error
+	started := status == models.JobStatusRunning
+	finished := status.IsTerminal()
 
-	if dbType == "postgres" {
-		query = "UPDATE jobs SET status = $1, error_message = $2, output_path = $3, output_size = $4, patient_count = $5, completed_at = NOW() WHERE id = $6"
-		_, err = dbConn.Exec(query, status, errorMessage, outputPath, outputSize, patientCount, jobID)
+	if db.dbType == "postgres" {
+		query = `UPDATE jobs SET status = $1, error_message = $2, output_path = $3, output_size = $4, patient_count = $5,
+			started_at = CASE WHEN $6 THEN NOW() ELSE started_at END, heartbeat_at = CASE WHEN $6 THEN NOW() ELSE heartbeat_at END,
+			completed_at = CASE WHEN $7 THEN NOW() END, updated_at = NOW() WHERE id = $8`
+		_, err = db.conn.ExecContext(ctx, query, status, errorMessage, outputPath, outputSize, patientCount, started, finished, jobID)
 	} else {
-		query = "UPDATE jobs SET status = ?, error_message = ?, output_path = ?, output_size = ?, patient_count = ?, completed_at = ? WHERE id = ?"
-		_, err = dbConn.Exec(query, status, errorMessage, outputPath, outputSize, patientCount, time.Now(), jobID)
+		now := time.Now()
+		var startedAt, completedAt *time.Time
+		if started {
+			startedAt = &now
+		}
+		if finished {
+			completedAt = &now
+		}
+		query = `UPDATE jobs SET status = ?, error_message = ?, output_path = ?, output_size = ?, patient_count = ?,
+			started_at = COALESCE(?, started_at), heartbeat_at = COALESCE(?, heartbeat_at), completed_at = ?, updated_at = ? WHERE id = ?`
+		_, err = db.conn.ExecContext(ctx, query, status, errorMessage, outputPath, outputSize, patientCount, startedAt, startedAt, completedAt, now, jobID)
+	}
+
+	if err == nil {
+		notifyJobWatchers(jobID)
+	}
+	return err
+}
+
+// ClaimJob moves a pending job to running, recording outputPath and stamping
+// started_at and heartbeat_at. It reports false when the job is no longer
+// pending, for example because another API instance claimed it first.
+func ClaimJob(jobID string, outputPath *string) (bool, error) {
+	return defaultDB.ClaimJob(context.Background(), jobID, outputPath)
+}
+
+// ClaimJob moves a pending job to running, recording outputPath and stamping
+// started_at and heartbeat_at, giving up when ctx is done. It reports false
+// when the job is no longer pending.
+func (db *DB) ClaimJob(ctx context.Context, jobID string, outputPath *string) (bool, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `UPDATE jobs SET status = ?, output_path = ?, started_at = ?, heartbeat_at = ?, updated_at = ?
+		WHERE id = ? AND status = ?`
+	if db.dbType == "postgres" {
+		query = `UPDATE jobs SET status = $1, output_path = $2, started_at = $3, heartbeat_at = $4, updated_at = $5
+		WHERE id = $6 AND status = $7`
+	}
+	now := time.Now()
+	result, err := db.conn.ExecContext(ctx, query, models.JobStatusRunning, outputPath, now, now, now, jobID, models.JobStatusPending)
+	if err != nil {
+		return false, err
 	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if claimed > 0 {
+		notifyJobWatchers(jobID)
+	}
+	return claimed > 0, nil
+}
+
+// TouchJobHeartbeat records that the process running the job is still alive
+func TouchJobHeartbeat(jobID string) error {
+	return defaultDB.TouchJobHeartbeat(context.Background(), jobID)
+}
 
+// TouchJobHeartbeat records that the process running the job is still alive,
+// giving up when ctx is done
+func (db *DB) TouchJobHeartbeat(ctx context.Context, jobID string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := "UPDATE jobs SET heartbeat_at = ? WHERE id = ? AND status = ?"
+	if db.dbType == "postgres" {
+		query = "UPDATE jobs SET heartbeat_at = $1 WHERE id = $2 AND status = $3"
+	}
+	_, err := db.conn.ExecContext(ctx, query, time.Now(), jobID, models.JobStatusRunning)
 	return err
 }
 
+var (
+	jobWatchersMu sync.Mutex
+	jobWatchers   = map[string]map[chan struct{}]bool{}
+)
+
+// WatchJob returns a channel that is closed the next time UpdateJobStatus
+// changes the job, and a function to call when no longer waiting. Only
+// updates made by this process are seen.
+func WatchJob(jobID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{})
+
+	jobWatchersMu.Lock()
+	if jobWatchers[jobID] == nil {
+		jobWatchers[jobID] = map[chan struct{}]bool{}
+	}
+	jobWatchers[jobID][ch] = true
+	jobWatchersMu.Unlock()
+
+	stop := func() {
+		jobWatchersMu.Lock()
+		defer jobWatchersMu.Unlock()
+		if watchers := jobWatchers[jobID]; watchers[ch] {
+			delete(watchers, ch)
+			if len(watchers) == 0 {
+				delete(jobWatchers, jobID)
+			}
+		}
+	}
+	return ch, stop
+}
+
+// notifyJobWatchers wakes everything waiting on WatchJob for the job
+func notifyJobWatchers(jobID string) {
+	jobWatchersMu.Lock()
+	defer jobWatchersMu.Unlock()
+	for ch := range jobWatchers[jobID] {
+		close(ch)
+	}
+	delete(jobWatchers, jobID)
+}
+
 // GetJobByID retrieves a job by its ID
+//
+// Deprecated: use GetJobByIDContext so the query stops with the request.
 func GetJobByID(id string) (*models.Job, error) {
-	job := &models.Job{}
-	var query string
-	if dbType == "postgres" {
-		query = "SELECT id, user_id, job_id, status, parameters, output_format, output_path, output_size, patient_count, error_message, created_at, completed_at FROM jobs WHERE id = $1"
-	} else {
-		query = "SELECT id, user_id, job_id, status, parameters, output_format, output_path, output_size, patient_count, error_message, created_at, completed_at FROM jobs WHERE id = ?"
+	return GetJobByIDContext(context.Background(), id)
+}
+
+// GetJobByIDContext retrieves a job by its ID, giving up when ctx is done
+func GetJobByIDContext(ctx context.Context, id string) (*models.Job, error) {
+	return defaultDB.GetJobByID(ctx, id)
+}
+
+// GetJobByID retrieves a job by its ID, giving up when ctx is done
+func (db *DB) GetJobByID(ctx context.Context, id string) (*models.Job, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := "SELECT " + jobColumns + " FROM jobs WHERE id = ?"
+	if db.dbType == "postgres" {
+		query = "SELECT " + jobColumns + " FROM jobs WHERE id = $1"
+	}
+	return scanJob(db.conn.QueryRowContext(ctx, query, id))
+}
+
+// DeleteJob deletes the user's job. It returns sql.ErrNoRows when the job does
+// not exist or belongs to another user.
+func DeleteJob(userID string, jobID string) error {
+	return defaultDB.DeleteJob(context.Background(), userID, jobID)
+}
+
+// DeleteJob deletes the user's job, giving up when ctx is done. It returns
+// sql.ErrNoRows when the job does not exist or belongs to another user.
+func (db *DB) DeleteJob(ctx context.Context, userID string, jobID string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := "DELETE FROM jobs WHERE id = ? AND user_id = ?"
+	if db.dbType == "postgres" {
+		query = "DELETE FROM jobs WHERE id = $1 AND user_id = $2"
+	}
+	result, err := db.conn.ExecContext(ctx, query, jobID, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetJobKeepOutput sets whether the user's job is exempt from output
+// retention. It returns sql.ErrNoRows when the job does not exist or belongs
+// to another user.
+func SetJobKeepOutput(userID string, jobID string, keep bool) error {
+	return defaultDB.SetJobKeepOutput(context.Background(), userID, jobID, keep)
+}
+
+// SetJobKeepOutput sets whether the user's job is exempt from output
+// retention, giving up when ctx is done. It returns sql.ErrNoRows when the
+// job does not exist or belongs to another user.
+func (db *DB) SetJobKeepOutput(ctx context.Context, userID string, jobID string, keep bool) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := "UPDATE jobs SET keep_output = ?, updated_at = ? WHERE id = ? AND user_id = ?"
+	if db.dbType == "postgres" {
+		query = "UPDATE jobs SET keep_output = $1, updated_at = $2 WHERE id = $3 AND user_id = $4"
+	}
+	result, err := db.conn.ExecContext(ctx, query, keep, time.Now(), jobID, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetJobsWithExpiredOutput returns completed jobs that finished before cutoff
+// and still have output, oldest first. Jobs marked keep_output are skipped.
+func GetJobsWithExpiredOutput(cutoff time.Time) ([]*models.Job, error) {
+	return defaultDB.GetJobsWithExpiredOutput(context.Background(), cutoff)
+}
+
+// GetJobsWithExpiredOutput returns completed jobs that finished before cutoff
+// and still have output, oldest first, giving up when ctx is done. Jobs
+// marked keep_output are skipped.
+func (db *DB) GetJobsWithExpiredOutput(ctx context.Context, cutoff time.Time) ([]*models.Job, error) {
+	if db.dbType == "postgres" {
+		return db.queryJobs(ctx, "SELECT "+jobColumns+" FROM jobs WHERE status = $1 AND completed_at < $2 AND output_path IS NOT NULL AND NOT keep_output ORDER BY completed_at ASC",
+			models.JobStatusCompleted, cutoff)
+	}
+	return db.queryJobs(ctx, "SELECT "+jobColumns+" FROM jobs WHERE status = ? AND completed_at < ? AND output_path IS NOT NULL AND NOT keep_output ORDER BY completed_at ASC",
+		models.JobStatusCompleted, db.filterTime(cutoff))
+}
+
+// ClearJobOutput records that a job's output has been deleted by clearing
+// its output path. The rest of the job is kept for history.
+func ClearJobOutput(jobID string) error {
+	return defaultDB.ClearJobOutput(context.Background(), jobID)
+}
+
+// ClearJobOutput clears the job's output path, giving up when ctx is done
+func (db *DB) ClearJobOutput(ctx context.Context, jobID string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := "UPDATE jobs SET output_path = NULL, updated_at = ? WHERE id = ?"
+	if db.dbType == "postgres" {
+		query = "UPDATE jobs SET output_path = NULL, updated_at = $1 WHERE id = $2"
+	}
+	_, err := db.conn.ExecContext(ctx, query, time.Now(), jobID)
+	return err
+}
+
+// GetJobsByUserID retrieves all jobs for a user
+//
+// Deprecated: use GetJobsByUserIDContext so the query stops with the request.
+func GetJobsByUserID(userID string) ([]*models.Job, error) {
+	return GetJobsByUserIDContext(context.Background(), userID)
+}
+
+// GetJobsByUserIDContext retrieves all jobs for a user, newest first, giving
+// up when ctx is done
+func GetJobsByUserIDContext(ctx context.Context, userID string) ([]*models.Job, error) {
+	return defaultDB.GetJobsByUserID(ctx, userID)
+}
+
+// GetJobsByUserID retrieves all jobs for a user, newest first, giving up when
+// ctx is done
+func (db *DB) GetJobsByUserID(ctx context.Context, userID string) ([]*models.Job, error) {
+	if db.dbType == "postgres" {
+		return db.queryJobs(ctx, "SELECT "+jobColumns+" FROM jobs WHERE user_id = $1 ORDER BY created_at DESC", userID)
+	}
+	return db.queryJobs(ctx, "SELECT "+jobColumns+" FROM jobs WHERE user_id = ? ORDER BY created_at DESC", userID)
+}
+
+// GetJobsByStatus retrieves all jobs in the given status, oldest first
+func GetJobsByStatus(status models.JobStatus) ([]*models.Job, error) {
+	return defaultDB.GetJobsByStatus(context.Background(), status)
+}
+
+// GetJobsByStatus retrieves all jobs in the given status, oldest first,
+// giving up when ctx is done
+func (db *DB) GetJobsByStatus(ctx context.Context, status models.JobStatus) ([]*models.Job, error) {
+	if db.dbType == "postgres" {
+		return db.queryJobs(ctx, "SELECT "+jobColumns+" FROM jobs WHERE status = $1 ORDER BY created_at ASC", status)
 	}
+	return db.queryJobs(ctx, "SELECT "+jobColumns+" FROM jobs WHERE status = ? ORDER BY created_at ASC", status)
+}
+
+// GetStaleRunningJobs returns running jobs whose last heartbeat is older than
+// cutoff, oldest first. Jobs that started before heartbeats were recorded
+// fall back to when they last changed.
+func (db *DB) GetStaleRunningJobs(ctx context.Context, cutoff time.Time) ([]*models.Job, error) {
+	if db.dbType == "postgres" {
+		return db.queryJobs(ctx, "SELECT "+jobColumns+" FROM jobs WHERE status = $1 AND COALESCE(heartbeat_at, updated_at) < $2 ORDER BY created_at ASC",
+			models.JobStatusRunning, cutoff)
+	}
+	return db.queryJobs(ctx, "SELECT "+jobColumns+" FROM jobs WHERE status = ? AND COALESCE(heartbeat_at, updated_at) < ? ORDER BY created_at ASC",
+		models.JobStatusRunning, db.filterTime(cutoff))
+}
+
+// jobColumns is the column list scanned by scanJob
+const jobColumns = "id, user_id, job_id, status, parameters, output_format, output_path, output_size, patient_count, error_message, created_at, started_at, completed_at, updated_at, keep_output, request_id"
 
-	err := dbConn.QueryRow(query, id).Scan(
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanJob scans one row of jobColumns
+func scanJob(row rowScanner) (*models.Job, error) {
+	job := &models.Job{}
+	err := row.Scan(
 		&job.ID, &job.UserID, &job.JobID, &job.Status, &job.ParametersJSON, &job.OutputFormat,
-		&job.OutputPath, &job.OutputSize, &job.PatientCount, &job.ErrorMessage, &job.CreatedAt, &job.CompletedAt,
+		&job.OutputPath, &job.OutputSize, &job.PatientCount, &job.ErrorMessage, &job.CreatedAt, &job.StartedAt, &job.CompletedAt,
+		&job.UpdatedAt, &job.KeepOutput, &job.RequestID,
 	)
 	if err != nil {
 		return nil, err
@@ -57,20 +377,16 @@ func GetJobByID(id string) (*models.Job, error) {
 	if err := job.UnmarshalParameters(); err != nil {
 		log.Printf("Warning: could not unmarshal job parameters for job %s: %v", job.ID, err)
 	}
-
 	return job, nil
 }
 
-// GetJobsByUserID retrieves all jobs for a user
-func GetJobsByUserID(userID string) ([]*models.Job, error) {
-	var query string
-	if dbType == "postgres" {
-		query = "SELECT id, user_id, job_id, status, parameters, output_format, output_path, patient_count, error_message, created_at, completed_at FROM jobs WHERE user_id = $1 ORDER BY created_at DESC"
-	} else {
-		query = "SELECT id, user_id, job_id, status, parameters, output_format, output_path, patient_count, error_message, created_at, completed_at FROM jobs WHERE user_id = ? ORDER BY created_at DESC"
-	}
+// queryJobs runs a SELECT of jobColumns and scans every row into a job,
+// giving up when ctx is done
+func (db *DB) queryJobs(ctx context.Context, query string, args ...interface{}) ([]*models.Job, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
 
-	rows, err := dbConn.Query(query, userID)
+	rows, err := db.conn.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -78,19 +394,10 @@ func GetJobsByUserID(userID string) ([]*models.Job, error) {
 
 	var jobs []*models.Job
 	for rows.Next() {
-		job := &models.Job{}
-		err := rows.Scan(
-			&job.ID, &job.UserID, &job.JobID, &job.Status, &job.ParametersJSON, &job.OutputFormat,
-			&job.OutputPath, &job.PatientCount, &job.ErrorMessage, &job.CreatedAt, &job.CompletedAt,
-		)
+		job, err := scanJob(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		if err := job.UnmarshalParameters(); err != nil {
-			log.Printf("Warning: could not unmarshal job parameters for job %s: %v", job.ID, err)
-		}
-
 		jobs = append(jobs, job)
 	}
 
@@ -100,3 +407,201 @@ func GetJobsByUserID(userID string) ([]*models.Job, error) {
 
 	return jobs, nil
 }
+
+// likeEscaper escapes LIKE wildcards so user input is matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchJobs returns the user's jobs whose state, city, output format or
+// status contain query (case-insensitive), newest first. An empty query
+// returns all of the user's jobs.
+//
+// Deprecated: use SearchJobsContext so the query stops with the request.
+func SearchJobs(userID string, query string) ([]*models.Job, error) {
+	return SearchJobsContext(context.Background(), userID, query)
+}
+
+// SearchJobsContext is SearchJobs giving up when ctx is done
+func SearchJobsContext(ctx context.Context, userID string, query string) ([]*models.Job, error) {
+	return defaultDB.SearchJobs(ctx, userID, query)
+}
+
+// SearchJobs returns the user's jobs whose state, city, output format or
+// status contain query (case-insensitive), newest first, giving up when ctx
+// is done. An empty query returns all of the user's jobs.
+func (db *DB) SearchJobs(ctx context.Context, userID string, query string) ([]*models.Job, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return db.GetJobsByUserID(ctx, userID)
+	}
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+
+	if db.dbType == "postgres" {
+		return db.queryJobs(ctx, `SELECT `+jobColumns+` FROM jobs WHERE user_id = $1 AND (
+			parameters->>'state' ILIKE $2 OR
+			parameters->>'city' ILIKE $2 OR
+			output_format ILIKE $2 OR
+			status ILIKE $2
+		) ORDER BY created_at DESC`, userID, pattern)
+	}
+
+	return db.queryJobs(ctx, `SELECT `+jobColumns+` FROM jobs WHERE user_id = ? AND (
+		json_extract(parameters, '$.state') LIKE ? ESCAPE '\' OR
+		json_extract(parameters, '$.city') LIKE ? ESCAPE '\' OR
+		output_format LIKE ? ESCAPE '\' OR
+		status LIKE ? ESCAPE '\'
+	) ORDER BY created_at DESC`, userID, pattern, pattern, pattern, pattern)
+}
+
+// GetJobsFiltered returns the user's jobs, newest first, optionally limited
+// to one status and to jobs created in [from, to). Empty status and nil
+// bounds apply no restriction.
+//
+// Deprecated: use GetJobsFilteredContext so the query stops with the request.
+func GetJobsFiltered(userID string, status string, from, to *time.Time) ([]*models.Job, error) {
+	return GetJobsFilteredContext(context.Background(), userID, status, from, to)
+}
+
+// GetJobsFilteredContext is GetJobsFiltered giving up when ctx is done
+func GetJobsFilteredContext(ctx context.Context, userID string, status string, from, to *time.Time) ([]*models.Job, error) {
+	return defaultDB.GetJobsFiltered(ctx, userID, status, from, to)
+}
+
+// GetJobsFiltered returns the user's jobs, newest first, optionally limited
+// to one status and to jobs created in [from, to), giving up when ctx is done
+func (db *DB) GetJobsFiltered(ctx context.Context, userID string, status string, from, to *time.Time) ([]*models.Job, error) {
+	conditions := []string{"user_id = %s"}
+	args := []interface{}{userID}
+	if status != "" {
+		conditions = append(conditions, "status = %s")
+		args = append(args, status)
+	}
+	if from != nil {
+		conditions = append(conditions, "created_at >= %s")
+		args = append(args, db.filterTime(*from))
+	}
+	if to != nil {
+		conditions = append(conditions, "created_at < %s")
+		args = append(args, db.filterTime(*to))
+	}
+
+	for i := range conditions {
+		placeholder := "?"
+		if db.dbType == "postgres" {
+			placeholder = fmt.Sprintf("$%d", i+1)
+		}
+		conditions[i] = fmt.Sprintf(conditions[i], placeholder)
+	}
+
+	return db.queryJobs(ctx, "SELECT "+jobColumns+" FROM jobs WHERE "+strings.Join(conditions, " AND ")+" ORDER BY created_at DESC", args...)
+}
+
+// filterTime prepares a time bound for comparison with created_at or
+// completed_at. SQLite compares timestamps as text, so bounds must use the
+// same zone as the stored values, which are stamped in local time.
+func (db *DB) filterTime(t time.Time) time.Time {
+	if db.dbType == "postgres" {
+		return t
+	}
+	return t.Local()
+}
+
+// GetUserPatientCountSince returns the number of patients generated by the
+// user's jobs that completed at or after since
+func GetUserPatientCountSince(userID string, since time.Time) (int, error) {
+	return defaultDB.GetUserPatientCountSince(context.Background(), userID, since)
+}
+
+// GetUserPatientCountSince returns the number of patients generated by the
+// user's jobs that completed at or after since, giving up when ctx is done
+func (db *DB) GetUserPatientCountSince(ctx context.Context, userID string, since time.Time) (int, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := "SELECT COALESCE(SUM(patient_count), 0) FROM jobs WHERE user_id = ? AND status = ? AND completed_at >= ?"
+	if db.dbType == "postgres" {
+		query = "SELECT COALESCE(SUM(patient_count), 0) FROM jobs WHERE user_id = $1 AND status = $2 AND completed_at >= $3"
+	}
+
+	var count int
+	if err := db.conn.QueryRowContext(ctx, query, userID, models.JobStatusCompleted, db.filterTime(since)).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetUserReservedPatientCount returns the population requested by the user's
+// pending and running jobs created at or after since. Those patients are not
+// generated yet, but a quota check must count them so that jobs submitted
+// together cannot exceed it.
+func GetUserReservedPatientCount(ctx context.Context, userID string, since time.Time) (int, error) {
+	return defaultDB.GetUserReservedPatientCount(ctx, userID, since)
+}
+
+// GetUserReservedPatientCount is the package-level GetUserReservedPatientCount
+// on this database
+func (db *DB) GetUserReservedPatientCount(ctx context.Context, userID string, since time.Time) (int, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT COALESCE(SUM(CAST(json_extract(parameters, '$.population') AS INTEGER)), 0) FROM jobs
+		WHERE user_id = ? AND status IN (?, ?) AND created_at >= ?`
+	if db.dbType == "postgres" {
+		query = `SELECT COALESCE(SUM((parameters->>'population')::INTEGER), 0) FROM jobs
+		WHERE user_id = $1 AND status IN ($2, $3) AND created_at >= $4`
+	}
+
+	var count int
+	err := db.conn.QueryRowContext(ctx, query, userID, models.JobStatusPending, models.JobStatusRunning, db.filterTime(since)).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// SetJobDuration records how long the job took to run
+func SetJobDuration(jobID string, duration time.Duration) error {
+	return defaultDB.SetJobDuration(context.Background(), jobID, duration)
+}
+
+// SetJobDuration records how long the job took to run, giving up when ctx is
+// done
+func (db *DB) SetJobDuration(ctx context.Context, jobID string, duration time.Duration) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := "UPDATE jobs SET duration_ms = ? WHERE id = ?"
+	if db.dbType == "postgres" {
+		query = "UPDATE jobs SET duration_ms = $1 WHERE id = $2"
+	}
+	_, err := db.conn.ExecContext(ctx, query, duration.Milliseconds(), jobID)
+	return err
+}
+
+// GetAverageJobDuration estimates how long a job generating population
+// patients in format takes, from the average time per patient of completed
+// jobs in that format with a recorded duration. It also returns how many jobs
+// the average is based on; with none the duration is 0.
+func GetAverageJobDuration(format string, population int) (time.Duration, int, error) {
+	return defaultDB.GetAverageJobDuration(context.Background(), format, population)
+}
+
+// GetAverageJobDuration is the package-level GetAverageJobDuration on this
+// database, giving up when ctx is done
+func (db *DB) GetAverageJobDuration(ctx context.Context, format string, population int) (time.Duration, int, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT COUNT(*), COALESCE(AVG(CAST(duration_ms AS REAL) / patient_count), 0) FROM jobs
+		WHERE output_format = ? AND status = ? AND duration_ms IS NOT NULL AND patient_count > 0`
+	if db.dbType == "postgres" {
+		query = `SELECT COUNT(*), COALESCE(AVG(duration_ms::DOUBLE PRECISION / patient_count), 0) FROM jobs
+		WHERE output_format = $1 AND status = $2 AND duration_ms IS NOT NULL AND patient_count > 0`
+	}
+
+	var samples int
+	var msPerPatient float64
+	if err := db.conn.QueryRowContext(ctx, query, format, models.JobStatusCompleted).Scan(&samples, &msPerPatient); err != nil {
+		return 0, 0, err
+	}
+	return time.Duration(msPerPatient * float64(population) * float64(time.Millisecond)), samples, nil
+}
@@ -0,0 +1,531 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCreateAndListJobs inserts jobs and reads them back through GetJobsByUserID
+func (s *DatabaseTestSuite) TestCreateAndListJobs() {
+	user, err := CreateUser("jobsuser@example.com", "password")
+	assert.NoError(s.T(), err)
+
+	older := &models.Job{
+		ID:           "job-older",
+		UserID:       user.ID,
+		JobID:        "synthea-older",
+		Status:       models.JobStatusPending,
+		Parameters:   map[string]interface{}{"population": 10, "state": "MA"},
+		OutputFormat: "fhir",
+	}
+	assert.NoError(s.T(), older.MarshalParameters())
+	assert.NoError(s.T(), CreateJob(older))
+	assert.False(s.T(), older.CreatedAt.IsZero(), "created_at should be set on insert")
+
+	time.Sleep(10 * time.Millisecond)
+	newer := &models.Job{
+		ID:           "job-newer",
+		UserID:       user.ID,
+		JobID:        "synthea-newer",
+		Status:       models.JobStatusPending,
+		Parameters:   map[string]interface{}{"population": 25},
+		OutputFormat: "csv",
+	}
+	assert.NoError(s.T(), newer.MarshalParameters())
+	assert.NoError(s.T(), CreateJob(newer))
+
+	outputPath := "synthea_output/synthea-older/"
+	patients := 10
+	assert.NoError(s.T(), UpdateJobStatus(older.ID, models.JobStatusCompleted, nil, &outputPath, nil, &patients))
+
+	jobs, err := GetJobsByUserID(user.ID)
+	assert.NoError(s.T(), err)
+	if assert.Len(s.T(), jobs, 2) {
+		assert.Equal(s.T(), newer.ID, jobs[0].ID, "jobs should be newest first")
+		assert.Equal(s.T(), "csv", jobs[0].OutputFormat)
+
+		assert.Equal(s.T(), older.ID, jobs[1].ID)
+		assert.Equal(s.T(), models.JobStatusCompleted, jobs[1].Status)
+		assert.Equal(s.T(), "MA", jobs[1].Parameters["state"])
+		if assert.NotNil(s.T(), jobs[1].OutputPath) {
+			assert.Equal(s.T(), outputPath, *jobs[1].OutputPath)
+		}
+		if assert.NotNil(s.T(), jobs[1].PatientCount) {
+			assert.Equal(s.T(), patients, *jobs[1].PatientCount)
+		}
+	}
+}
+
+// TestSearchJobs matches jobs on parameters, format and status for one user only
+func (s *DatabaseTestSuite) TestSearchJobs() {
+	user, err := CreateUser("searchuser@example.com", "password")
+	assert.NoError(s.T(), err)
+	other, err := CreateUser("searchother@example.com", "password")
+	assert.NoError(s.T(), err)
+
+	newJob := func(id, userID, format string, params map[string]interface{}) *models.Job {
+		job := &models.Job{
+			ID:           id,
+			UserID:       userID,
+			JobID:        "synthea-" + id,
+			Status:       models.JobStatusPending,
+			Parameters:   params,
+			OutputFormat: format,
+		}
+		assert.NoError(s.T(), job.MarshalParameters())
+		assert.NoError(s.T(), CreateJob(job))
+		time.Sleep(10 * time.Millisecond)
+		return job
+	}
+
+	boston := newJob("job-search-boston", user.ID, "fhir", map[string]interface{}{"population": 10, "state": "Massachusetts", "city": "Boston"})
+	austin := newJob("job-search-austin", user.ID, "csv", map[string]interface{}{"population": 10, "state": "Texas", "city": "Austin"})
+	dallas := newJob("job-search-dallas", user.ID, "csv", map[string]interface{}{"population": 10, "state": "Texas", "city": "Dallas"})
+	newJob("job-search-other", other.ID, "csv", map[string]interface{}{"population": 10, "state": "Texas"})
+
+	jobs, err := SearchJobs(user.ID, "texas")
+	assert.NoError(s.T(), err)
+	if assert.Len(s.T(), jobs, 2) {
+		assert.Equal(s.T(), dallas.ID, jobs[0].ID, "results should be newest first")
+		assert.Equal(s.T(), austin.ID, jobs[1].ID)
+	}
+
+	jobs, err = SearchJobs(user.ID, "CSV")
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), jobs, 2)
+
+	jobs, err = SearchJobs(user.ID, "bost")
+	assert.NoError(s.T(), err)
+	if assert.Len(s.T(), jobs, 1) {
+		assert.Equal(s.T(), boston.ID, jobs[0].ID)
+	}
+
+	jobs, err = SearchJobs(user.ID, "pending")
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), jobs, 3)
+
+	// Only the state and city parameters are searched, not key names or
+	// other values
+	for _, query := range []string{"population", "city", "10"} {
+		jobs, err = SearchJobs(user.ID, query)
+		assert.NoError(s.T(), err)
+		assert.Empty(s.T(), jobs, query)
+	}
+
+	jobs, err = SearchJobs(user.ID, "100%")
+	assert.NoError(s.T(), err)
+	assert.Empty(s.T(), jobs)
+
+	jobs, err = SearchJobs(user.ID, "")
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), jobs, 3)
+}
+
+// TestGetJobsFiltered narrows a user's jobs by status and creation date
+func (s *DatabaseTestSuite) TestGetJobsFiltered() {
+	user, err := CreateUser("filteruser@example.com", "password")
+	assert.NoError(s.T(), err)
+	other, err := CreateUser("filterother@example.com", "password")
+	assert.NoError(s.T(), err)
+
+	now := time.Now()
+	newJob := func(id, userID string, status models.JobStatus, createdAt time.Time) *models.Job {
+		job := &models.Job{
+			ID:           id,
+			UserID:       userID,
+			JobID:        "synthea-" + id,
+			Status:       status,
+			Parameters:   map[string]interface{}{"population": 10},
+			OutputFormat: "fhir",
+		}
+		assert.NoError(s.T(), job.MarshalParameters())
+		assert.NoError(s.T(), CreateJob(job))
+
+		// Backdate the job; Postgres stamps created_at itself on insert
+		query := "UPDATE jobs SET created_at = ? WHERE id = ?"
+		if dbType == "postgres" {
+			query = "UPDATE jobs SET created_at = $1 WHERE id = $2"
+		}
+		_, err := dbConn.Exec(query, createdAt, id)
+		assert.NoError(s.T(), err)
+		return job
+	}
+
+	lastWeek := newJob("job-filter-lastweek", user.ID, models.JobStatusFailed, now.AddDate(0, 0, -7))
+	yesterday := newJob("job-filter-yesterday", user.ID, models.JobStatusCompleted, now.AddDate(0, 0, -1))
+	today := newJob("job-filter-today", user.ID, models.JobStatusFailed, now)
+	newJob("job-filter-other", other.ID, models.JobStatusFailed, now)
+
+	jobs, err := GetJobsFiltered(user.ID, "", nil, nil)
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), jobs, 3)
+
+	jobs, err = GetJobsFiltered(user.ID, string(models.JobStatusFailed), nil, nil)
+	assert.NoError(s.T(), err)
+	if assert.Len(s.T(), jobs, 2) {
+		assert.Equal(s.T(), today.ID, jobs[0].ID, "results should be newest first")
+		assert.Equal(s.T(), lastWeek.ID, jobs[1].ID)
+	}
+
+	from := now.AddDate(0, 0, -2)
+	jobs, err = GetJobsFiltered(user.ID, "", &from, nil)
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), jobs, 2)
+
+	to := now.Add(-time.Hour)
+	jobs, err = GetJobsFiltered(user.ID, "", &from, &to)
+	assert.NoError(s.T(), err)
+	if assert.Len(s.T(), jobs, 1) {
+		assert.Equal(s.T(), yesterday.ID, jobs[0].ID)
+	}
+
+	jobs, err = GetJobsFiltered(user.ID, string(models.JobStatusFailed), &from, &to)
+	assert.NoError(s.T(), err)
+	assert.Empty(s.T(), jobs)
+}
+
+// TestWatchJob checks that UpdateJobStatus wakes watchers of that job only
+func (s *DatabaseTestSuite) TestWatchJob() {
+	user, err := CreateUser("watchuser@example.com", "password")
+	assert.NoError(s.T(), err)
+	for _, id := range []string{"job-watched", "job-other"} {
+		job := &models.Job{ID: id, UserID: user.ID, JobID: "synthea-" + id, Status: models.JobStatusPending, OutputFormat: "fhir"}
+		assert.NoError(s.T(), job.MarshalParameters())
+		assert.NoError(s.T(), CreateJob(job))
+	}
+
+	changed, stop := WatchJob("job-watched")
+	defer stop()
+	stopped, stopWatching := WatchJob("job-watched")
+	stopWatching()
+
+	assert.NoError(s.T(), UpdateJobStatus("job-other", models.JobStatusRunning, nil, nil, nil, nil))
+	select {
+	case <-changed:
+		s.T().Fatal("an update to another job must not wake the watcher")
+	default:
+	}
+
+	assert.NoError(s.T(), UpdateJobStatus("job-watched", models.JobStatusCompleted, nil, nil, nil, nil))
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		s.T().Fatal("watcher was not notified")
+	}
+	select {
+	case <-stopped:
+		s.T().Fatal("a stopped watcher must not be notified")
+	default:
+	}
+}
+
+// TestJobUpdatedAt checks updated_at is stamped on insert and moved by status updates
+func (s *DatabaseTestSuite) TestJobUpdatedAt() {
+	user, err := CreateUser("updatedat@example.com", "password")
+	assert.NoError(s.T(), err)
+
+	job := &models.Job{ID: "job-updated", UserID: user.ID, JobID: "synthea-updated", Status: models.JobStatusPending, OutputFormat: "fhir"}
+	assert.NoError(s.T(), job.MarshalParameters())
+	assert.NoError(s.T(), CreateJob(job))
+
+	stored, err := GetJobByID(job.ID)
+	if assert.NoError(s.T(), err) {
+		assert.WithinDuration(s.T(), stored.CreatedAt, stored.UpdatedAt, time.Second)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(s.T(), UpdateJobStatus(job.ID, models.JobStatusRunning, nil, nil, nil, nil))
+	updated, err := GetJobByID(job.ID)
+	if assert.NoError(s.T(), err) {
+		assert.True(s.T(), updated.UpdatedAt.After(stored.UpdatedAt), "a status change moves updated_at")
+	}
+}
+
+// TestJobStartedAndCompletedAt checks running stamps started_at and only a
+// finished job has completed_at
+func (s *DatabaseTestSuite) TestJobStartedAndCompletedAt() {
+	user, err := CreateUser("startedat@example.com", "password")
+	assert.NoError(s.T(), err)
+
+	job := &models.Job{ID: "job-started", UserID: user.ID, JobID: "synthea-started", Status: models.JobStatusPending, OutputFormat: "fhir"}
+	assert.NoError(s.T(), job.MarshalParameters())
+	assert.NoError(s.T(), CreateJob(job))
+
+	assert.NoError(s.T(), UpdateJobStatus(job.ID, models.JobStatusRunning, nil, nil, nil, nil))
+	running, err := GetJobByID(job.ID)
+	if assert.NoError(s.T(), err) {
+		assert.NotNil(s.T(), running.StartedAt)
+		assert.Nil(s.T(), running.CompletedAt, "a running job has not completed")
+		assert.Zero(s.T(), running.Duration())
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(s.T(), UpdateJobStatus(job.ID, models.JobStatusCompleted, nil, nil, nil, nil))
+	completed, err := GetJobByID(job.ID)
+	if assert.NoError(s.T(), err) && assert.NotNil(s.T(), completed.StartedAt) && assert.NotNil(s.T(), completed.CompletedAt) {
+		assert.True(s.T(), completed.StartedAt.Equal(*running.StartedAt), "finishing keeps started_at")
+		assert.Equal(s.T(), completed.CompletedAt.Sub(*completed.StartedAt), completed.Duration())
+		assert.GreaterOrEqual(s.T(), completed.Duration(), 10*time.Millisecond)
+	}
+}
+
+// TestDeleteJob deletes a job only for its owner
+func (s *DatabaseTestSuite) TestDeleteJob() {
+	owner, err := CreateUser("deletejob@example.com", "password")
+	assert.NoError(s.T(), err)
+	other, err := CreateUser("deletejob-other@example.com", "password")
+	assert.NoError(s.T(), err)
+
+	job := &models.Job{ID: "job-delete", UserID: owner.ID, JobID: "synthea-delete", Status: models.JobStatusCompleted, OutputFormat: "fhir"}
+	assert.NoError(s.T(), job.MarshalParameters())
+	assert.NoError(s.T(), CreateJob(job))
+
+	assert.ErrorIs(s.T(), DeleteJob(other.ID, job.ID), sql.ErrNoRows, "another user cannot delete the job")
+	_, err = GetJobByID(job.ID)
+	assert.NoError(s.T(), err)
+
+	assert.NoError(s.T(), DeleteJob(owner.ID, job.ID))
+	_, err = GetJobByID(job.ID)
+	assert.ErrorIs(s.T(), err, sql.ErrNoRows)
+	assert.ErrorIs(s.T(), DeleteJob(owner.ID, job.ID), sql.ErrNoRows)
+}
+
+// TestUpgradeSchemaAddsJobUpdatedAt runs the schema setup against a jobs table
+// from before updated_at existed
+func (s *DatabaseTestSuite) TestUpgradeSchemaAddsJobUpdatedAt() {
+	if s.dbType != "sqlite" {
+		s.T().Skip("the legacy table fixture is SQLite only")
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(s.T().TempDir(), "legacy.db"))
+	assert.NoError(s.T(), err)
+	defer db.Close()
+
+	created := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	_, err = db.Exec(`CREATE TABLE jobs (
+		id TEXT PRIMARY KEY, user_id TEXT NOT NULL, job_id TEXT NOT NULL, status TEXT NOT NULL,
+		parameters TEXT, output_format TEXT, output_path TEXT, output_size INTEGER,
+		patient_count INTEGER, error_message TEXT, created_at DATETIME NOT NULL, completed_at DATETIME
+	)`)
+	assert.NoError(s.T(), err)
+	_, err = db.Exec("INSERT INTO jobs (id, user_id, job_id, status, created_at) VALUES ('legacy', 'u', 'j', 'completed', ?)", created)
+	assert.NoError(s.T(), err)
+
+	// Run twice: the upgrade must be idempotent
+	assert.NoError(s.T(), initSchema(db, "sqlite"))
+	assert.NoError(s.T(), initSchema(db, "sqlite"))
+
+	var updatedAt time.Time
+	assert.NoError(s.T(), db.QueryRow("SELECT updated_at FROM jobs WHERE id = 'legacy'").Scan(&updatedAt))
+	assert.True(s.T(), created.Equal(updatedAt), "updated_at is backfilled from created_at, got %v", updatedAt)
+
+	var keepOutput bool
+	assert.NoError(s.T(), db.QueryRow("SELECT keep_output FROM jobs WHERE id = 'legacy'").Scan(&keepOutput))
+	assert.False(s.T(), keepOutput, "existing jobs follow the retention policy")
+}
+
+// TestGetJobsWithExpiredOutput finds completed jobs past the cutoff that
+// still have output and are not kept
+func (s *DatabaseTestSuite) TestGetJobsWithExpiredOutput() {
+	user, err := CreateUser("retention@example.com", "password")
+	assert.NoError(s.T(), err)
+	other, err := CreateUser("retention-other@example.com", "password")
+	assert.NoError(s.T(), err)
+
+	outputPath := "synthea_output/retention/"
+	createJob := func(id string, status models.JobStatus) {
+		job := &models.Job{ID: id, UserID: user.ID, JobID: "synthea-" + id, Status: models.JobStatusPending, OutputFormat: "fhir"}
+		assert.NoError(s.T(), job.MarshalParameters())
+		assert.NoError(s.T(), CreateJob(job))
+		assert.NoError(s.T(), UpdateJobStatus(id, status, nil, &outputPath, nil, nil))
+	}
+	createJob("retention-expired", models.JobStatusCompleted)
+	createJob("retention-kept", models.JobStatusCompleted)
+	createJob("retention-running", models.JobStatusRunning)
+
+	assert.ErrorIs(s.T(), SetJobKeepOutput(other.ID, "retention-kept", true), sql.ErrNoRows, "only the owner can keep a job")
+	assert.NoError(s.T(), SetJobKeepOutput(user.ID, "retention-kept", true))
+
+	ids := func(cutoff time.Time) []string {
+		jobs, err := GetJobsWithExpiredOutput(cutoff)
+		assert.NoError(s.T(), err)
+		var ids []string
+		for _, job := range jobs {
+			ids = append(ids, job.ID)
+		}
+		return ids
+	}
+	assert.Empty(s.T(), ids(time.Now().Add(-time.Hour)), "nothing finished before the cutoff")
+	assert.Equal(s.T(), []string{"retention-expired"}, ids(time.Now().Add(time.Hour)))
+
+	assert.NoError(s.T(), ClearJobOutput("retention-expired"))
+	assert.Empty(s.T(), ids(time.Now().Add(time.Hour)), "a cleared job is not returned again")
+	job, err := GetJobByID("retention-expired")
+	if assert.NoError(s.T(), err) {
+		assert.Nil(s.T(), job.OutputPath)
+		assert.Equal(s.T(), models.JobStatusCompleted, job.Status, "the job record is kept")
+	}
+}
+
+// TestGetUserPatientCountSince sums completed jobs' patients from a point in time
+func (s *DatabaseTestSuite) TestGetUserPatientCountSince() {
+	user, err := CreateUser("quota@example.com", "password")
+	assert.NoError(s.T(), err)
+	other, err := CreateUser("quota-other@example.com", "password")
+	assert.NoError(s.T(), err)
+
+	createJob := func(id, userID string, status models.JobStatus, patients int) {
+		job := &models.Job{ID: id, UserID: userID, JobID: "synthea-" + id, Status: models.JobStatusPending, OutputFormat: "fhir"}
+		assert.NoError(s.T(), job.MarshalParameters())
+		assert.NoError(s.T(), CreateJob(job))
+		assert.NoError(s.T(), UpdateJobStatus(id, status, nil, nil, nil, &patients))
+	}
+	createJob("quota-done-1", user.ID, models.JobStatusCompleted, 10)
+	createJob("quota-done-2", user.ID, models.JobStatusCompleted, 25)
+	createJob("quota-old", user.ID, models.JobStatusCompleted, 1000)
+	createJob("quota-failed", user.ID, models.JobStatusFailed, 50)
+	createJob("quota-other", other.ID, models.JobStatusCompleted, 70)
+
+	since := time.Now().Add(-time.Hour)
+	query := "UPDATE jobs SET completed_at = ? WHERE id = ?"
+	if s.dbType == "postgres" {
+		query = "UPDATE jobs SET completed_at = $1 WHERE id = $2"
+	}
+	_, err = dbConn.Exec(query, since.Add(-time.Hour), "quota-old")
+	assert.NoError(s.T(), err)
+
+	count, err := GetUserPatientCountSince(user.ID, since)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), 35, count, "only the user's jobs completed since the bound count")
+
+	count, err = GetUserPatientCountSince("no-such-user", since)
+	assert.NoError(s.T(), err)
+	assert.Zero(s.T(), count)
+}
+
+// TestGetUserReservedPatientCount sums the population requested by unfinished jobs
+func (s *DatabaseTestSuite) TestGetUserReservedPatientCount() {
+	user, err := CreateUser("reserved@example.com", "password")
+	assert.NoError(s.T(), err)
+
+	createJob := func(id string, status models.JobStatus, population int) {
+		job := &models.Job{ID: id, UserID: user.ID, JobID: "synthea-" + id, Status: models.JobStatusPending,
+			Parameters: map[string]interface{}{"population": population}, OutputFormat: "fhir"}
+		assert.NoError(s.T(), job.MarshalParameters())
+		assert.NoError(s.T(), CreateJob(job))
+		if status != models.JobStatusPending {
+			assert.NoError(s.T(), UpdateJobStatus(id, status, nil, nil, nil, nil))
+		}
+	}
+	createJob("reserved-pending", models.JobStatusPending, 10)
+	createJob("reserved-running", models.JobStatusRunning, 25)
+	createJob("reserved-done", models.JobStatusCompleted, 50)
+	createJob("reserved-failed", models.JobStatusFailed, 70)
+
+	count, err := GetUserReservedPatientCount(context.Background(), user.ID, time.Now().Add(-time.Hour))
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), 35, count, "only pending and running jobs reserve patients")
+
+	count, err = GetUserReservedPatientCount(context.Background(), user.ID, time.Now().Add(time.Hour))
+	assert.NoError(s.T(), err)
+	assert.Zero(s.T(), count, "jobs created before the period do not count")
+}
+
+// TestGetAverageJobDuration averages the time per patient of completed jobs
+func (s *DatabaseTestSuite) TestGetAverageJobDuration() {
+	user, err := CreateUser("duration@example.com", "password")
+	assert.NoError(s.T(), err)
+
+	createJob := func(id, format string, status models.JobStatus, patients int, duration time.Duration) {
+		job := &models.Job{ID: id, UserID: user.ID, JobID: "synthea-" + id, Status: models.JobStatusPending, OutputFormat: format}
+		assert.NoError(s.T(), job.MarshalParameters())
+		assert.NoError(s.T(), CreateJob(job))
+		assert.NoError(s.T(), UpdateJobStatus(id, status, nil, nil, nil, &patients))
+		assert.NoError(s.T(), SetJobDuration(id, duration))
+	}
+	createJob("duration-1", "fhir", models.JobStatusCompleted, 10, 10*time.Second)
+	createJob("duration-2", "fhir", models.JobStatusCompleted, 100, 300*time.Second)
+	createJob("duration-failed", "fhir", models.JobStatusFailed, 10, time.Hour)
+	createJob("duration-csv", "csv", models.JobStatusCompleted, 10, time.Hour)
+
+	// 1s and 3s per patient
+	duration, samples, err := GetAverageJobDuration("fhir", 50)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), 2, samples, "only completed jobs in the format count")
+	assert.Equal(s.T(), 100*time.Second, duration)
+
+	duration, samples, err = GetAverageJobDuration("ccda", 50)
+	assert.NoError(s.T(), err)
+	assert.Zero(s.T(), samples)
+	assert.Zero(s.T(), duration)
+}
+
+// TestQueriesStopWithContext checks a cancelled context or the per-query
+// timeout aborts a query instead of running it
+func (s *DatabaseTestSuite) TestQueriesStopWithContext() {
+	user, err := CreateUser("context@example.com", "password")
+	assert.NoError(s.T(), err)
+	job := &models.Job{ID: "job-context", UserID: user.ID, JobID: "synthea-context", Status: models.JobStatusPending, OutputFormat: "fhir"}
+	assert.NoError(s.T(), job.MarshalParameters())
+	assert.NoError(s.T(), CreateJobContext(context.Background(), job))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = GetJobsByUserIDContext(ctx, user.ID)
+	assert.ErrorIs(s.T(), err, context.Canceled)
+	_, err = GetJobByIDContext(ctx, job.ID)
+	assert.ErrorIs(s.T(), err, context.Canceled)
+	_, err = GetUserByIDContext(ctx, user.ID)
+	assert.ErrorIs(s.T(), err, context.Canceled)
+	other := &models.Job{ID: "job-context-2", UserID: user.ID, JobID: "synthea-context-2", Status: models.JobStatusPending, OutputFormat: "fhir"}
+	assert.ErrorIs(s.T(), CreateJobContext(ctx, other), context.Canceled)
+
+	defer func(timeout time.Duration) { defaultDB.queryTimeout = timeout }(defaultDB.queryTimeout)
+	defaultDB.queryTimeout = time.Nanosecond
+	_, err = GetJobsFilteredContext(context.Background(), user.ID, "", nil, nil)
+	assert.ErrorIs(s.T(), err, context.DeadlineExceeded, "DB_QUERY_TIMEOUT_SECONDS bounds every query")
+
+	defaultDB.queryTimeout = 0
+	jobs, err := GetJobsByUserIDContext(context.Background(), user.ID)
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), jobs, 1, "the cancelled insert did not run")
+}
+
+// TestClaimJob checks a pending job can be claimed once
+func (s *DatabaseTestSuite) TestClaimJob() {
+	user, err := CreateUser("claim@example.com", "password")
+	assert.NoError(s.T(), err)
+	job := &models.Job{ID: "job-claim", UserID: user.ID, JobID: "synthea-claim", Status: models.JobStatusPending, OutputFormat: "fhir"}
+	assert.NoError(s.T(), job.MarshalParameters())
+	assert.NoError(s.T(), CreateJob(job))
+
+	prefix := "synthea_output/synthea-claim/"
+	claimed, err := ClaimJob(job.ID, &prefix)
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), claimed)
+	claimed, err = ClaimJob(job.ID, &prefix)
+	assert.NoError(s.T(), err)
+	assert.False(s.T(), claimed, "a running job cannot be claimed again")
+
+	stored, err := GetJobByID(job.ID)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), models.JobStatusRunning, stored.Status)
+	assert.NotNil(s.T(), stored.StartedAt)
+	if assert.NotNil(s.T(), stored.OutputPath) {
+		assert.Equal(s.T(), prefix, *stored.OutputPath)
+	}
+
+	assert.NoError(s.T(), TouchJobHeartbeat(job.ID))
+	stale, err := defaultDB.GetStaleRunningJobs(context.Background(), time.Now().Add(-time.Minute))
+	assert.NoError(s.T(), err)
+	assert.Empty(s.T(), stale, "a job with a recent heartbeat is not stale")
+	stale, err = defaultDB.GetStaleRunningJobs(context.Background(), time.Now().Add(time.Minute))
+	assert.NoError(s.T(), err)
+	if assert.Len(s.T(), stale, 1) {
+		assert.Equal(s.T(), job.ID, stale[0].ID)
+	}
+}
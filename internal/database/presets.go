@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/models"
+)
+
+// CreatePreset stores a new job preset
+func CreatePreset(preset *models.JobPreset) error {
+	return defaultDB.CreatePreset(context.Background(), preset)
+}
+
+// CreatePreset stores a new job preset, giving up when ctx is done
+func (db *DB) CreatePreset(ctx context.Context, preset *models.JobPreset) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	if err := preset.MarshalParameters(); err != nil {
+		return err
+	}
+
+	if db.dbType == "postgres" {
+		query := "INSERT INTO job_presets (user_id, name, parameters) VALUES ($1, $2, $3) RETURNING id, created_at, updated_at"
+		return db.conn.QueryRowContext(ctx, query, preset.UserID, preset.Name, preset.ParametersJSON).Scan(&preset.ID, &preset.CreatedAt, &preset.UpdatedAt)
+	}
+
+	now := time.Now()
+	preset.ID = GenerateID()
+	preset.CreatedAt = now
+	preset.UpdatedAt = now
+	query := "INSERT INTO job_presets (id, user_id, name, parameters, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)"
+	_, err := db.conn.ExecContext(ctx, query, preset.ID, preset.UserID, preset.Name, preset.ParametersJSON, preset.CreatedAt, preset.UpdatedAt)
+	return err
+}
+
+// GetPresetByID retrieves a single job preset
+func GetPresetByID(id string) (*models.JobPreset, error) {
+	return defaultDB.GetPresetByID(context.Background(), id)
+}
+
+// GetPresetByID retrieves a single job preset, giving up when ctx is done
+func (db *DB) GetPresetByID(ctx context.Context, id string) (*models.JobPreset, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var query string
+	if db.dbType == "postgres" {
+		query = "SELECT id, user_id, name, parameters, created_at, updated_at FROM job_presets WHERE id = $1"
+	} else {
+		query = "SELECT id, user_id, name, parameters, created_at, updated_at FROM job_presets WHERE id = ?"
+	}
+
+	preset := &models.JobPreset{}
+	err := db.conn.QueryRowContext(ctx, query, id).Scan(
+		&preset.ID, &preset.UserID, &preset.Name, &preset.ParametersJSON, &preset.CreatedAt, &preset.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := preset.UnmarshalParameters(); err != nil {
+		log.Printf("Warning: could not unmarshal preset parameters for preset %s: %v", preset.ID, err)
+	}
+
+	return preset, nil
+}
+
+// GetPresetsForUser retrieves the user's own presets followed by the global ones
+func GetPresetsForUser(userID string) ([]*models.JobPreset, error) {
+	return defaultDB.GetPresetsForUser(context.Background(), userID)
+}
+
+// GetPresetsForUser retrieves the user's own presets followed by the global ones, giving up when ctx is done
+func (db *DB) GetPresetsForUser(ctx context.Context, userID string) ([]*models.JobPreset, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var query string
+	if db.dbType == "postgres" {
+		query = "SELECT id, user_id, name, parameters, created_at, updated_at FROM job_presets WHERE user_id = $1 OR user_id IS NULL ORDER BY user_id IS NULL, name"
+	} else {
+		query = "SELECT id, user_id, name, parameters, created_at, updated_at FROM job_presets WHERE user_id = ? OR user_id IS NULL ORDER BY user_id IS NULL, name"
+	}
+
+	rows, err := db.conn.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var presets []*models.JobPreset
+	for rows.Next() {
+		preset := &models.JobPreset{}
+		err := rows.Scan(
+			&preset.ID, &preset.UserID, &preset.Name, &preset.ParametersJSON, &preset.CreatedAt, &preset.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := preset.UnmarshalParameters(); err != nil {
+			log.Printf("Warning: could not unmarshal preset parameters for preset %s: %v", preset.ID, err)
+		}
+
+		presets = append(presets, preset)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return presets, nil
+}
+
+// UpdatePreset renames a user's preset and replaces its parameters
+func UpdatePreset(userID string, preset *models.JobPreset) error {
+	return defaultDB.UpdatePreset(context.Background(), userID, preset)
+}
+
+// UpdatePreset renames a user's preset and replaces its parameters, giving up when ctx is done
+func (db *DB) UpdatePreset(ctx context.Context, userID string, preset *models.JobPreset) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	if err := preset.MarshalParameters(); err != nil {
+		return err
+	}
+
+	preset.UpdatedAt = time.Now()
+	var query string
+	if db.dbType == "postgres" {
+		query = "UPDATE job_presets SET name = $1, parameters = $2, updated_at = $3 WHERE id = $4 AND user_id = $5"
+	} else {
+		query = "UPDATE job_presets SET name = ?, parameters = ?, updated_at = ? WHERE id = ? AND user_id = ?"
+	}
+	result, err := db.conn.ExecContext(ctx, query, preset.Name, preset.ParametersJSON, preset.UpdatedAt, preset.ID, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeletePreset deletes a preset owned by the user
+func DeletePreset(userID string, presetID string) error {
+	return defaultDB.DeletePreset(context.Background(), userID, presetID)
+}
+
+// DeletePreset deletes a preset owned by the user, giving up when ctx is done
+func (db *DB) DeletePreset(ctx context.Context, userID string, presetID string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var query string
+	if db.dbType == "postgres" {
+		query = "DELETE FROM job_presets WHERE id = $1 AND user_id = $2"
+	} else {
+		query = "DELETE FROM job_presets WHERE id = ? AND user_id = ?"
+	}
+	result, err := db.conn.ExecContext(ctx, query, presetID, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
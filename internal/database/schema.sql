@@ -3,6 +3,7 @@ CREATE TABLE IF NOT EXISTS users (
     id TEXT PRIMARY KEY,
     email TEXT NOT NULL UNIQUE,
     password TEXT NOT NULL,
+    tier TEXT NOT NULL DEFAULT 'free', -- free, pro
     created_at TIMESTAMP NOT NULL,
     updated_at TIMESTAMP NOT NULL
 );
@@ -41,7 +42,13 @@ CREATE TABLE IF NOT EXISTS jobs (
     patient_count INTEGER, -- Number of patients generated
     error_message TEXT, -- Error details if failed
     created_at TIMESTAMP NOT NULL,
+    started_at TIMESTAMP, -- When the job last moved to running
     completed_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL,
+    keep_output BOOLEAN NOT NULL DEFAULT 0, -- Exempt from output retention
+    duration_ms INTEGER, -- Run time of a finished job
+    request_id TEXT, -- X-Request-ID of the request that created the job
+    heartbeat_at TIMESTAMP, -- Last sign of life from the process running the job
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
@@ -0,0 +1,100 @@
+// Package estimate predicts how long a generation job will run and how much
+// output it will produce, for the dry-run response.
+package estimate
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+)
+
+// How an estimate was made
+const (
+	BasisHeuristic = "heuristic"
+	BasisHistory   = "history"
+)
+
+// minHistorySamples is how many finished jobs in a format are needed before
+// their average replaces the heuristic duration
+const minHistorySamples = 5
+
+// startupTime is Synthea's fixed cost of starting the JVM and loading modules
+const startupTime = 15 * time.Second
+
+// formatCost is the typical run time and output size per patient of a format
+type formatCost struct {
+	timePerPatient  time.Duration
+	bytesPerPatient int64
+}
+
+var formatCosts = map[string]formatCost{
+	"fhir": {timePerPatient: 400 * time.Millisecond, bytesPerPatient: 3 << 20},
+	"ccda": {timePerPatient: 300 * time.Millisecond, bytesPerPatient: 1 << 20},
+	"csv":  {timePerPatient: 250 * time.Millisecond, bytesPerPatient: 400 << 10},
+}
+
+// Estimate is the approximate cost of a job
+type Estimate struct {
+	DurationSeconds int    `json:"durationSeconds"`
+	OutputBytes     int64  `json:"outputBytes"`
+	Basis           string `json:"basis"` // How the duration was estimated
+}
+
+// moduleFactor scales the cost of a job by how much of Synthea it runs. Only
+// the kept modules generate conditions, but every patient still goes through
+// the core lifecycle, so even a single module costs a fraction of a full run.
+func moduleFactor(keepModules []string) float64 {
+	if len(keepModules) == 0 {
+		return 1
+	}
+	total := len(models.SyntheaModules())
+	return min(0.3+0.7*float64(len(keepModules))/float64(total), 1)
+}
+
+// Heuristic estimates a job from fixed per-patient costs of its format
+func Heuristic(params *models.SyntheaParams) Estimate {
+	population := 0
+	if params.Population != nil {
+		population = *params.Population
+	}
+	cost, ok := formatCosts[params.GetOutputFormat()]
+	if !ok {
+		cost = formatCosts["fhir"]
+	}
+	factor := moduleFactor(params.KeepModules)
+
+	perPatient := time.Duration(float64(cost.timePerPatient) * factor)
+	return Estimate{
+		DurationSeconds: seconds(startupTime + perPatient*time.Duration(population)),
+		OutputBytes:     int64(math.Round(float64(cost.bytesPerPatient) * factor * float64(population))),
+		Basis:           BasisHeuristic,
+	}
+}
+
+// ForParams estimates a job, using the average duration of finished jobs in
+// the same format in db once there are enough of them and the heuristic
+// otherwise. The output size always comes from the heuristic.
+func ForParams(ctx context.Context, db *database.DB, params *models.SyntheaParams) (Estimate, error) {
+	estimate := Heuristic(params)
+	if params.Population == nil {
+		return estimate, nil
+	}
+
+	duration, samples, err := db.GetAverageJobDuration(ctx, params.GetOutputFormat(), *params.Population)
+	if err != nil {
+		return estimate, err
+	}
+	if samples >= minHistorySamples {
+		estimate.DurationSeconds = seconds(duration)
+		estimate.Basis = BasisHistory
+	}
+	return estimate, nil
+}
+
+// seconds rounds d up to whole seconds
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
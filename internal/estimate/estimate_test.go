@@ -0,0 +1,79 @@
+package estimate
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func params(population int, format string, modules ...string) *models.SyntheaParams {
+	return &models.SyntheaParams{Population: &population, OutputFormat: &format, KeepModules: modules}
+}
+
+func TestHeuristic(t *testing.T) {
+	// 15s startup + 100 * 400ms, 100 * 3MB
+	assert.Equal(t, Estimate{DurationSeconds: 55, OutputBytes: 300 << 20, Basis: BasisHeuristic}, Heuristic(params(100, "fhir")))
+	// 15s startup + 10 * 250ms rounded up
+	assert.Equal(t, Estimate{DurationSeconds: 18, OutputBytes: 4000 << 10, Basis: BasisHeuristic}, Heuristic(params(10, "csv")))
+
+	full := Heuristic(params(1000, "fhir"))
+	one := Heuristic(params(1000, "fhir", "asthma"))
+	assert.Less(t, one.DurationSeconds, full.DurationSeconds, "keeping one module is cheaper")
+	assert.Less(t, one.OutputBytes, full.OutputBytes)
+	assert.Greater(t, one.OutputBytes, full.OutputBytes/4, "every patient still runs the core modules")
+}
+
+func TestModuleFactor(t *testing.T) {
+	assert.Equal(t, 1.0, moduleFactor(nil))
+	var all []string
+	for _, module := range models.SyntheaModules() {
+		all = append(all, module.Name)
+	}
+	assert.Equal(t, 1.0, moduleFactor(all))
+	assert.InDelta(t, 0.3, moduleFactor([]string{"asthma"}), 0.7/float64(len(all))+1e-9)
+}
+
+func TestForParamsUsesHistory(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.Open(&config.Config{DatabaseType: "sqlite", DatabasePath: filepath.Join(t.TempDir(), "test.db")})
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	user, err := db.CreateUser(ctx, "estimate@example.com", "password")
+	require.NoError(t, err)
+
+	finishJob := func(i int) {
+		id := fmt.Sprintf("estimate-%d", i)
+		job := &models.Job{ID: id, UserID: user.ID, JobID: "synthea-" + id, Status: models.JobStatusPending, OutputFormat: "csv"}
+		require.NoError(t, job.MarshalParameters())
+		require.NoError(t, db.CreateJob(ctx, job))
+		patients := 10
+		require.NoError(t, db.UpdateJobStatus(ctx, id, models.JobStatusCompleted, nil, nil, nil, &patients))
+		require.NoError(t, db.SetJobDuration(ctx, id, 20*time.Second))
+	}
+
+	for i := 0; i < minHistorySamples-1; i++ {
+		finishJob(i)
+	}
+	estimate, err := ForParams(ctx, db, params(100, "csv"))
+	require.NoError(t, err)
+	assert.Equal(t, Heuristic(params(100, "csv")), estimate, "too few jobs to go by")
+
+	finishJob(minHistorySamples)
+	estimate, err = ForParams(ctx, db, params(100, "csv"))
+	require.NoError(t, err)
+	assert.Equal(t, BasisHistory, estimate.Basis)
+	assert.Equal(t, 200, estimate.DurationSeconds, "2s per patient")
+	assert.Equal(t, Heuristic(params(100, "csv")).OutputBytes, estimate.OutputBytes)
+
+	estimate, err = ForParams(ctx, db, params(100, "fhir"))
+	require.NoError(t, err)
+	assert.Equal(t, BasisHeuristic, estimate.Basis, "history is per format")
+}
@@ -3,6 +3,8 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -15,6 +17,11 @@ const (
 	JobStatusFailed    JobStatus = "failed"
 )
 
+// IsTerminal reports whether a job in this status will not change again
+func (s JobStatus) IsTerminal() bool {
+	return s == JobStatusCompleted || s == JobStatusFailed
+}
+
 // Job represents a patient generation job
 type Job struct {
 	ID             string                 `json:"id" db:"id"`
@@ -29,7 +36,49 @@ type Job struct {
 	PatientCount   *int                   `json:"patient_count" db:"patient_count"`
 	ErrorMessage   *string                `json:"error_message" db:"error_message"`
 	CreatedAt      time.Time              `json:"created_at" db:"created_at"`
+	StartedAt      *time.Time             `json:"started_at" db:"started_at"` // Last moved to running
 	CompletedAt    *time.Time             `json:"completed_at" db:"completed_at"`
+	UpdatedAt      time.Time              `json:"updated_at" db:"updated_at"`
+	KeepOutput     bool                   `json:"keep_output" db:"keep_output"` // Exempt from output retention
+	RequestID      *string                `json:"request_id" db:"request_id"`   // X-Request-ID of the request that created the job
+
+	// OutputExpiresAt is when the job's outputs will be deleted. It is derived
+	// from the retention config by SetOutputExpiry and not stored.
+	OutputExpiresAt *time.Time `json:"output_expires_at,omitempty" db:"-"`
+
+	// DurationSeconds is how long a finished job ran. It is derived from
+	// StartedAt and CompletedAt by SetDuration and not stored.
+	DurationSeconds *float64 `json:"duration_seconds,omitempty" db:"-"`
+}
+
+// Duration returns how long a finished job ran, from StartedAt to
+// CompletedAt, or 0 for jobs that are unfinished or never started
+func (j *Job) Duration() time.Duration {
+	if !j.Status.IsTerminal() || j.StartedAt == nil || j.CompletedAt == nil {
+		return 0
+	}
+	return j.CompletedAt.Sub(*j.StartedAt)
+}
+
+// SetDuration computes DurationSeconds, leaving it nil when Duration is unknown
+func (j *Job) SetDuration() {
+	j.DurationSeconds = nil
+	if duration := j.Duration(); duration > 0 {
+		seconds := duration.Seconds()
+		j.DurationSeconds = &seconds
+	}
+}
+
+// SetOutputExpiry computes OutputExpiresAt for a completed job. It is left
+// nil for unfinished jobs and when outputs are kept indefinitely, either by
+// config or because the job is marked KeepOutput.
+func (j *Job) SetOutputExpiry(retention time.Duration) {
+	j.OutputExpiresAt = nil
+	if retention <= 0 || j.KeepOutput || j.Status != JobStatusCompleted || j.CompletedAt == nil {
+		return
+	}
+	expiresAt := j.CompletedAt.Add(retention)
+	j.OutputExpiresAt = &expiresAt
 }
 
 // JobFile represents a file output from a generation job
@@ -61,6 +110,87 @@ type SyntheaCmdArgs struct {
 	Gender     string
 	AgeRange   string
 	City       string
+	Modules    string // Synthea -m filter, colon separated
+}
+
+// Limits enforced by SyntheaParams.Validate
+const (
+	DefaultMaxPopulation = 10000
+	MaxAge               = 140
+)
+
+// ValidOutputFormats are the exporters a job can request
+var ValidOutputFormats = []string{"fhir", "ccda", "csv"}
+
+// ValidationErrors maps a parameter's JSON field name to what is wrong with it
+type ValidationErrors map[string]string
+
+func (v ValidationErrors) Error() string {
+	fields := make([]string, 0, len(v))
+	for field := range v {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	msgs := make([]string, 0, len(fields))
+	for _, field := range fields {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", field, v[field]))
+	}
+	return "invalid parameters: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks the parameters before a job is created, allowing at most
+// maxPopulation patients (DefaultMaxPopulation if maxPopulation <= 0). It
+// returns ValidationErrors describing every invalid field, or nil.
+func (p *SyntheaParams) Validate(maxPopulation int) error {
+	if maxPopulation <= 0 {
+		maxPopulation = DefaultMaxPopulation
+	}
+	errs := ValidationErrors{}
+
+	if p.Population == nil {
+		errs["population"] = "is required"
+	} else if *p.Population < 1 || *p.Population > maxPopulation {
+		errs["population"] = fmt.Sprintf("must be between 1 and %d", maxPopulation)
+	}
+
+	if p.OutputFormat != nil {
+		valid := false
+		for _, format := range ValidOutputFormats {
+			if *p.OutputFormat == format {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			errs["outputFormat"] = "must be one of " + strings.Join(ValidOutputFormats, ", ")
+		}
+	}
+
+	if p.Gender != nil && *p.Gender != "M" && *p.Gender != "F" {
+		errs["gender"] = "must be M or F"
+	}
+
+	if unknown := unknownModules(p.KeepModules); len(unknown) > 0 {
+		errs["keepModules"] = "unknown modules: " + strings.Join(unknown, ", ")
+	}
+
+	if p.AgeMin != nil && (*p.AgeMin < 0 || *p.AgeMin > MaxAge) {
+		errs["ageMin"] = fmt.Sprintf("must be between 0 and %d", MaxAge)
+	}
+	if p.AgeMax != nil && (*p.AgeMax < 0 || *p.AgeMax > MaxAge) {
+		errs["ageMax"] = fmt.Sprintf("must be between 0 and %d", MaxAge)
+	}
+	if p.AgeMin != nil && p.AgeMax != nil && *p.AgeMin > *p.AgeMax {
+		if _, exists := errs["ageMin"]; !exists {
+			errs["ageMin"] = "must not be greater than ageMax"
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
 // GetOutputFormat returns the output format, defaulting to "fhir"
@@ -136,6 +266,18 @@ func (j *Job) GetSyntheaArgs() (*SyntheaCmdArgs, error) {
 		}
 	}
 
+	if raw, ok := j.Parameters["keepModules"].([]interface{}); ok && len(raw) > 0 {
+		modules := make([]string, 0, len(raw))
+		for _, m := range raw {
+			name, _ := m.(string)
+			modules = append(modules, name)
+		}
+		if unknown := unknownModules(modules); len(unknown) > 0 {
+			return nil, fmt.Errorf("unknown modules in job parameters: %s", strings.Join(unknown, ", "))
+		}
+		args.Modules = strings.Join(modules, ":")
+	}
+
 	return args, nil
 }
 
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// JobFilterDateLayout is the date-only format accepted for job filter bounds.
+// RFC 3339 timestamps are accepted as well.
+const JobFilterDateLayout = "2006-01-02"
+
+// JobFilter narrows a user's job list by status and creation time. Zero
+// values mean no restriction; From is inclusive and To exclusive.
+type JobFilter struct {
+	Status JobStatus
+	From   *time.Time
+	To     *time.Time
+}
+
+// ParseJobFilter builds a JobFilter from the status, from and to query
+// parameters. A date-only to covers that whole day. It returns
+// ValidationErrors for an unknown status, malformed dates, or from after to.
+func ParseJobFilter(status, from, to string) (JobFilter, error) {
+	var filter JobFilter
+	errs := ValidationErrors{}
+
+	if status = strings.TrimSpace(status); status != "" {
+		switch JobStatus(status) {
+		case JobStatusPending, JobStatusRunning, JobStatusCompleted, JobStatusFailed:
+			filter.Status = JobStatus(status)
+		default:
+			errs["status"] = "must be one of pending, running, completed, failed"
+		}
+	}
+
+	if from = strings.TrimSpace(from); from != "" {
+		if t, _, err := parseFilterTime(from); err != nil {
+			errs["from"] = "must be a date (YYYY-MM-DD) or RFC 3339 timestamp"
+		} else {
+			filter.From = &t
+		}
+	}
+
+	if to = strings.TrimSpace(to); to != "" {
+		if t, dateOnly, err := parseFilterTime(to); err != nil {
+			errs["to"] = "must be a date (YYYY-MM-DD) or RFC 3339 timestamp"
+		} else {
+			if filter.From != nil && filter.From.After(t) {
+				errs["from"] = "must not be after to"
+			}
+			if dateOnly {
+				t = t.AddDate(0, 0, 1)
+			}
+			filter.To = &t
+		}
+	}
+
+	if len(errs) > 0 {
+		return JobFilter{}, errs
+	}
+	return filter, nil
+}
+
+// parseFilterTime parses an RFC 3339 timestamp or a UTC date, reporting
+// whether the value was date-only
+func parseFilterTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse(JobFilterDateLayout, value)
+	return t, true, err
+}
+
+// IsEmpty reports whether the filter restricts nothing
+func (f JobFilter) IsEmpty() bool {
+	return f.Status == "" && f.From == nil && f.To == nil
+}
+
+// Matches reports whether job passes the filter
+func (f JobFilter) Matches(job *Job) bool {
+	if f.Status != "" && job.Status != f.Status {
+		return false
+	}
+	if f.From != nil && job.CreatedAt.Before(*f.From) {
+		return false
+	}
+	if f.To != nil && !job.CreatedAt.Before(*f.To) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJobFilter(t *testing.T) {
+	filter, err := ParseJobFilter("", "", "")
+	require.NoError(t, err)
+	assert.True(t, filter.IsEmpty())
+
+	filter, err = ParseJobFilter("failed", "2024-03-01", "2024-03-31")
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusFailed, filter.Status)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), *filter.From)
+	assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), *filter.To, "a date-only to covers the whole day")
+
+	filter, err = ParseJobFilter("", "2024-03-01T12:00:00Z", "2024-03-01T12:00:00Z")
+	require.NoError(t, err)
+	assert.Equal(t, *filter.From, *filter.To)
+
+	_, err = ParseJobFilter("done", "yesterday", "2024-13-01")
+	var verrs ValidationErrors
+	require.ErrorAs(t, err, &verrs)
+	assert.Contains(t, verrs, "status")
+	assert.Contains(t, verrs, "from")
+	assert.Contains(t, verrs, "to")
+
+	_, err = ParseJobFilter("", "2024-03-02", "2024-03-01")
+	require.ErrorAs(t, err, &verrs)
+	assert.Equal(t, "must not be after to", verrs["from"])
+}
+
+func TestJobFilterMatches(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	filter := JobFilter{Status: JobStatusFailed, From: &from, To: &to}
+
+	assert.True(t, filter.Matches(&Job{Status: JobStatusFailed, CreatedAt: from}))
+	assert.False(t, filter.Matches(&Job{Status: JobStatusCompleted, CreatedAt: from}))
+	assert.False(t, filter.Matches(&Job{Status: JobStatusFailed, CreatedAt: from.Add(-time.Second)}))
+	assert.False(t, filter.Matches(&Job{Status: JobStatusFailed, CreatedAt: to}))
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intPtr(i int) *int       { return &i }
+func strPtr(s string) *string { return &s }
+
+func TestSyntheaParamsValidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		params        SyntheaParams
+		maxPopulation int
+		wantFields    []string
+	}{
+		{"minimum population", SyntheaParams{Population: intPtr(1)}, 0, nil},
+		{"default maximum population", SyntheaParams{Population: intPtr(DefaultMaxPopulation)}, 0, nil},
+		{"above default maximum", SyntheaParams{Population: intPtr(DefaultMaxPopulation + 1)}, 0, []string{"population"}},
+		{"configured maximum", SyntheaParams{Population: intPtr(500)}, 500, nil},
+		{"above configured maximum", SyntheaParams{Population: intPtr(501)}, 500, []string{"population"}},
+		{"missing population", SyntheaParams{}, 0, []string{"population"}},
+		{"zero population", SyntheaParams{Population: intPtr(0)}, 0, []string{"population"}},
+		{"negative population", SyntheaParams{Population: intPtr(-5)}, 0, []string{"population"}},
+		{"equal ages", SyntheaParams{Population: intPtr(10), AgeMin: intPtr(30), AgeMax: intPtr(30)}, 0, nil},
+		{"age zero", SyntheaParams{Population: intPtr(10), AgeMin: intPtr(0), AgeMax: intPtr(0)}, 0, nil},
+		{"negative age", SyntheaParams{Population: intPtr(10), AgeMin: intPtr(-1)}, 0, []string{"ageMin"}},
+		{"negative max age", SyntheaParams{Population: intPtr(10), AgeMax: intPtr(-1)}, 0, []string{"ageMax"}},
+		{"ageMin above ageMax", SyntheaParams{Population: intPtr(10), AgeMin: intPtr(31), AgeMax: intPtr(30)}, 0, []string{"ageMin"}},
+		{"age above maximum", SyntheaParams{Population: intPtr(10), AgeMax: intPtr(MaxAge + 1)}, 0, []string{"ageMax"}},
+		{"gender M", SyntheaParams{Population: intPtr(10), Gender: strPtr("M")}, 0, nil},
+		{"gender F", SyntheaParams{Population: intPtr(10), Gender: strPtr("F")}, 0, nil},
+		{"lowercase gender", SyntheaParams{Population: intPtr(10), Gender: strPtr("m")}, 0, []string{"gender"}},
+		{"unknown output format", SyntheaParams{Population: intPtr(10), OutputFormat: strPtr("pdf")}, 0, []string{"outputFormat"}},
+		{"several errors", SyntheaParams{Population: intPtr(0), Gender: strPtr("X")}, 0, []string{"population", "gender"}},
+		{"known modules", SyntheaParams{Population: intPtr(10), KeepModules: []string{"asthma", "copd"}}, 0, nil},
+		{"unknown module", SyntheaParams{Population: intPtr(10), KeepModules: []string{"asthma", "not_a_module"}}, 0, []string{"keepModules"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.params.Validate(tt.maxPopulation)
+			if tt.wantFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var errs ValidationErrors
+			if assert.ErrorAs(t, err, &errs) {
+				assert.Len(t, errs, len(tt.wantFields))
+				for _, field := range tt.wantFields {
+					assert.Contains(t, errs, field)
+				}
+			}
+		})
+	}
+}
+
+func TestGetSyntheaArgsModuleFilter(t *testing.T) {
+	params := SyntheaParams{Population: intPtr(10), KeepModules: []string{"asthma", "copd"}}
+	job := &Job{Parameters: params.ToMap()}
+	assert.NoError(t, job.MarshalParameters())
+
+	args, err := job.GetSyntheaArgs()
+	assert.NoError(t, err)
+	assert.Equal(t, "10", args.Population)
+	assert.Equal(t, "asthma:copd", args.Modules)
+
+	job = &Job{Parameters: (&SyntheaParams{Population: intPtr(10)}).ToMap()}
+	assert.NoError(t, job.MarshalParameters())
+	args, err = job.GetSyntheaArgs()
+	assert.NoError(t, err)
+	assert.Empty(t, args.Modules)
+
+	job = &Job{ParametersJSON: `{"population":10,"keepModules":["asthma","rm -rf"]}`}
+	_, err = job.GetSyntheaArgs()
+	assert.Error(t, err)
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"slices"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -11,14 +13,38 @@ type User struct {
 	ID        string    `json:"id"`
 	Email     string    `json:"email"`
 	Password  string    `json:"-"` // Password is never exposed in JSON
+	Tier      string    `json:"tier" db:"tier"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	IsAdmin   bool      `json:"is_admin" db:"is_admin"`
 }
 
-// NewUser creates a new user with a hashed password
-func NewUser(email, password string) (*User, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// Account tiers. New users start on TierFree, the only tier subject to the
+// monthly patient quota.
+const (
+	TierFree = "free"
+	TierPro  = "pro"
+)
+
+// ValidTiers are the tiers a user can be assigned
+var ValidTiers = []string{TierFree, TierPro}
+
+// IsValidTier reports whether tier is one of ValidTiers
+func IsValidTier(tier string) bool {
+	return slices.Contains(ValidTiers, tier)
+}
+
+// TierLabel returns the user's tier for display, e.g. "Pro"
+func (u *User) TierLabel() string {
+	if u.Tier == "" {
+		return "Free"
+	}
+	return strings.ToUpper(u.Tier[:1]) + u.Tier[1:]
+}
+
+// NewUser creates a new user with the password hashed at the given bcrypt cost
+func NewUser(email, password string, cost int) (*User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	if err != nil {
 		return nil, err
 	}
@@ -26,6 +52,7 @@ func NewUser(email, password string) (*User, error) {
 	return &User{
 		Email:     email,
 		Password:  string(hashedPassword),
+		Tier:      TierFree,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}, nil
@@ -55,3 +82,11 @@ type Session struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
 }
+
+// SessionSummary describes a session for display without exposing its token
+type SessionSummary struct {
+	ID          string    `json:"id"`
+	MaskedToken string    `json:"masked_token"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelsJSONRoundTrip(t *testing.T) {
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	expires := created.Add(24 * time.Hour)
+	userID := "user-1"
+
+	models := map[string]interface{}{
+		"User": &User{ID: userID, Email: "user@example.com", CreatedAt: created, UpdatedAt: created, IsAdmin: true},
+		"Token": &Token{ID: "token-1", UserID: userID, Token: "ms_abc", Name: "ci",
+			CreatedAt: created, ExpiresAt: &expires},
+		"Session":        &Session{ID: "session-1", UserID: userID, Token: "abc", CreatedAt: created, ExpiresAt: expires},
+		"SessionSummary": &SessionSummary{ID: "session-1", MaskedToken: "abc…", CreatedAt: created, ExpiresAt: expires},
+		"Job": &Job{ID: "job-1", UserID: userID, JobID: "synthea-1", Status: JobStatusCompleted,
+			Parameters: map[string]interface{}{"population": float64(10), "outputFormat": "fhir"}, OutputFormat: "fhir",
+			OutputPath: strPtr("jobs/job-1"), OutputSize: func() *int64 { n := int64(2048); return &n }(),
+			PatientCount: intPtr(10), CreatedAt: created, CompletedAt: &expires, OutputExpiresAt: &expires},
+		"JobFile": &JobFile{ID: "file-1", JobID: "job-1", Filename: "a.json", S3Key: "k", Size: 1, URL: "https://example.com/a"},
+		"SyntheaParams": &SyntheaParams{Population: intPtr(10), OutputFormat: strPtr("csv"),
+			KeepModules: []string{"asthma"}, CustomModules: []string{"custom"}, State: strPtr("Ohio"),
+			City: strPtr("Columbus"), Gender: strPtr("F"), AgeMin: intPtr(1), AgeMax: intPtr(90),
+			Seed: func() *int64 { n := int64(42); return &n }()},
+		"JobPreset": &JobPreset{ID: "preset-1", UserID: &userID, Name: "small",
+			Parameters: SyntheaParams{Population: intPtr(5)}, CreatedAt: created, UpdatedAt: created},
+	}
+	for name, original := range models {
+		t.Run(name, func(t *testing.T) {
+			data, err := json.Marshal(original)
+			require.NoError(t, err)
+			decoded := reflect.New(reflect.TypeOf(original).Elem()).Interface()
+			require.NoError(t, json.Unmarshal(data, decoded))
+			assert.Equal(t, original, decoded)
+		})
+	}
+}
+
+func TestUserJSONOmitsPassword(t *testing.T) {
+	data, err := json.Marshal(&User{ID: "user-1", Password: "hash"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "hash")
+}
+
+func TestTierLabel(t *testing.T) {
+	assert.Equal(t, "Free", (&User{Tier: TierFree}).TierLabel())
+	assert.Equal(t, "Pro", (&User{Tier: TierPro}).TierLabel())
+	assert.Equal(t, "Free", (&User{}).TierLabel(), "users loaded without a tier are free")
+	assert.True(t, IsValidTier(TierPro))
+	assert.False(t, IsValidTier("platinum"))
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	_ "embed"
+	"encoding/json"
+	"sync"
+)
+
+// SyntheaModule is a Synthea disease module a job may keep via keepModules.
+// Name is the module file in Synthea's src/main/resources/modules.
+type SyntheaModule struct {
+	Name     string `json:"name"`
+	Label    string `json:"label"`
+	Category string `json:"category"`
+}
+
+// syntheaModulesJSON is the single list of selectable modules shared by
+// validation, the API and the portal
+//
+//go:embed synthea_modules.json
+var syntheaModulesJSON []byte
+
+var (
+	syntheaModulesOnce sync.Once
+	syntheaModules     []SyntheaModule
+	syntheaModuleNames map[string]bool
+)
+
+func loadSyntheaModules() {
+	if err := json.Unmarshal(syntheaModulesJSON, &syntheaModules); err != nil {
+		panic("models: invalid synthea_modules.json: " + err.Error())
+	}
+	syntheaModuleNames = make(map[string]bool, len(syntheaModules))
+	for _, module := range syntheaModules {
+		syntheaModuleNames[module.Name] = true
+	}
+}
+
+// SyntheaModules returns the selectable Synthea modules. The list is parsed
+// once and callers get their own copy.
+func SyntheaModules() []SyntheaModule {
+	syntheaModulesOnce.Do(loadSyntheaModules)
+	return append([]SyntheaModule(nil), syntheaModules...)
+}
+
+// IsKnownModule reports whether name is one of SyntheaModules
+func IsKnownModule(name string) bool {
+	syntheaModulesOnce.Do(loadSyntheaModules)
+	return syntheaModuleNames[name]
+}
+
+// unknownModules returns the entries of modules that are not SyntheaModules
+func unknownModules(modules []string) []string {
+	var unknown []string
+	for _, module := range modules {
+		if !IsKnownModule(module) {
+			unknown = append(unknown, module)
+		}
+	}
+	return unknown
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// JobPreset is a named, reusable set of generation parameters. Presets with a
+// nil UserID are global and visible to every user.
+type JobPreset struct {
+	ID             string        `json:"id" db:"id"`
+	UserID         *string       `json:"user_id,omitempty" db:"user_id"`
+	Name           string        `json:"name" db:"name"`
+	Parameters     SyntheaParams `json:"parameters" db:"-"`
+	ParametersJSON string        `json:"-" db:"parameters"` // JSON storage
+	CreatedAt      time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+// IsGlobal reports whether the preset is shared with all users
+func (p *JobPreset) IsGlobal() bool {
+	return p.UserID == nil
+}
+
+// MarshalParameters converts Parameters to JSON for database storage
+func (p *JobPreset) MarshalParameters() error {
+	data, err := json.Marshal(p.Parameters)
+	if err != nil {
+		return err
+	}
+	p.ParametersJSON = string(data)
+	return nil
+}
+
+// UnmarshalParameters converts the stored JSON back into Parameters
+func (p *JobPreset) UnmarshalParameters() error {
+	if p.ParametersJSON == "" {
+		p.Parameters = SyntheaParams{}
+		return nil
+	}
+	return json.Unmarshal([]byte(p.ParametersJSON), &p.Parameters)
+}
@@ -0,0 +1,54 @@
+package portal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/auth"
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSRFProtection(t *testing.T) {
+	p := newTestPortal(t, &config.Config{})
+	routes := p.Routes()
+
+	email := fmt.Sprintf("csrf-%d@example.com", time.Now().UnixNano())
+	_, err := auth.RegisterUser(email, "Password1!")
+	require.NoError(t, err)
+	session := login(t, p, email, false)
+	other := login(t, p, email, false)
+
+	// Pages carry the token bound to the session in their forms
+	req := httptest.NewRequest(http.MethodGet, "/tokens", nil)
+	req.AddCookie(session)
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `<input type="hidden" name="csrf_token" value="`+csrfToken(session.Value)+`">`)
+
+	post := func(form url.Values, header string) int {
+		req := httptest.NewRequest(http.MethodPost, "/account/sessions/revoke-others", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if header != "" {
+			req.Header.Set(csrfHeaderName, header)
+		}
+		req.AddCookie(session)
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, post(url.Values{}, ""), "missing token")
+	assert.Equal(t, http.StatusForbidden, post(url.Values{csrfFieldName: {"forged"}}, ""), "wrong token")
+	assert.Equal(t, http.StatusForbidden, post(url.Values{csrfFieldName: {csrfToken(other.Value)}}, ""), "another session's token")
+
+	assert.Equal(t, http.StatusSeeOther, post(url.Values{csrfFieldName: {csrfToken(session.Value)}}, ""))
+	assert.Equal(t, http.StatusSeeOther, post(url.Values{}, csrfToken(session.Value)), "token in the header")
+}
@@ -0,0 +1,51 @@
+package portal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/auth"
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboardShowsTierAndQuota(t *testing.T) {
+	email := fmt.Sprintf("dashboard-quota-%d@example.com", time.Now().UnixNano())
+	user, err := auth.RegisterUser(email, "Password1!")
+	require.NoError(t, err)
+
+	job := &models.Job{ID: database.GenerateID(), UserID: user.ID, JobID: database.GenerateID(), Status: models.JobStatusPending, OutputFormat: "fhir"}
+	require.NoError(t, job.MarshalParameters())
+	require.NoError(t, database.CreateJob(job))
+	patients := 30
+	require.NoError(t, database.UpdateJobStatus(job.ID, models.JobStatusCompleted, nil, nil, nil, &patients))
+
+	dashboard := func(cfg *config.Config) string {
+		p := newTestPortal(t, cfg)
+		req := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+		req.AddCookie(login(t, p, email, false))
+		rec := httptest.NewRecorder()
+		p.Routes().ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+
+	page := dashboard(&config.Config{MonthlyPatientQuota: 100})
+	assert.Contains(t, page, "Patients This Month")
+	assert.Contains(t, page, "30 / 100")
+	assert.Contains(t, page, "70 remaining")
+
+	assert.Contains(t, page, ">Free</dd>")
+	assert.NotContains(t, dashboard(&config.Config{}), "Patients This Month", "no card without a quota")
+
+	require.NoError(t, database.SetUserTier(user.ID, models.TierPro))
+	page = dashboard(&config.Config{MonthlyPatientQuota: 100})
+	assert.Contains(t, page, ">Pro</dd>")
+	assert.NotContains(t, page, "Patients This Month", "paid tiers have no quota")
+}
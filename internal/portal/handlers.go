@@ -3,29 +3,41 @@ package portal
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/MediSynth-io/medisynth/internal/apiclient"
 	"github.com/MediSynth-io/medisynth/internal/auth"
 	"github.com/MediSynth-io/medisynth/internal/database"
 	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/MediSynth-io/medisynth/internal/quota"
 	"github.com/go-chi/chi/v5"
 )
 
 func (p *Portal) handleLanding(w http.ResponseWriter, r *http.Request) {
-	p.renderTemplate(w, r, "landing.html", "MediSynth", map[string]interface{}{})
+	if err := p.renderTemplate(w, r, "landing.html", "MediSynth", map[string]interface{}{}); err != nil {
+		serverError(w, r, err)
+	}
 }
 
 func (p *Portal) HandleHome(w http.ResponseWriter, r *http.Request) {
-	p.renderTemplate(w, r, "home.html", "Home", map[string]interface{}{})
+	if err := p.renderTemplate(w, r, "home.html", "Home", map[string]interface{}{}); err != nil {
+		serverError(w, r, err)
+	}
 }
 
 func (p *Portal) handleLogin(w http.ResponseWriter, r *http.Request) {
-	p.renderTemplate(w, r, "login.html", "Login", map[string]interface{}{})
+	if err := p.renderTemplate(w, r, "login.html", "Login", map[string]interface{}{}); err != nil {
+		serverError(w, r, err)
+	}
 }
 
 func (p *Portal) handleRegister(w http.ResponseWriter, r *http.Request) {
@@ -40,7 +52,9 @@ func (p *Portal) handleRegister(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("[REGISTER] Rendering register template with data: %+v", data)
-	p.renderTemplate(w, r, "register.html", "Register", data)
+	if err := p.renderTemplate(w, r, "register.html", "Register", data); err != nil {
+		serverError(w, r, err)
+	}
 }
 
 func (p *Portal) handleLoginRedirect(w http.ResponseWriter, r *http.Request) {
@@ -97,7 +111,12 @@ func (p *Portal) handleAboutRedirect(w http.ResponseWriter, r *http.Request) {
 }
 
 func (p *Portal) handleDocumentation(w http.ResponseWriter, r *http.Request) {
-	p.renderTemplate(w, r, "documentation.html", "Documentation", map[string]interface{}{})
+	data := map[string]interface{}{
+		"Modules": models.SyntheaModules(),
+	}
+	if err := p.renderTemplate(w, r, "documentation.html", "Documentation", data); err != nil {
+		serverError(w, r, err)
+	}
 }
 
 func (p *Portal) handleSwaggerProxy(w http.ResponseWriter, r *http.Request) {
@@ -110,36 +129,10 @@ func (p *Portal) handleSwaggerProxy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create API URL using configured internal URL
-	apiURL := p.config.APIInternalURL + r.URL.Path
-	if r.URL.RawQuery != "" {
-		apiURL += "?" + r.URL.RawQuery
-	}
-
-	// Create proxy request
-	proxyReq, err := http.NewRequest(r.Method, apiURL, r.Body)
+	resp, err := p.api.Forward(r)
 	if err != nil {
-		http.Error(w, "Failed to create proxy request", http.StatusInternalServerError)
-		return
-	}
-
-	// Copy headers from original request
-	for name, values := range r.Header {
-		for _, value := range values {
-			proxyReq.Header.Add(name, value)
-		}
-	}
-
-	// Forward the user's session cookie for API authentication
-	if cookie, err := r.Cookie("session"); err == nil {
-		proxyReq.AddCookie(cookie)
-	}
-
-	// Execute the proxy request
-	client := &http.Client{}
-	resp, err := client.Do(proxyReq)
-	if err != nil {
-		http.Error(w, "Failed to proxy request", http.StatusInternalServerError)
+		log.Printf("[SWAGGER] Proxy request failed: %v", err)
+		http.Error(w, "Failed to proxy request", http.StatusBadGateway)
 		return
 	}
 	defer resp.Body.Close()
@@ -176,21 +169,39 @@ func (p *Portal) handleLoginPost(w http.ResponseWriter, r *http.Request) {
 	user, err := auth.ValidateUser(email, password)
 	if err != nil {
 		log.Printf("[PORTAL] User validation failed for %s: %v", email, err)
-		p.renderTemplate(w, r, "login.html", "Login", map[string]interface{}{"Error": "Invalid email or password", "Email": email})
+		if err := p.renderTemplate(w, r, "login.html", "Login", map[string]interface{}{"Error": "Invalid email or password", "Email": email}); err != nil {
+			serverError(w, r, err)
+		}
 		return
 	}
 
 	log.Printf("[PORTAL] User validation successful for %s (UserID: %s)", email, user.ID)
 
-	token, err := auth.CreateSession(user.ID)
+	duration := p.config.SessionDuration()
+	if r.FormValue("remember-me") != "" {
+		duration = p.config.RememberMeDuration()
+	}
+
+	token, expiresAt, err := auth.CreateSession(user.ID, duration)
 	if err != nil {
 		log.Printf("ERROR: Session creation failed for user %s: %v", user.ID, err)
-		p.renderTemplate(w, r, "login.html", "Login", map[string]interface{}{"Error": "Failed to create session.", "Email": email})
+		if err := p.renderTemplate(w, r, "login.html", "Login", map[string]interface{}{"Error": "Failed to create session.", "Email": email}); err != nil {
+			serverError(w, r, err)
+		}
 		return
 	}
 
 	log.Printf("[PORTAL] Session created successfully for user %s, token length: %d", user.ID, len(token))
 
+	p.setSessionCookie(w, token, expiresAt)
+
+	log.Printf("[PORTAL] Session cookie set for user %s, redirecting to dashboard", user.ID)
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// setSessionCookie stores the session token in a cookie that expires together
+// with the session itself
+func (p *Portal) setSessionCookie(w http.ResponseWriter, token string, expiresAt time.Time) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session",
 		Value:    token,
@@ -199,11 +210,8 @@ func (p *Portal) handleLoginPost(w http.ResponseWriter, r *http.Request) {
 		HttpOnly: true,
 		Secure:   p.config.DomainSecure,
 		SameSite: http.SameSiteStrictMode,
-		Expires:  time.Now().Add(24 * time.Hour),
+		Expires:  expiresAt,
 	})
-
-	log.Printf("[PORTAL] Session cookie set for user %s, redirecting to dashboard", user.ID)
-	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
 }
 
 func (p *Portal) handleRegisterPost(w http.ResponseWriter, r *http.Request) {
@@ -221,21 +229,28 @@ func (p *Portal) handleRegisterPost(w http.ResponseWriter, r *http.Request) {
 	if !auth.ValidateEmail(email) {
 		log.Printf("[PORTAL] Invalid email format: %s", email)
 		data["Error"] = "Please enter a valid email address"
-		p.renderTemplate(w, r, "register.html", "Register", data)
+		if err := p.renderTemplate(w, r, "register.html", "Register", data); err != nil {
+			serverError(w, r, err)
+		}
 		return
 	}
 
 	if password != confirmPassword {
 		log.Printf("[PORTAL] Password mismatch for email: %s", email)
 		data["Error"] = "Passwords do not match"
-		p.renderTemplate(w, r, "register.html", "Register", data)
+		if err := p.renderTemplate(w, r, "register.html", "Register", data); err != nil {
+			serverError(w, r, err)
+		}
 		return
 	}
 
-	if !auth.ValidatePassword(password) {
+	if problems := auth.PasswordProblems(password); len(problems) > 0 {
 		log.Printf("[PORTAL] Password validation failed for email: %s", email)
 		data["Error"] = "Password does not meet the requirements"
-		p.renderTemplate(w, r, "register.html", "Register", data)
+		data["PasswordProblems"] = problems
+		if err := p.renderTemplate(w, r, "register.html", "Register", data); err != nil {
+			serverError(w, r, err)
+		}
 		return
 	}
 
@@ -243,19 +258,21 @@ func (p *Portal) handleRegisterPost(w http.ResponseWriter, r *http.Request) {
 	user, err := auth.RegisterUser(email, password)
 	if err != nil {
 		log.Printf("[PORTAL] User registration failed for %s: %v", email, err)
-		if strings.Contains(err.Error(), "UNIQUE constraint") || strings.Contains(err.Error(), "duplicate key") {
+		if errors.Is(err, auth.ErrEmailAlreadyExists) {
 			data["Error"] = "This email is already registered."
 		} else {
 			data["Error"] = "Registration failed. Please try again later."
 			log.Printf("ERROR: Failed to register user %s: %v", email, err)
 		}
-		p.renderTemplate(w, r, "register.html", "Register", data)
+		if err := p.renderTemplate(w, r, "register.html", "Register", data); err != nil {
+			serverError(w, r, err)
+		}
 		return
 	}
 
 	log.Printf("[PORTAL] User registered successfully: %s (UserID: %s)", email, user.ID)
 
-	token, err := auth.CreateSession(user.ID)
+	token, expiresAt, err := auth.CreateSession(user.ID, p.config.SessionDuration())
 	if err != nil {
 		log.Printf("ERROR: User %s registered but session creation failed: %v", email, err)
 		// User is registered, but we can't log them in.
@@ -266,16 +283,7 @@ func (p *Portal) handleRegisterPost(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("[PORTAL] Session created successfully for new user %s, token length: %d", user.ID, len(token))
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session",
-		Value:    token,
-		Path:     "/",
-		Domain:   p.config.DomainPortal,
-		HttpOnly: true,
-		Secure:   p.config.DomainSecure,
-		SameSite: http.SameSiteStrictMode,
-		Expires:  time.Now().Add(24 * time.Hour),
-	})
+	p.setSessionCookie(w, token, expiresAt)
 
 	log.Printf("[PORTAL] Registration complete for %s, redirecting to dashboard", email)
 	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
@@ -292,6 +300,13 @@ func (p *Portal) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[DASHBOARD] Rendering dashboard for user: %s", userID)
 	log.Printf("[DASHBOARD] Request from host: %s, RemoteAddr: %s", r.Host, r.RemoteAddr)
 
+	user, err := database.GetUserByIDContext(r.Context(), userID)
+	if err != nil {
+		log.Printf("[DASHBOARD] Error getting user %s: %v", userID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	// Get API tokens count
 	tokens, err := database.GetUserTokens(userID)
 	if err != nil {
@@ -301,7 +316,7 @@ func (p *Portal) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get job statistics
-	jobs, err := database.GetJobsByUserID(userID)
+	jobs, err := database.GetJobsByUserIDContext(r.Context(), userID)
 	if err != nil {
 		log.Printf("[DASHBOARD] Error getting jobs for user %s: %v", userID, err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -320,6 +335,13 @@ func (p *Portal) handleDashboard(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("[DASHBOARD] Found %d tokens, %d jobs, %d total patients for user %s", len(tokens), len(jobs), totalPatients, userID)
 
+	usage, err := quota.ForUser(r.Context(), database.Default(), p.config, userID, time.Now())
+	if err != nil {
+		log.Printf("[DASHBOARD] Error getting quota usage for user %s: %v", userID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	data := struct {
 		APIRequests      int    `json:"apiRequests"`
 		RecordsGenerated int    `json:"recordsGenerated"`
@@ -327,20 +349,41 @@ func (p *Portal) handleDashboard(w http.ResponseWriter, r *http.Request) {
 		TotalJobs        int    `json:"totalJobs"`
 		CompletedJobs    int    `json:"completedJobs"`
 		ActiveTokens     int    `json:"activeTokens"`
+		// Quota is nil when no monthly patient quota applies to the user
+		Quota *quota.Usage `json:"quota,omitempty"`
 	}{
 		APIRequests:      len(jobs), // Each job represents an API request
 		RecordsGenerated: totalPatients,
-		AccountType:      "Free",
+		AccountType:      user.TierLabel(),
 		TotalJobs:        len(jobs),
 		CompletedJobs:    completedJobs,
 		ActiveTokens:     len(tokens),
+		Quota:            usage,
 	}
 
-	p.renderTemplate(w, r, "dashboard.html", "Dashboard", data)
+	if err := p.renderTemplate(w, r, "dashboard.html", "Dashboard", data); err != nil {
+		serverError(w, r, err)
+	}
 }
 
 func (p *Portal) handleTokens(w http.ResponseWriter, r *http.Request) {
-	p.renderTemplate(w, r, "tokens.html", "API Tokens", nil)
+	userID := r.Context().Value("userID").(string)
+
+	tokens, err := auth.ListTokens(userID)
+	if err != nil {
+		log.Printf("Error listing tokens for user %s: %v", userID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Tokens":   tokens,
+		"NewToken": r.URL.Query().Get("new_token"),
+		"Revoked":  r.URL.Query().Get("revoked"),
+	}
+	if err := p.renderTemplate(w, r, "tokens.html", "API Tokens", data); err != nil {
+		serverError(w, r, fmt.Errorf("tokens page for user %s: %w", userID, err))
+	}
 }
 
 func (p *Portal) handleCreateToken(w http.ResponseWriter, r *http.Request) {
@@ -381,6 +424,110 @@ func (p *Portal) handleDeleteToken(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/tokens", http.StatusSeeOther)
 }
 
+func (p *Portal) handleRevokeAllTokens(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	revoked, err := auth.DeleteUserTokens(userID)
+	if err != nil {
+		log.Printf("Error revoking tokens for user %s: %v", userID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/tokens?revoked="+strconv.FormatInt(revoked, 10), http.StatusSeeOther)
+}
+
+// currentSessionID returns the ID of the session the request was made with
+func currentSessionID(r *http.Request) (string, error) {
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		return "", err
+	}
+	session, err := database.GetSessionByToken(cookie.Value)
+	if err != nil {
+		return "", err
+	}
+	return session.ID, nil
+}
+
+func (p *Portal) handleSessions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	sessions, err := database.GetSessionsByUserID(userID)
+	if err != nil {
+		log.Printf("Error listing sessions for user %s: %v", userID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	currentID, err := currentSessionID(r)
+	if err != nil {
+		log.Printf("Warning: could not resolve current session for user %s: %v", userID, err)
+	}
+
+	data := map[string]interface{}{
+		"Sessions":         sessions,
+		"CurrentSessionID": currentID,
+		"Revoked":          r.URL.Query().Get("revoked"),
+	}
+	if err := p.renderTemplate(w, r, "sessions.html", "Sessions", data); err != nil {
+		serverError(w, r, fmt.Errorf("sessions page for user %s: %w", userID, err))
+	}
+}
+
+func (p *Portal) handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	sessionID := chi.URLParam(r, "id")
+	if sessionID == "" {
+		http.Error(w, "Session ID required", http.StatusBadRequest)
+		return
+	}
+
+	currentID, err := currentSessionID(r)
+	if err != nil {
+		log.Printf("Error resolving current session for user %s: %v", userID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if sessionID == currentID {
+		http.Error(w, "Use sign out to end the current session", http.StatusBadRequest)
+		return
+	}
+
+	err = database.DeleteUserSession(userID, sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error revoking session %s: %v", sessionID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/account/sessions?revoked=1", http.StatusSeeOther)
+}
+
+func (p *Portal) handleRevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	currentID, err := currentSessionID(r)
+	if err != nil {
+		log.Printf("Error resolving current session for user %s: %v", userID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	revoked, err := database.DeleteOtherSessions(userID, currentID)
+	if err != nil {
+		log.Printf("Error revoking other sessions for user %s: %v", userID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/account/sessions?revoked="+strconv.FormatInt(revoked, 10), http.StatusSeeOther)
+}
+
 func (p *Portal) handleLogout(w http.ResponseWriter, r *http.Request) {
 	cookie, err := r.Cookie("session")
 	if err == nil {
@@ -410,21 +557,168 @@ func (p *Portal) handleJobs(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[JOBS] Rendering jobs for user: %s", userID)
 	log.Printf("[JOBS] Request from host: %s, RemoteAddr: %s", r.Host, r.RemoteAddr)
 
-	jobs, err := database.GetJobsByUserID(userID)
+	params := r.URL.Query()
+	query := strings.TrimSpace(params.Get("q"))
+	data := map[string]interface{}{
+		"Query":   query,
+		"Status":  params.Get("status"),
+		"From":    params.Get("from"),
+		"To":      params.Get("to"),
+		"Deleted": params.Get("deleted") != "",
+	}
+
+	filter, err := models.ParseJobFilter(params.Get("status"), params.Get("from"), params.Get("to"))
+	if err != nil {
+		data["Error"] = err.Error()
+		if err := p.renderTemplateStatus(w, r, http.StatusBadRequest, "jobs.html", "Generation History", data); err != nil {
+			serverError(w, r, fmt.Errorf("jobs page for user %s: %w", userID, err))
+		}
+		return
+	}
+
+	var jobs []*models.Job
+	if query != "" {
+		// Search results are narrowed by the filter in memory
+		var found []*models.Job
+		found, err = database.SearchJobsContext(r.Context(), userID, query)
+		for _, job := range found {
+			if filter.Matches(job) {
+				jobs = append(jobs, job)
+			}
+		}
+	} else {
+		jobs, err = database.GetJobsFilteredContext(r.Context(), userID, string(filter.Status), filter.From, filter.To)
+	}
 	if err != nil {
 		log.Printf("[JOBS] Error getting jobs for user %s: %v", userID, err)
 		http.Error(w, "Could not retrieve job history.", http.StatusInternalServerError)
 		return
 	}
 
-	data := map[string]interface{}{
-		"Jobs": jobs,
+	for _, job := range jobs {
+		job.SetOutputExpiry(p.config.JobOutputRetention())
+	}
+
+	data["Jobs"] = jobs
+	data["Filtered"] = query != "" || !filter.IsEmpty()
+	if err := p.renderTemplate(w, r, "jobs.html", "Generation History", data); err != nil {
+		serverError(w, r, fmt.Errorf("jobs page for user %s: %w", userID, err))
 	}
-	p.renderTemplate(w, r, "jobs.html", "Generation History", data)
+}
+
+// handleDeleteJob deletes a finished job and its output through the API,
+// which checks ownership and removes the S3 objects before the record
+func (p *Portal) handleDeleteJob(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	jobID := chi.URLParam(r, "id")
+
+	if err := p.api.DeleteJob(r, jobID); err != nil {
+		var apiErr *apiclient.Error
+		if errors.As(err, &apiErr) && apiErr.IsClientError() {
+			http.Error(w, apiErr.Message, apiErr.StatusCode)
+			return
+		}
+		serverError(w, r, fmt.Errorf("delete job %s for user %s: %w", jobID, userID, err))
+		return
+	}
+
+	http.Redirect(w, r, "/jobs?deleted=1", http.StatusSeeOther)
 }
 
 func (p *Portal) handleNewJob(w http.ResponseWriter, r *http.Request) {
-	p.renderTemplate(w, r, "new-job.html", "New Job", nil)
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok {
+		log.Printf("Error: userID not found in context")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	presets, err := database.GetPresetsForUser(userID)
+	if err != nil {
+		// Presets are a convenience; still let the user fill in the form by hand
+		log.Printf("[JOBS] Error getting presets for user %s: %v", userID, err)
+	}
+
+	form := map[string]string{"population": "10", "outputFormat": "fhir"}
+	selectedModules := map[string]bool{}
+	selected := r.URL.Query().Get("preset")
+	for _, preset := range presets {
+		if preset.ID == selected {
+			form = presetFormValues(preset.Parameters)
+			selectedModules = moduleSet(preset.Parameters.KeepModules)
+			break
+		}
+	}
+
+	data := map[string]interface{}{
+		"Presets":         presets,
+		"SelectedPreset":  selected,
+		"Form":            form,
+		"Modules":         models.SyntheaModules(),
+		"SelectedModules": selectedModules,
+		"RetentionDays":   p.config.JobOutputRetentionDays,
+	}
+	if err := p.renderTemplate(w, r, "new-job.html", "New Job", data); err != nil {
+		serverError(w, r, err)
+	}
+}
+
+// renderNewJobError re-renders the new-job form with the submitted values and an error
+func (p *Portal) renderNewJobError(w http.ResponseWriter, r *http.Request, status int, errMsg string) {
+	form := map[string]string{}
+	for _, field := range []string{"population", "gender", "ageMin", "ageMax", "state", "city", "outputFormat", "presetName"} {
+		form[field] = r.FormValue(field)
+	}
+
+	var presets []*models.JobPreset
+	if userID, ok := r.Context().Value("userID").(string); ok {
+		presets, _ = database.GetPresetsForUser(userID)
+	}
+
+	data := map[string]interface{}{
+		"Presets":         presets,
+		"Form":            form,
+		"Modules":         models.SyntheaModules(),
+		"SelectedModules": moduleSet(r.Form["keepModules"]),
+		"RetentionDays":   p.config.JobOutputRetentionDays,
+		"Error":           errMsg,
+	}
+	if err := p.renderTemplateStatus(w, r, status, "new-job.html", "New Job", data); err != nil {
+		serverError(w, r, err)
+	}
+}
+
+// moduleSet turns a module list into the set the new-job template checks against
+func moduleSet(modules []string) map[string]bool {
+	set := make(map[string]bool, len(modules))
+	for _, module := range modules {
+		set[module] = true
+	}
+	return set
+}
+
+// presetFormValues flattens preset parameters into new-job form field values
+func presetFormValues(params models.SyntheaParams) map[string]string {
+	form := map[string]string{"outputFormat": params.GetOutputFormat()}
+	if params.Population != nil {
+		form["population"] = strconv.Itoa(*params.Population)
+	}
+	if params.Gender != nil {
+		form["gender"] = *params.Gender
+	}
+	if params.AgeMin != nil {
+		form["ageMin"] = strconv.Itoa(*params.AgeMin)
+	}
+	if params.AgeMax != nil {
+		form["ageMax"] = strconv.Itoa(*params.AgeMax)
+	}
+	if params.State != nil {
+		form["state"] = *params.State
+	}
+	if params.City != nil {
+		form["city"] = *params.City
+	}
+	return form
 }
 
 func (p *Portal) handleCreateJob(w http.ResponseWriter, r *http.Request) {
@@ -433,58 +727,73 @@ func (p *Portal) handleCreateJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	params := models.SyntheaParams{
-		Population:   toIntPtr(r.FormValue("population")),
-		Gender:       toStringPtr(r.FormValue("gender")),
-		AgeMin:       toIntPtr(r.FormValue("ageMin")),
-		AgeMax:       toIntPtr(r.FormValue("ageMax")),
-		State:        toStringPtr(r.FormValue("state")),
-		City:         toStringPtr(r.FormValue("city")),
-		OutputFormat: toStringPtr(r.FormValue("outputFormat")),
-	}
-
-	bodyBytes, err := json.Marshal(params)
-	if err != nil {
-		log.Printf("ERROR: Failed to marshal job params: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	params := jobParamsFromForm(r)
+	if err := params.Validate(p.config.MaxPopulation); err != nil {
+		p.renderNewJobError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Use configured internal API URL
-	apiURL := p.config.APIInternalURL + "/generate-patients"
-
-	// Create the request to the API service
-	apiReq, err := http.NewRequestWithContext(r.Context(), "POST", apiURL, bytes.NewReader(bodyBytes))
-	if err != nil {
-		log.Printf("ERROR: Failed to create API request: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	if _, err := p.api.CreateJob(r, params); err != nil {
+		log.Printf("ERROR: Failed to create job through the API: %v", err)
+		// The API's own message is only useful to the user when it rejected the request
+		var apiErr *apiclient.Error
+		if errors.As(err, &apiErr) && apiErr.IsClientError() {
+			p.renderNewJobError(w, r, apiErr.StatusCode, apiErr.Message)
+			return
+		}
+		p.renderNewJobError(w, r, http.StatusBadGateway, "The job could not be started because of a server problem. Please try again in a few minutes.")
 		return
 	}
 
-	// Forward the user's session cookie for authentication
-	if cookie, err := r.Cookie("session"); err == nil {
-		apiReq.AddCookie(cookie)
+	// Only parameters the API accepted are worth saving as a preset
+	if name := strings.TrimSpace(r.FormValue("presetName")); name != "" {
+		userID := r.Context().Value("userID").(string)
+		preset := &models.JobPreset{UserID: &userID, Name: name, Parameters: params}
+		if err := database.CreatePreset(preset); err != nil {
+			// Don't fail the job on a preset that couldn't be saved (e.g. duplicate name)
+			log.Printf("[JOBS] Failed to save preset %q for user %s: %v", name, userID, err)
+		}
 	}
 
-	apiReq.Header.Set("Content-Type", "application/json")
+	http.Redirect(w, r, "/jobs", http.StatusSeeOther)
+}
 
-	// Execute the request
-	client := &http.Client{}
-	apiRes, err := client.Do(apiReq)
-	if err != nil {
-		log.Printf("ERROR: Failed to call API service: %v", err)
-		http.Error(w, "Failed to start job. Could not contact API service.", http.StatusInternalServerError)
+// handleValidateJob checks the new-job form with the same parsing and
+// validation as handleCreateJob, without creating a job, and reports every
+// invalid field as JSON so the form can show the errors inline
+func (p *Portal) handleValidateJob(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
 		return
 	}
-	defer apiRes.Body.Close()
 
-	if apiRes.StatusCode >= 400 {
-		log.Printf("ERROR: API service returned status %d", apiRes.StatusCode)
-		http.Error(w, "Failed to create generation job.", http.StatusInternalServerError)
-		return
+	params := jobParamsFromForm(r)
+	resp := map[string]interface{}{"valid": true}
+	status := http.StatusOK
+	if err := params.Validate(p.config.MaxPopulation); err != nil {
+		fieldErrs := models.ValidationErrors{}
+		errors.As(err, &fieldErrs)
+		resp = map[string]interface{}{"valid": false, "message": err.Error(), "errors": fieldErrs}
+		status = http.StatusUnprocessableEntity
 	}
 
-	http.Redirect(w, r, "/jobs", http.StatusSeeOther)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// jobParamsFromForm reads the job parameters of a parsed new-job form
+func jobParamsFromForm(r *http.Request) models.SyntheaParams {
+	return models.SyntheaParams{
+		Population:   toIntPtr(r.FormValue("population")),
+		Gender:       toStringPtr(r.FormValue("gender")),
+		AgeMin:       toIntPtr(r.FormValue("ageMin")),
+		AgeMax:       toIntPtr(r.FormValue("ageMax")),
+		State:        toStringPtr(r.FormValue("state")),
+		City:         toStringPtr(r.FormValue("city")),
+		OutputFormat: toStringPtr(r.FormValue("outputFormat")),
+		KeepModules:  r.Form["keepModules"],
+	}
 }
 
 // Helper functions to convert form values to pointers for the SyntheaParams struct
@@ -506,14 +815,23 @@ func toStringPtr(s string) *string {
 	return &s
 }
 
-func (p *Portal) renderTemplate(w http.ResponseWriter, r *http.Request, tmplName string, pageTitle string, data interface{}) {
+// renderTemplate renders a cached page template. Output is buffered and only
+// written once the template has executed completely, so on error nothing has
+// been sent and the caller can still respond as it sees fit.
+func (p *Portal) renderTemplate(w http.ResponseWriter, r *http.Request, tmplName string, pageTitle string, data interface{}) error {
+	return p.renderTemplateStatus(w, r, http.StatusOK, tmplName, pageTitle, data)
+}
+
+// renderTemplateStatus is renderTemplate for pages sent with a status other
+// than 200, such as a form shown again with an error. The status is only
+// written once the page has rendered, so a failed render can still become a
+// 500.
+func (p *Portal) renderTemplateStatus(w http.ResponseWriter, r *http.Request, status int, tmplName string, pageTitle string, data interface{}) error {
 	log.Printf("Rendering template: %s", tmplName)
 
-	ts, ok := p.templates[tmplName]
-	if !ok {
-		log.Printf("Error: template %s not found", tmplName)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+	ts, err := p.lookupTemplate(tmplName)
+	if err != nil {
+		return err
 	}
 
 	// Create a map to hold template data
@@ -535,7 +853,7 @@ func (p *Portal) renderTemplate(w http.ResponseWriter, r *http.Request, tmplName
 
 	// Only try to fetch user data if there's a userID in the context
 	if userID, ok := r.Context().Value("userID").(string); ok && userID != "" {
-		user, err := database.GetUserByID(userID)
+		user, err := database.GetUserByIDContext(r.Context(), userID)
 		if err != nil {
 			log.Printf("Warning: Failed to get user data for ID %s: %v", userID, err)
 			// Don't fail the template rendering, just log the warning
@@ -545,13 +863,48 @@ func (p *Portal) renderTemplate(w http.ResponseWriter, r *http.Request, tmplName
 	}
 
 	log.Printf("Executing template %s with data keys: %v", tmplName, getMapKeys(templateData))
-	err := ts.ExecuteTemplate(w, "base.html", templateData)
+	// Bind the request-specific helpers to a copy so the cached set stays untouched
+	ts, err = ts.Clone()
 	if err != nil {
-		log.Printf("Error rendering template %s: %v", tmplName, err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+		return fmt.Errorf("clone template %s: %w", tmplName, err)
+	}
+	ts.Funcs(requestFuncs(r))
+
+	var buf bytes.Buffer
+	if err := ts.ExecuteTemplate(&buf, "base.html", templateData); err != nil {
+		return fmt.Errorf("render template %s: %w", tmplName, err)
+	}
+	w.WriteHeader(status)
+	if _, err := buf.WriteTo(w); err != nil {
+		// The client went away; there is nobody left to report this to
+		log.Printf("Warning: failed to write template %s: %v", tmplName, err)
 	}
 	log.Printf("Successfully rendered template: %s", tmplName)
+	return nil
+}
+
+// lookupTemplate returns the cached page template, or in dev mode parses it
+// afresh so edits show up without a restart
+func (p *Portal) lookupTemplate(tmplName string) (*template.Template, error) {
+	if p.config.DevMode {
+		ts, err := parsePage(p.templateFS, tmplName)
+		if err != nil {
+			return nil, fmt.Errorf("parse template %s: %w", tmplName, err)
+		}
+		return ts, nil
+	}
+
+	ts, ok := p.templates[tmplName]
+	if !ok {
+		return nil, fmt.Errorf("template %s not found", tmplName)
+	}
+	return ts, nil
+}
+
+// serverError logs err with the request it failed and responds with a generic 500
+func serverError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("ERROR: %s %s: %v", r.Method, r.URL.Path, err)
+	http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 }
 
 // Helper function to get map keys for logging
@@ -0,0 +1,137 @@
+package portal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/auth"
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobsPageSearch(t *testing.T) {
+	p := newTestPortal(t, &config.Config{})
+
+	user, err := database.CreateUser(fmt.Sprintf("jobs-page-%d@example.com", time.Now().UnixNano()), "password")
+	require.NoError(t, err)
+	ids := map[string]string{}
+	statuses := map[string]models.JobStatus{"Vermont": models.JobStatusCompleted, "Oregon": models.JobStatusFailed}
+	for _, state := range []string{"Vermont", "Oregon"} {
+		job := &models.Job{
+			ID:           "job-" + database.GenerateID(),
+			UserID:       user.ID,
+			JobID:        "synthea-" + database.GenerateID(),
+			Status:       statuses[state],
+			Parameters:   map[string]interface{}{"population": 5, "state": state},
+			OutputFormat: "fhir",
+		}
+		require.NoError(t, job.MarshalParameters())
+		require.NoError(t, database.CreateJob(job))
+		ids[state] = job.ID
+	}
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req = req.WithContext(context.WithValue(req.Context(), "userID", user.ID))
+		rec := httptest.NewRecorder()
+		p.handleJobs(rec, req)
+		return rec
+	}
+	get := func(target string) string {
+		rec := serve(target)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+
+	all := get("/jobs")
+	assert.Contains(t, all, ids["Vermont"])
+	assert.Contains(t, all, ids["Oregon"])
+
+	filtered := get("/jobs?q=verm")
+	assert.Contains(t, filtered, ids["Vermont"])
+	assert.NotContains(t, filtered, ids["Oregon"])
+
+	none := get("/jobs?q=nowhere")
+	assert.Contains(t, none, "No jobs match these filters")
+
+	failedOnly := get("/jobs?status=failed")
+	assert.Contains(t, failedOnly, ids["Oregon"])
+	assert.NotContains(t, failedOnly, ids["Vermont"])
+
+	searchFailed := get("/jobs?q=verm&status=failed")
+	assert.NotContains(t, searchFailed, ids["Vermont"])
+
+	rec := serve("/jobs?from=2024-03-02&to=2024-03-01")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "must not be after to")
+}
+
+func TestDeleteJob(t *testing.T) {
+	var deleted []string
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		if strings.HasPrefix(r.URL.Path, "/jobs/job-done-") {
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/jobs/"))
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, `{"error":{"code":"conflict","message":"Only completed or failed jobs can be deleted"}}`)
+		}
+	}))
+	defer stub.Close()
+	p := newTestPortal(t, &config.Config{APIInternalURL: stub.URL})
+	routes := p.Routes()
+
+	email := fmt.Sprintf("delete-job-%d@example.com", time.Now().UnixNano())
+	user, err := auth.RegisterUser(email, "Password1!")
+	require.NoError(t, err)
+	doneID, runningID := "job-done-"+user.ID, "job-running-"+user.ID
+	for id, status := range map[string]models.JobStatus{doneID: models.JobStatusCompleted, runningID: models.JobStatusRunning} {
+		job := &models.Job{ID: id, UserID: user.ID, JobID: "synthea-" + id, Status: status, OutputFormat: "fhir"}
+		require.NoError(t, job.MarshalParameters())
+		require.NoError(t, database.CreateJob(job))
+	}
+	session := login(t, p, email, false)
+
+	req := httptest.NewRequest(http.MethodGet, "/jobs", nil)
+	req.AddCookie(session)
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `action="/jobs/`+doneID+`/delete"`)
+	assert.NotContains(t, rec.Body.String(), `action="/jobs/`+runningID+`/delete"`, "unfinished jobs have no delete button")
+
+	post := func(target string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(session)
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		return rec
+	}
+	withCSRF := url.Values{csrfFieldName: {csrfToken(session.Value)}}
+
+	rec = post("/jobs/"+doneID+"/delete", url.Values{})
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the form needs a CSRF token")
+	assert.Empty(t, deleted)
+
+	rec = post("/jobs/"+doneID+"/delete", withCSRF)
+	require.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/jobs?deleted=1", rec.Header().Get("Location"))
+	assert.Equal(t, []string{doneID}, deleted)
+
+	rec = post("/jobs/"+runningID+"/delete", withCSRF)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Only completed or failed jobs can be deleted")
+}
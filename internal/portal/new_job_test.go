@@ -0,0 +1,178 @@
+package portal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPortal builds a portal with the templates embedded in the binary
+func newTestPortal(t *testing.T, cfg *config.Config) *Portal {
+	t.Helper()
+	p, err := New(cfg)
+	require.NoError(t, err)
+	return p
+}
+
+func TestCreateJobRejectsUnknownModules(t *testing.T) {
+	p := newTestPortal(t, &config.Config{MaxPopulation: 100})
+
+	form := url.Values{
+		"population":   {"10"},
+		"outputFormat": {"fhir"},
+		"keepModules":  {"asthma", "not_a_module"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/jobs/new", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(context.WithValue(req.Context(), "userID", "module-test-user"))
+	rec := httptest.NewRecorder()
+
+	p.handleCreateJob(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "unknown modules: not_a_module")
+	// The valid selection is kept when the form is shown again
+	assert.Contains(t, body, `value="asthma" checked`)
+}
+
+func TestCreateJobErrorPageFailsCleanly(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "base.html"), []byte(`<main>{{template "content" .}}</main>`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new-job.html"), []byte(`{{define "content"}}{{index .Missing 3}}{{end}}`), 0o644))
+	p := newTestPortal(t, &config.Config{DevTemplateDir: dir, MaxPopulation: 100})
+
+	form := url.Values{"population": {"10"}, "outputFormat": {"fhir"}, "keepModules": {"not_a_module"}}
+	req := httptest.NewRequest(http.MethodPost, "/jobs/new", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(context.WithValue(req.Context(), "userID", "module-test-user"))
+	rec := httptest.NewRecorder()
+
+	p.handleCreateJob(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code, "the 400 is not sent before the form has rendered")
+}
+
+func TestCreateJobShowsAPIErrors(t *testing.T) {
+	status, body := http.StatusBadRequest, `{"error":{"code":"validation_failed","message":"state: unknown state \"Atlantis\""}}`
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	defer stub.Close()
+	p := newTestPortal(t, &config.Config{MaxPopulation: 100, APIInternalURL: stub.URL})
+
+	submit := func() *httptest.ResponseRecorder {
+		form := url.Values{
+			"population":   {"10"},
+			"state":        {"Atlantis"},
+			"outputFormat": {"csv"},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/jobs/new", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(context.WithValue(req.Context(), "userID", "api-error-user"))
+		rec := httptest.NewRecorder()
+		p.handleCreateJob(rec, req)
+		return rec
+	}
+
+	rec := submit()
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	page := rec.Body.String()
+	assert.Contains(t, page, "state: unknown state &#34;Atlantis&#34;")
+	// The submitted values are kept
+	assert.Contains(t, page, `value="Atlantis"`)
+	assert.Contains(t, page, `<option selected>csv</option>`)
+
+	status, body = http.StatusInternalServerError, `{"error":{"code":"internal","message":"Failed to create job"}}`
+	rec = submit()
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	page = rec.Body.String()
+	assert.NotContains(t, page, "Failed to create job")
+	assert.Contains(t, page, "Please try again in a few minutes.")
+	assert.Contains(t, page, `value="Atlantis"`)
+}
+
+func TestCreateJobSavesPresetOnlyOnSuccess(t *testing.T) {
+	status, body := http.StatusBadRequest, `{"error":{"code":"validation_failed","message":"state: unknown state \"Atlantis\""}}`
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	defer stub.Close()
+	p := newTestPortal(t, &config.Config{MaxPopulation: 100, APIInternalURL: stub.URL})
+	user, err := database.CreateUser("preset-save@example.com", "password")
+	require.NoError(t, err)
+
+	submit := func(presetName string) *httptest.ResponseRecorder {
+		form := url.Values{"population": {"10"}, "state": {"Atlantis"}, "outputFormat": {"csv"}, "presetName": {presetName}}
+		req := httptest.NewRequest(http.MethodPost, "/jobs/new", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(context.WithValue(req.Context(), "userID", user.ID))
+		rec := httptest.NewRecorder()
+		p.handleCreateJob(rec, req)
+		return rec
+	}
+	presetNames := func() []string {
+		presets, err := database.GetPresetsForUser(user.ID)
+		require.NoError(t, err)
+		var names []string
+		for _, preset := range presets {
+			if preset.UserID != nil {
+				names = append(names, preset.Name)
+			}
+		}
+		return names
+	}
+
+	rec := submit("Rejected cohort")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, presetNames(), "parameters the API rejected are not saved")
+
+	status, body = http.StatusAccepted, `{"job_id":"job-1","status":"pending"}`
+	rec = submit("Accepted cohort")
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, []string{"Accepted cohort"}, presetNames())
+}
+
+func TestValidateJob(t *testing.T) {
+	p := newTestPortal(t, &config.Config{MaxPopulation: 100})
+
+	validate := func(form url.Values) (int, map[string]interface{}) {
+		req := httptest.NewRequest(http.MethodPost, "/jobs/validate", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(context.WithValue(req.Context(), "userID", "validate-test-user"))
+		rec := httptest.NewRecorder()
+		p.handleValidateJob(rec, req)
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+		return rec.Code, body
+	}
+
+	status, body := validate(url.Values{"population": {"10"}, "outputFormat": {"fhir"}})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, body["valid"])
+
+	status, body = validate(url.Values{"population": {"0"}, "gender": {"X"}, "outputFormat": {"fhir"}})
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, false, body["valid"])
+	errs, ok := body["errors"].(map[string]interface{})
+	require.True(t, ok, "got %v", body)
+	assert.Contains(t, errs, "population")
+	assert.Contains(t, errs, "gender")
+	assert.NotContains(t, errs, "outputFormat", "only invalid fields are reported")
+}
@@ -7,28 +7,68 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/MediSynth-io/medisynth"
+	"github.com/MediSynth-io/medisynth/internal/apiclient"
 	"github.com/MediSynth-io/medisynth/internal/auth"
+	"github.com/MediSynth-io/medisynth/internal/compress"
 	"github.com/MediSynth-io/medisynth/internal/config"
 	"github.com/go-chi/chi/v5"
 )
 
 type Portal struct {
-	templates map[string]*template.Template
-	config    *config.Config
+	templates  map[string]*template.Template
+	templateFS fs.FS // Where templates are re-read from in dev mode
+	config     *config.Config
+	api        *apiclient.Client
 }
 
 func New(cfg *config.Config) (*Portal, error) {
-	templates := make(map[string]*template.Template)
+	fsys := templateSource(cfg)
+	templates, err := loadTemplates(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Successfully loaded templates")
+	if cfg.DevMode {
+		log.Printf("DEV_MODE is on: templates are re-parsed on every request")
+	}
 
-	// Path to the templates directory
-	templateDir := "templates/portal"
+	return &Portal{
+		templates:  templates,
+		templateFS: fsys,
+		config:     cfg,
+		api:        apiclient.New(cfg.APIInternalURL),
+	}, nil
+}
+
+// devTemplateDir is where dev mode reads templates when DEV_TEMPLATE_DIR is unset
+const devTemplateDir = "templates/portal"
+
+// templateSource returns where page templates are read from: DEV_TEMPLATE_DIR
+// when set, the working copy in dev mode, otherwise the templates embedded in
+// the binary
+func templateSource(cfg *config.Config) fs.FS {
+	dir := cfg.DevTemplateDir
+	if dir == "" && cfg.DevMode {
+		dir = devTemplateDir
+	}
+	if dir != "" {
+		log.Printf("Loading templates from %s", dir)
+		return os.DirFS(dir)
+	}
+	return medisynth.Templates()
+}
+
+// loadTemplates parses every page in fsys together with base.html
+func loadTemplates(fsys fs.FS) (map[string]*template.Template, error) {
+	templates := make(map[string]*template.Template)
 
 	// Find all the page templates
-	pages, err := fs.Glob(os.DirFS(templateDir), "*.html")
+	pages, err := fs.Glob(fsys, "*.html")
 	if err != nil {
 		log.Printf("Error finding templates: %v", err)
 		return nil, err
@@ -40,31 +80,34 @@ func New(cfg *config.Config) (*Portal, error) {
 			continue
 		}
 
-		ts, err := template.ParseFiles(
-			filepath.Join(templateDir, "base.html"),
-			filepath.Join(templateDir, page),
-		)
+		ts, err := parsePage(fsys, page)
 		if err != nil {
 			log.Printf("Error parsing template %s: %v", page, err)
 			return nil, err
 		}
 		templates[page] = ts
 	}
+	return templates, nil
+}
 
-	log.Printf("Successfully loaded templates")
+// formatDuration renders a job duration to the second, e.g. "2m5s"
+func formatDuration(d time.Duration) string {
+	return max(d.Round(time.Second), time.Second).String()
+}
 
-	return &Portal{
-		templates: templates,
-		config:    cfg,
-	}, nil
+// parsePage parses a single page template together with base.html
+func parsePage(fsys fs.FS, page string) (*template.Template, error) {
+	return template.New("base.html").Funcs(templateFuncs).ParseFS(fsys, "base.html", page)
 }
 
 func (p *Portal) Routes() http.Handler {
 	r := chi.NewRouter()
+	r.Use(p.securityHeaders)
+	r.Use(compress.Gzip(compress.DefaultMinSize))
 
 	// Static files
-	log.Printf("Setting up static file server for directory: static")
-	fileServer := http.FileServer(http.Dir("static"))
+	staticFS := medisynth.Static()
+	fileServer := http.FileServer(http.FS(staticFS))
 	r.Handle("/static/*", http.StripPrefix("/static/", fileServer))
 
 	// Public routes
@@ -88,7 +131,7 @@ func (p *Portal) Routes() http.Handler {
 
 	// Favicon
 	r.Get("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "static/favicon.ico")
+		http.ServeFileFS(w, r, staticFS, "favicon.ico")
 	})
 
 	// Logout route
@@ -104,6 +147,7 @@ func (p *Portal) Routes() http.Handler {
 	// Protected routes
 	r.Group(func(r chi.Router) {
 		r.Use(p.requireAuth)
+		r.Use(p.csrfProtect)
 
 		r.Get("/dashboard", p.handleDashboard)
 		r.Get("/documentation", p.handleDocumentation)
@@ -111,20 +155,33 @@ func (p *Portal) Routes() http.Handler {
 		r.Get("/jobs", p.handleJobs)
 		r.Get("/jobs/new", p.handleNewJob)
 		r.Post("/jobs/new", p.handleCreateJob)
+		r.Post("/jobs/validate", p.handleValidateJob)
+		r.Post("/jobs/{id}/delete", p.handleDeleteJob)
 
 		// Token management routes
 		r.Route("/tokens", func(r chi.Router) {
 			r.Get("/", p.handleTokens)
 			r.Post("/create", p.handleCreateToken)
 			r.Post("/{id}/delete", p.handleDeleteToken)
+			r.Post("/revoke-all", p.handleRevokeAllTokens)
+		})
+
+		// Session management routes
+		r.Route("/account/sessions", func(r chi.Router) {
+			r.Get("/", p.handleSessions)
+			r.Post("/revoke-others", p.handleRevokeOtherSessions)
+			r.Post("/{id}/revoke", p.handleRevokeSession)
 		})
 	})
 
 	// NotFound handler
 	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
-		p.renderTemplate(w, r, "404.html", "Not Found", map[string]interface{}{
+		data := map[string]interface{}{
 			"Path": r.URL.Path,
-		})
+		}
+		if err := p.renderTemplate(w, r, "404.html", "Not Found", data); err != nil {
+			serverError(w, r, err)
+		}
 	})
 
 	// Walk and log all registered routes
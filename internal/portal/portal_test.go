@@ -0,0 +1,128 @@
+package portal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The package directory has no templates or static files, so these tests only
+// pass if New and Routes read from the embedded copies
+func TestNewLoadsEmbeddedTemplates(t *testing.T) {
+	_, err := os.Stat("templates")
+	require.True(t, os.IsNotExist(err), "tests must not run next to an on-disk templates directory")
+
+	p, err := New(&config.Config{})
+	require.NoError(t, err)
+	for _, page := range []string{"404.html", "dashboard.html", "jobs.html", "login.html", "new-job.html", "sessions.html"} {
+		assert.Contains(t, p.templates, page)
+	}
+	assert.NotContains(t, p.templates, "base.html", "base is only parsed alongside a page")
+
+	routes := p.Routes()
+	for _, path := range []string{"/static/css/custom.css", "/favicon.ico"} {
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+		assert.NotZero(t, rec.Body.Len(), path)
+	}
+}
+
+func TestNewLoadsDevTemplateDir(t *testing.T) {
+	dir := t.TempDir()
+	base := `<main>{{template "content" .}}</main>`
+	page := `{{define "content"}}dev copy{{end}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "base.html"), []byte(base), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "only-in-dev.html"), []byte(page), 0o644))
+
+	p, err := New(&config.Config{DevTemplateDir: dir})
+	require.NoError(t, err)
+	assert.Len(t, p.templates, 1)
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, p.renderTemplate(rec, httptest.NewRequest(http.MethodGet, "/", nil), "only-in-dev.html", "Dev", nil))
+	assert.Equal(t, "<main>dev copy</main>", rec.Body.String())
+}
+
+func TestRenderTemplate(t *testing.T) {
+	p := newTestPortal(t, &config.Config{})
+	req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, p.renderTemplate(rec, req, "404.html", "Not Found", map[string]interface{}{"Path": "/missing"}))
+	body := rec.Body.String()
+	assert.Contains(t, body, "<title>Not Found - MediSynth</title>")
+	assert.Contains(t, body, "Page not found")
+	assert.Contains(t, body, "Return to Home", "anonymous visitors get the public link")
+
+	rec = httptest.NewRecorder()
+	err := p.renderTemplate(rec, req, "no-such-page.html", "Nope", nil)
+	assert.ErrorContains(t, err, "no-such-page.html")
+	assert.Zero(t, rec.Body.Len(), "nothing is written when the template is missing")
+}
+
+func TestRenderTemplateWritesNothingOnError(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "base.html"), []byte(`<main>{{template "content" .}}</main>`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.html"), []byte(`{{define "content"}}{{index .Missing 3}}{{end}}`), 0o644))
+	p, err := New(&config.Config{DevTemplateDir: dir})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	err = p.renderTemplate(rec, httptest.NewRequest(http.MethodGet, "/", nil), "broken.html", "Broken", nil)
+	assert.ErrorContains(t, err, "broken.html")
+	assert.Zero(t, rec.Body.Len(), "a half-rendered page must not reach the client")
+}
+
+func TestRenderTemplateStatus(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "base.html"), []byte(`<main>{{template "content" .}}</main>`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "form.html"), []byte(`{{define "content"}}{{.Error}}{{end}}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.html"), []byte(`{{define "content"}}{{index .Missing 3}}{{end}}`), 0o644))
+	p, err := New(&config.Config{DevTemplateDir: dir})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, p.renderTemplateStatus(rec, req, http.StatusBadRequest, "form.html", "Form", map[string]interface{}{"Error": "bad input"}))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "<main>bad input</main>", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	err = p.renderTemplateStatus(rec, req, http.StatusBadRequest, "broken.html", "Broken", nil)
+	assert.ErrorContains(t, err, "broken.html")
+	serverError(rec, req, err)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code, "the status is not sent before the page has rendered")
+}
+
+func TestDevModeReparsesTemplates(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	write("base.html", `<main>{{template "content" .}}</main>`)
+	write("page.html", `{{define "content"}}first{{end}}`)
+
+	render := func(p *Portal) string {
+		rec := httptest.NewRecorder()
+		require.NoError(t, p.renderTemplate(rec, httptest.NewRequest(http.MethodGet, "/", nil), "page.html", "Page", nil))
+		return rec.Body.String()
+	}
+
+	dev, err := New(&config.Config{DevTemplateDir: dir, DevMode: true})
+	require.NoError(t, err)
+	cached, err := New(&config.Config{DevTemplateDir: dir})
+	require.NoError(t, err)
+	assert.Equal(t, "<main>first</main>", render(dev))
+	assert.Equal(t, "<main>first</main>", render(cached))
+
+	write("page.html", `{{define "content"}}second{{end}}`)
+	assert.Equal(t, "<main>second</main>", render(dev), "dev mode picks up the edit")
+	assert.Equal(t, "<main>first</main>", render(cached), "without dev mode the parsed copy is kept")
+}
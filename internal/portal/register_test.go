@@ -0,0 +1,34 @@
+package portal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterShowsUnmetPasswordRequirements(t *testing.T) {
+	p := newTestPortal(t, &config.Config{})
+
+	form := url.Values{
+		"email":            {"weak-password@example.com"},
+		"password":         {"password"},
+		"confirm_password": {"password"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+
+	p.handleRegisterPost(rec, req)
+
+	body := rec.Body.String()
+	assert.Contains(t, body, "Password does not meet the requirements")
+	for _, problem := range []string{"needs an uppercase letter", "needs a number", "needs a symbol"} {
+		assert.Contains(t, body, "<li>"+problem+"</li>")
+	}
+	assert.NotContains(t, body, "<li>needs a lowercase letter</li>")
+}
@@ -0,0 +1,39 @@
+package portal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	get := func(cfg *config.Config) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		newTestPortal(t, cfg).Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Contains(t, rec.Body.String(), "<html")
+		return rec
+	}
+
+	rec := get(&config.Config{DomainPortal: "portal.medisynth.io", DomainSecure: true})
+	h := rec.Header()
+	csp := h.Get("Content-Security-Policy")
+	assert.Contains(t, csp, "default-src 'self'")
+	assert.Contains(t, csp, "style-src 'self' 'unsafe-inline' https://cdnjs.cloudflare.com")
+	assert.Contains(t, csp, "form-action 'self' https://portal.medisynth.io")
+	assert.Contains(t, csp, "frame-ancestors 'none'")
+	assert.Equal(t, "nosniff", h.Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", h.Get("X-Frame-Options"))
+	assert.Equal(t, "strict-origin-when-cross-origin", h.Get("Referrer-Policy"))
+	assert.Equal(t, "max-age=31536000; includeSubDomains", h.Get("Strict-Transport-Security"))
+
+	rec = get(&config.Config{CSPAllowedSources: "https://cdn.example.com"})
+	csp = rec.Header().Get("Content-Security-Policy")
+	assert.Contains(t, csp, "style-src 'self' 'unsafe-inline' https://cdn.example.com")
+	assert.NotContains(t, csp, "cdnjs.cloudflare.com", "a configured allowlist replaces the defaults")
+	assert.Empty(t, rec.Header().Get("Strict-Transport-Security"), "HSTS is only sent when DOMAIN_SECURE is set")
+}
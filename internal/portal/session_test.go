@@ -0,0 +1,92 @@
+package portal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/auth"
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "medisynth-portal-test")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create temp dir: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := database.Init(&config.Config{DatabaseType: "sqlite", DatabasePath: filepath.Join(dir, "test.db")}); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to initialize test database: %v\n", err)
+		os.Exit(1)
+	}
+	auth.SetStore(store.New())
+	auth.SetPasswordCost(bcrypt.MinCost)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// login posts the login form and returns the session cookie that was set
+func login(t *testing.T, p *Portal, email string, rememberMe bool) *http.Cookie {
+	form := url.Values{"email": {email}, "password": {"Password1!"}}
+	if rememberMe {
+		form.Set("remember-me", "on")
+	}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+
+	p.handleLoginPost(rec, req)
+	require.Equal(t, http.StatusSeeOther, rec.Code)
+
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == "session" {
+			return cookie
+		}
+	}
+	t.Fatal("no session cookie set")
+	return nil
+}
+
+func TestLoginUsesConfiguredSessionDuration(t *testing.T) {
+	email := fmt.Sprintf("duration-%d@example.com", time.Now().UnixNano())
+	_, err := auth.RegisterUser(email, "Password1!")
+	require.NoError(t, err)
+
+	p := &Portal{config: &config.Config{SessionDurationHours: 2, RememberMeDurationHours: 48}}
+
+	tests := []struct {
+		name       string
+		rememberMe bool
+		want       time.Duration
+	}{
+		{name: "default", rememberMe: false, want: 2 * time.Hour},
+		{name: "remember me", rememberMe: true, want: 48 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now()
+			cookie := login(t, p, email, tt.rememberMe)
+
+			session, err := database.GetSessionByToken(cookie.Value)
+			require.NoError(t, err)
+
+			assert.WithinDuration(t, before.Add(tt.want), session.ExpiresAt, 5*time.Second)
+			// Cookie expiry is sent with second precision
+			assert.WithinDuration(t, session.ExpiresAt, cookie.Expires, time.Second)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package portal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/auth"
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeAllTokens(t *testing.T) {
+	p := newTestPortal(t, &config.Config{})
+	routes := p.Routes()
+
+	email := fmt.Sprintf("revoke-tokens-%d@example.com", time.Now().UnixNano())
+	user, err := auth.RegisterUser(email, "Password1!")
+	require.NoError(t, err)
+	other, err := auth.RegisterUser("other-"+email, "Password1!")
+	require.NoError(t, err)
+	for _, name := range []string{"ci", "laptop"} {
+		_, err := auth.CreateToken(user.ID, name)
+		require.NoError(t, err)
+	}
+	_, err = auth.CreateToken(other.ID, "foreign")
+	require.NoError(t, err)
+	session := login(t, p, email, false)
+
+	get := func(target string) string {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.AddCookie(session)
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+
+	page := get("/tokens")
+	assert.Contains(t, page, "laptop", "the page lists the user's tokens")
+	assert.Contains(t, page, `action="/tokens/revoke-all"`)
+
+	form := url.Values{csrfFieldName: {csrfToken(session.Value)}}
+	req := httptest.NewRequest(http.MethodPost, "/tokens/revoke-all", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(session)
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/tokens?revoked=2", rec.Header().Get("Location"))
+
+	remaining, err := auth.ListTokens(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+	otherRemaining, err := auth.ListTokens(other.ID)
+	require.NoError(t, err)
+	assert.Len(t, otherRemaining, 1, "only the signed-in user's tokens are revoked")
+
+	page = get("/tokens?revoked=2")
+	assert.Contains(t, page, "Revoked 2 token(s).")
+	assert.NotContains(t, page, `action="/tokens/revoke-all"`, "there is nothing left to revoke")
+}
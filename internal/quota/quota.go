@@ -0,0 +1,68 @@
+// Package quota implements the monthly limit on generated patients.
+package quota
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/MediSynth-io/medisynth/internal/models"
+)
+
+// PeriodStart returns the start of the quota period containing t: the first
+// of its calendar month, in UTC
+func PeriodStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Usage is a user's generated patients in the current quota period
+type Usage struct {
+	Limit    int
+	Used     int
+	Reserved int // Requested by jobs that have not finished yet
+	ResetsAt time.Time
+}
+
+// Remaining returns how many more patients may be requested this period
+func (u *Usage) Remaining() int {
+	return max(u.Limit-u.Used-u.Reserved, 0)
+}
+
+// Allows reports whether a job generating population more patients stays
+// within the quota
+func (u *Usage) Allows(population int) bool {
+	return u.Used+u.Reserved+population <= u.Limit
+}
+
+// ForUser returns the user's usage recorded in db in the period containing
+// now, or nil if no quota applies to them: MONTHLY_PATIENT_QUOTA is 0, they
+// are on a paid tier or their email is in QUOTA_EXEMPT_EMAILS. Patients count
+// as used once their job has completed, and as reserved while it is pending
+// or running.
+func ForUser(ctx context.Context, db *database.DB, cfg *config.Config, userID string, now time.Time) (*Usage, error) {
+	if cfg.MonthlyPatientQuota <= 0 {
+		return nil, nil
+	}
+	user, err := db.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Tier != models.TierFree || slices.Contains(cfg.QuotaExemptEmailList(), strings.ToLower(user.Email)) {
+		return nil, nil
+	}
+
+	start := PeriodStart(now)
+	used, err := db.GetUserPatientCountSince(ctx, userID, start)
+	if err != nil {
+		return nil, err
+	}
+	reserved, err := db.GetUserReservedPatientCount(ctx, userID, start)
+	if err != nil {
+		return nil, err
+	}
+	return &Usage{Limit: cfg.MonthlyPatientQuota, Used: used, Reserved: reserved, ResetsAt: start.AddDate(0, 1, 0)}, nil
+}
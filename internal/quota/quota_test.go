@@ -0,0 +1,31 @@
+package quota
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeriodStart(t *testing.T) {
+	est := time.FixedZone("EST", -5*60*60)
+	assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), PeriodStart(time.Date(2025, 3, 17, 12, 0, 0, 0, time.UTC)))
+	// 8pm on February 28th in New York is already March in UTC
+	assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), PeriodStart(time.Date(2025, 2, 28, 20, 0, 0, 0, est)))
+}
+
+func TestUsage(t *testing.T) {
+	usage := &Usage{Limit: 100, Used: 60}
+	assert.Equal(t, 40, usage.Remaining())
+	assert.True(t, usage.Allows(40), "a job may use the quota exactly")
+	assert.False(t, usage.Allows(41))
+
+	reserved := &Usage{Limit: 100, Used: 60, Reserved: 30}
+	assert.Equal(t, 10, reserved.Remaining())
+	assert.True(t, reserved.Allows(10))
+	assert.False(t, reserved.Allows(11), "unfinished jobs count against the quota")
+
+	over := &Usage{Limit: 100, Used: 120}
+	assert.Zero(t, over.Remaining())
+	assert.False(t, over.Allows(1))
+}
@@ -3,12 +3,8 @@ package s3
 import (
 	"context"
 	"log"
-	"path/filepath"
-	"strings"
-	"time"
 
 	"github.com/MediSynth-io/medisynth/internal/config"
-	"github.com/MediSynth-io/medisynth/internal/models"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
@@ -49,64 +45,3 @@ func NewClient(cfg *config.Config) (*Client, error) {
 		BucketName: cfg.S3Bucket,
 	}, nil
 }
-
-func (c *Client) ListFiles(ctx context.Context, prefix string) ([]models.JobFile, error) {
-	output, err := c.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
-		Bucket: &c.BucketName,
-		Prefix: &prefix,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	presignClient := s3.NewPresignClient(c.Client)
-	var files []models.JobFile
-
-	for _, object := range output.Contents {
-		req, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
-			Bucket: &c.BucketName,
-			Key:    object.Key,
-		}, func(opts *s3.PresignOptions) {
-			opts.Expires = 24 * time.Hour
-		})
-		if err != nil {
-			log.Printf("Failed to generate presigned URL for key %s: %v", *object.Key, err)
-			continue // Or handle error differently
-		}
-
-		var size int64
-		if object.Size != nil {
-			size = *object.Size
-		}
-
-		files = append(files, models.JobFile{
-			S3Key:    *object.Key,
-			Filename: extractFilename(*object.Key),
-			Size:     size,
-			URL:      req.URL,
-		})
-	}
-
-	return files, nil
-}
-
-func extractFilename(s3Key string) string {
-	// Extract just the filename from the S3 key path
-	// For example: "synthea_output/job-123/fhir/Patient_123.json" -> "Patient_123.json"
-	filename := filepath.Base(s3Key)
-
-	// If it's still empty or just a path separator, use the last meaningful part
-	if filename == "." || filename == "/" || filename == "" {
-		parts := strings.Split(strings.Trim(s3Key, "/"), "/")
-		if len(parts) > 0 {
-			filename = parts[len(parts)-1]
-		}
-	}
-
-	// If we still don't have a good filename, use the full key
-	if filename == "" {
-		filename = s3Key
-	}
-
-	return filename
-}
@@ -0,0 +1,64 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ObjectDeleter lists and batch-deletes objects; *s3.Client implements it
+type ObjectDeleter interface {
+	s3.ListObjectsV2APIClient
+	DeleteObjects(ctx context.Context, input *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+}
+
+// DeleteAllObjects deletes every object under prefix and returns how many
+// were deleted. Each ListObjectsV2 page holds at most 1000 keys, the
+// DeleteObjects limit, so pages are deleted one batch at a time. The first
+// failure stops the deletion; objects already removed stay removed.
+func DeleteAllObjects(ctx context.Context, api ObjectDeleter, bucket, prefix string) (int, error) {
+	if prefix == "" {
+		return 0, errors.New("refusing to delete with an empty prefix")
+	}
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}
+	deleted := 0
+	for {
+		output, err := api.ListObjectsV2(ctx, input)
+		if err != nil {
+			return deleted, err
+		}
+
+		if len(output.Contents) > 0 {
+			ids := make([]types.ObjectIdentifier, len(output.Contents))
+			for i, object := range output.Contents {
+				ids[i] = types.ObjectIdentifier{Key: object.Key}
+			}
+			result, err := api.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+				Bucket: aws.String(bucket),
+				Delete: &types.Delete{Objects: ids, Quiet: aws.Bool(true)},
+			})
+			if err != nil {
+				return deleted, err
+			}
+			if len(result.Errors) > 0 {
+				first := result.Errors[0]
+				return deleted + len(ids) - len(result.Errors), fmt.Errorf("failed to delete %d objects under %s, first %s: %s",
+					len(result.Errors), prefix, aws.ToString(first.Key), aws.ToString(first.Message))
+			}
+			deleted += len(ids)
+		}
+
+		if !aws.ToBool(output.IsTruncated) || aws.ToString(output.NextContinuationToken) == "" {
+			return deleted, nil
+		}
+		input.ContinuationToken = output.NextContinuationToken
+	}
+}
@@ -0,0 +1,72 @@
+package s3
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedDeleter serves pagedLister's pages and records deleted keys
+type pagedDeleter struct {
+	pagedLister
+	deleted []string
+	failKey string
+}
+
+func (p *pagedDeleter) DeleteObjects(ctx context.Context, input *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	output := &s3.DeleteObjectsOutput{}
+	for _, id := range input.Delete.Objects {
+		if aws.ToString(id.Key) == p.failKey {
+			output.Errors = append(output.Errors, types.Error{Key: id.Key, Message: aws.String("AccessDenied")})
+			continue
+		}
+		p.deleted = append(p.deleted, aws.ToString(id.Key))
+	}
+	return output, nil
+}
+
+func twoPages() map[string]*s3.ListObjectsV2Output {
+	return map[string]*s3.ListObjectsV2Output{
+		"": {
+			Contents:              objects("job/a.json", "job/b.json"),
+			IsTruncated:           aws.Bool(true),
+			NextContinuationToken: aws.String("page-2"),
+		},
+		"page-2": {
+			Contents:    objects("job/c.json"),
+			IsTruncated: aws.Bool(false),
+		},
+	}
+}
+
+func TestDeleteAllObjectsDeletesEveryPage(t *testing.T) {
+	deleter := &pagedDeleter{pagedLister: pagedLister{pages: twoPages()}}
+
+	n, err := DeleteAllObjects(context.Background(), deleter, "bucket", "job/")
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []string{"job/a.json", "job/b.json", "job/c.json"}, deleter.deleted)
+}
+
+func TestDeleteAllObjectsReportsFailedKeys(t *testing.T) {
+	deleter := &pagedDeleter{pagedLister: pagedLister{pages: twoPages()}, failKey: "job/b.json"}
+
+	n, err := DeleteAllObjects(context.Background(), deleter, "bucket", "job/")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "job/b.json")
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 1, deleter.calls, "deletion stops at the failing page")
+}
+
+func TestDeleteAllObjectsRejectsEmptyPrefix(t *testing.T) {
+	deleter := &pagedDeleter{pagedLister: pagedLister{pages: twoPages()}}
+
+	_, err := DeleteAllObjects(context.Background(), deleter, "bucket", "")
+	assert.Error(t, err)
+	assert.Zero(t, deleter.calls, "nothing is listed, let alone deleted")
+}
@@ -0,0 +1,42 @@
+package s3
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// maxListedObjects caps how many keys ListAllObjects collects so a runaway
+// prefix cannot exhaust memory
+const maxListedObjects = 100000
+
+// ListAllObjects returns every object under prefix, following continuation
+// tokens across ListObjectsV2 pages. Listing stops early with a warning once
+// maxListedObjects keys have been collected.
+func ListAllObjects(ctx context.Context, api s3.ListObjectsV2APIClient, bucket, prefix string) ([]types.Object, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}
+
+	var objects []types.Object
+	for {
+		output, err := api.ListObjectsV2(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, output.Contents...)
+
+		if len(objects) >= maxListedObjects {
+			log.Printf("WARNING: Listing of s3://%s/%s stopped at %d objects", bucket, prefix, maxListedObjects)
+			return objects[:maxListedObjects], nil
+		}
+		if !aws.ToBool(output.IsTruncated) || aws.ToString(output.NextContinuationToken) == "" {
+			return objects, nil
+		}
+		input.ContinuationToken = output.NextContinuationToken
+	}
+}
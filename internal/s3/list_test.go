@@ -0,0 +1,78 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedLister serves ListObjectsV2 pages keyed by continuation token
+type pagedLister struct {
+	pages map[string]*s3.ListObjectsV2Output
+	calls int
+}
+
+func (p *pagedLister) ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	p.calls++
+	page, ok := p.pages[aws.ToString(input.ContinuationToken)]
+	if !ok {
+		return nil, errors.New("unexpected continuation token")
+	}
+	return page, nil
+}
+
+func objects(keys ...string) []types.Object {
+	var out []types.Object
+	for _, key := range keys {
+		out = append(out, types.Object{Key: aws.String(key)})
+	}
+	return out
+}
+
+func TestListAllObjectsFollowsContinuationTokens(t *testing.T) {
+	lister := &pagedLister{pages: map[string]*s3.ListObjectsV2Output{
+		"": {
+			Contents:              objects("job/a.json", "job/b.json"),
+			IsTruncated:           aws.Bool(true),
+			NextContinuationToken: aws.String("page-2"),
+		},
+		"page-2": {
+			Contents:              objects("job/c.json"),
+			IsTruncated:           aws.Bool(true),
+			NextContinuationToken: aws.String("page-3"),
+		},
+		"page-3": {
+			Contents:    objects("job/d.json"),
+			IsTruncated: aws.Bool(false),
+		},
+	}}
+
+	got, err := ListAllObjects(context.Background(), lister, "bucket", "job/")
+	require.NoError(t, err)
+
+	var keys []string
+	for _, object := range got {
+		keys = append(keys, aws.ToString(object.Key))
+	}
+	assert.Equal(t, []string{"job/a.json", "job/b.json", "job/c.json", "job/d.json"}, keys)
+	assert.Equal(t, 3, lister.calls)
+}
+
+func TestListAllObjectsReturnsPageErrors(t *testing.T) {
+	lister := &pagedLister{pages: map[string]*s3.ListObjectsV2Output{
+		"": {
+			Contents:              objects("job/a.json"),
+			IsTruncated:           aws.Bool(true),
+			NextContinuationToken: aws.String("missing"),
+		},
+	}}
+
+	_, err := ListAllObjects(context.Background(), lister, "bucket", "job/")
+	assert.Error(t, err)
+}
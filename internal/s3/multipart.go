@@ -0,0 +1,163 @@
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// MultipartUploader is the part of the S3 API used by UploadMultipart;
+// *s3.Client implements it
+type MultipartUploader interface {
+	CreateMultipartUpload(ctx context.Context, input *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, input *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, input *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, input *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
+// MinPartSize is the smallest part S3 accepts, other than the last one
+const MinPartSize = 5 << 20
+
+// maxParts is the most parts a single multipart upload may have
+const maxParts = 10000
+
+// abortTimeout bounds the cleanup of a failed upload, which runs even when
+// the upload's own context is already done
+const abortTimeout = time.Minute
+
+// UploadMultipart uploads size bytes read from r to the bucket and key of
+// input, which also carries any content type or metadata of the object, as a
+// multipart upload with up to concurrency parts in flight. Parts are partSize
+// bytes, raised to MinPartSize or as needed to stay within S3's part limit,
+// and each carries its MD5 so S3 rejects a corrupted part. If any part fails the upload is
+// aborted, so S3 does not keep the parts already stored.
+func UploadMultipart(ctx context.Context, api MultipartUploader, input *s3.CreateMultipartUploadInput, r io.ReaderAt, size, partSize int64, concurrency int) error {
+	partSize = max(partSize, MinPartSize, (size+maxParts-1)/maxParts)
+	concurrency = max(concurrency, 1)
+	bucket, key := aws.ToString(input.Bucket), aws.ToString(input.Key)
+
+	created, err := api.CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to start multipart upload of %s: %w", key, err)
+	}
+	uploadID := created.UploadId
+
+	parts, err := uploadParts(ctx, api, bucket, key, uploadID, r, size, partSize, concurrency)
+	if err == nil {
+		_, err = api.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(bucket),
+			Key:             aws.String(key),
+			UploadId:        uploadID,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+		})
+		if err == nil {
+			return nil
+		}
+		err = fmt.Errorf("failed to complete multipart upload of %s: %w", key, err)
+	}
+
+	abortCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abortTimeout)
+	defer cancel()
+	if _, abortErr := api.AbortMultipartUpload(abortCtx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: uploadID,
+	}); abortErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to abort multipart upload of %s: %w", key, abortErr))
+	}
+	return err
+}
+
+// uploadParts uploads every part of r and returns them in order for
+// CompleteMultipartUpload. The first failure stops the remaining parts.
+func uploadParts(ctx context.Context, api MultipartUploader, bucket, key string, uploadID *string, r io.ReaderAt, size, partSize int64, concurrency int) ([]types.CompletedPart, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	count := max(int((size+partSize-1)/partSize), 1)
+	parts := make([]types.CompletedPart, count)
+	slots := make(chan struct{}, concurrency)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			offset := int64(i) * partSize
+			part := io.NewSectionReader(r, offset, min(partSize, size-offset))
+			number := int32(i + 1)
+			etag, err := uploadPart(ctx, api, bucket, key, uploadID, number, part)
+			if err != nil {
+				fail(fmt.Errorf("failed to upload part %d of %s: %w", number, key, err))
+				return
+			}
+			parts[i] = types.CompletedPart{ETag: etag, PartNumber: aws.Int32(number)}
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// uploadPart uploads one part with its MD5 and returns the part's ETag
+func uploadPart(ctx context.Context, api MultipartUploader, bucket, key string, uploadID *string, number int32, part *io.SectionReader) (*string, error) {
+	hash := md5.New()
+	if _, err := io.Copy(hash, part); err != nil {
+		return nil, err
+	}
+	if _, err := part.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	output, err := api.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(key),
+		UploadId:      uploadID,
+		PartNumber:    aws.Int32(number),
+		Body:          part,
+		ContentLength: aws.Int64(part.Size()),
+		ContentMD5:    aws.String(base64.StdEncoding.EncodeToString(hash.Sum(nil))),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.ETag, nil
+}
@@ -0,0 +1,139 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMultipart stores uploaded parts in memory and fails the part numbered failPart
+type fakeMultipart struct {
+	mu        sync.Mutex
+	parts     map[int32][]byte
+	inFlight  int
+	maxFlight int
+	failPart  int32
+	completed []byte
+	aborted   bool
+	metadata  map[string]string
+}
+
+func newFakeMultipart() *fakeMultipart {
+	return &fakeMultipart{parts: map[int32][]byte{}}
+}
+
+func (f *fakeMultipart) CreateMultipartUpload(ctx context.Context, input *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	f.metadata = input.Metadata
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+}
+
+func (f *fakeMultipart) UploadPart(ctx context.Context, input *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	f.mu.Lock()
+	f.inFlight++
+	f.maxFlight = max(f.maxFlight, f.inFlight)
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.inFlight--
+		f.mu.Unlock()
+	}()
+
+	number := aws.ToInt32(input.PartNumber)
+	if number == f.failPart {
+		return nil, errors.New("connection reset")
+	}
+	body, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) != aws.ToInt64(input.ContentLength) {
+		return nil, fmt.Errorf("part %d has %d bytes, declared %d", number, len(body), aws.ToInt64(input.ContentLength))
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.parts[number] = body
+	return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf(`"etag-%d"`, number))}, nil
+}
+
+func (f *fakeMultipart) CompleteMultipartUpload(ctx context.Context, input *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, part := range input.MultipartUpload.Parts {
+		number := aws.ToInt32(part.PartNumber)
+		if number != int32(i+1) || aws.ToString(part.ETag) != fmt.Sprintf(`"etag-%d"`, number) {
+			return nil, fmt.Errorf("part %d listed out of order", number)
+		}
+		f.completed = append(f.completed, f.parts[number]...)
+	}
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (f *fakeMultipart) AbortMultipartUpload(ctx context.Context, input *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.aborted = true
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+// bulkUpload is the CreateMultipartUpload input the tests upload with
+func bulkUpload() *s3.CreateMultipartUploadInput {
+	return &s3.CreateMultipartUploadInput{Bucket: aws.String("bucket"), Key: aws.String("job/bulk.ndjson")}
+}
+
+// syntheticFile returns size bytes that differ from part to part
+func syntheticFile(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i / 1024)
+	}
+	return data
+}
+
+func TestUploadMultipart(t *testing.T) {
+	data := syntheticFile(2*MinPartSize + 1234)
+	fake := newFakeMultipart()
+
+	input := bulkUpload()
+	input.Metadata = map[string]string{"job-id": "job-1"}
+
+	err := UploadMultipart(context.Background(), fake, input, bytes.NewReader(data), int64(len(data)), MinPartSize, 2)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"job-id": "job-1"}, fake.metadata, "the object keeps its metadata")
+	assert.Len(t, fake.parts, 3)
+	assert.Len(t, fake.parts[3], 1234, "the last part holds the remainder")
+	assert.True(t, bytes.Equal(data, fake.completed), "the parts reassemble into the file")
+	assert.LessOrEqual(t, fake.maxFlight, 2)
+	assert.False(t, fake.aborted)
+}
+
+func TestUploadMultipartRaisesSmallPartSize(t *testing.T) {
+	data := syntheticFile(MinPartSize + 1)
+	fake := newFakeMultipart()
+
+	err := UploadMultipart(context.Background(), fake, bulkUpload(), bytes.NewReader(data), int64(len(data)), 1024, 4)
+	require.NoError(t, err)
+	assert.Len(t, fake.parts, 2, "parts are never smaller than S3's minimum")
+}
+
+func TestUploadMultipartAbortsOnFailure(t *testing.T) {
+	data := syntheticFile(3 * MinPartSize)
+	fake := newFakeMultipart()
+	fake.failPart = 2
+
+	err := UploadMultipart(context.Background(), fake, bulkUpload(), bytes.NewReader(data), int64(len(data)), MinPartSize, 1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "part 2")
+	assert.True(t, fake.aborted, "the stored parts are cleaned up")
+	assert.Nil(t, fake.completed)
+	assert.NotContains(t, fake.parts, int32(3), "no parts are sent after a failure")
+}
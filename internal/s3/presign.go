@@ -0,0 +1,58 @@
+package s3
+
+import (
+	"context"
+	"mime"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// contentTypes maps the extensions of generated files to their MIME types
+var contentTypes = map[string]string{
+	".json": "application/json",
+	".xml":  "application/xml",
+	".csv":  "text/csv",
+	".zip":  "application/zip",
+	".gz":   "application/gzip",
+	".tgz":  "application/gzip",
+}
+
+// ContentType returns the MIME type of filename based on its extension, or
+// "" if the extension is not known
+func ContentType(filename string) string {
+	return contentTypes[strings.ToLower(filepath.Ext(filename))]
+}
+
+// AttachmentDisposition returns a Content-Disposition value that makes
+// browsers save the response as filename instead of displaying it
+func AttachmentDisposition(filename string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+}
+
+// PresignGetObject returns a presigned GET URL for key that is valid for
+// expires. A non-empty contentDisposition or contentType replaces the
+// corresponding header S3 sends with the object.
+func PresignGetObject(ctx context.Context, client *s3.Client, bucket, key string, expires time.Duration, contentDisposition, contentType string) (string, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if contentDisposition != "" {
+		input.ResponseContentDisposition = aws.String(contentDisposition)
+	}
+	if contentType != "" {
+		input.ResponseContentType = aws.String(contentType)
+	}
+
+	req, err := s3.NewPresignClient(client).PresignGetObject(ctx, input, func(opts *s3.PresignOptions) {
+		opts.Expires = expires
+	})
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
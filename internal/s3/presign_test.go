@@ -0,0 +1,53 @@
+package s3
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// offlineClient signs requests without ever contacting an endpoint
+func offlineClient() *s3.Client {
+	return s3.New(s3.Options{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		BaseEndpoint: aws.String("https://s3.example.com"),
+		UsePathStyle: true,
+	})
+}
+
+func TestPresignGetObjectForcesDownload(t *testing.T) {
+	filename := "Patient_1.json"
+	raw, err := PresignGetObject(context.Background(), offlineClient(), "bucket", "synthea_output/job-1/fhir/"+filename,
+		time.Hour, AttachmentDisposition(filename), ContentType(filename))
+	require.NoError(t, err)
+
+	presigned, err := url.Parse(raw)
+	require.NoError(t, err)
+	query := presigned.Query()
+	assert.Equal(t, `attachment; filename=Patient_1.json`, query.Get("response-content-disposition"))
+	assert.Equal(t, "application/json", query.Get("response-content-type"))
+}
+
+func TestPresignGetObjectWithoutOverrides(t *testing.T) {
+	raw, err := PresignGetObject(context.Background(), offlineClient(), "bucket", "synthea_output/job-1/synthea.log", time.Hour, "", "")
+	require.NoError(t, err)
+
+	presigned, err := url.Parse(raw)
+	require.NoError(t, err)
+	assert.False(t, presigned.Query().Has("response-content-disposition"))
+	assert.False(t, presigned.Query().Has("response-content-type"))
+}
+
+func TestAttachmentDispositionQuotesFilenames(t *testing.T) {
+	assert.Equal(t, `attachment; filename="Jane Doe.json"`, AttachmentDisposition("Jane Doe.json"))
+	assert.Equal(t, "", ContentType("synthea.log"), "unknown extensions keep the stored type")
+	assert.Equal(t, "text/csv", ContentType("patients.CSV"))
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/MediSynth-io/medisynth/internal/models"
+	mss3 "github.com/MediSynth-io/medisynth/internal/s3"
+)
+
+// ErrNotFound is returned when no object is stored under a key
+var ErrNotFound = errors.New("object not found")
+
+// Object describes one stored object. Metadata is only filled in by Stat.
+type Object struct {
+	Key      string
+	Size     int64
+	Metadata map[string]string
+}
+
+// PutOptions describe an object being stored
+type PutOptions struct {
+	// ContentType is left to the backend when empty
+	ContentType string
+	// Metadata is stored with the object; S3 sends each entry as an
+	// x-amz-meta-<key> header. Keys should be lowercase.
+	Metadata map[string]string
+}
+
+// Metadata keys that attribute an object to the job that produced it
+const (
+	MetadataUserID = "user-id"
+	MetadataJobID  = "job-id"
+	MetadataFormat = "format"
+)
+
+// JobMetadata returns the metadata stored with a job's outputs, leaving out
+// empty values
+func JobMetadata(userID, jobID, format string) map[string]string {
+	metadata := map[string]string{}
+	for key, value := range map[string]string{MetadataUserID: userID, MetadataJobID: jobID, MetadataFormat: format} {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+	return metadata
+}
+
+// Backend stores job outputs under slash-separated keys. Implementations
+// are safe for concurrent use.
+type Backend interface {
+	// Put stores size bytes read from body under key, replacing any existing
+	// object and its metadata
+	Put(ctx context.Context, key string, body io.ReadSeeker, size int64, opts PutOptions) error
+	// Get opens the object stored under key, or returns ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Stat returns the object stored under key with its metadata, or ErrNotFound
+	Stat(ctx context.Context, key string) (Object, error)
+	// List returns every object whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// Delete removes every object whose key starts with prefix and returns
+	// how many were removed. An empty prefix is refused.
+	Delete(ctx context.Context, prefix string) (int, error)
+	// PresignGet returns a URL that downloads key as filename without
+	// further authentication until expires has passed
+	PresignGet(ctx context.Context, key string, expires time.Duration, filename string) (string, error)
+	// Ping reports whether the backend is reachable
+	Ping(ctx context.Context) error
+}
+
+// New returns the backend selected by cfg.StorageBackendName
+func New(cfg *config.Config) (Backend, error) {
+	switch name := cfg.StorageBackendName(); name {
+	case config.StorageFilesystem:
+		log.Printf("Storing job outputs on the local filesystem in %s", cfg.StorageDir)
+		return NewFilesystemBackend(cfg.StorageDir, cfg.APIURL+FilesystemURLPath)
+	case config.StorageS3:
+		client, err := mss3.NewClient(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create S3 client: %w", err)
+		}
+		return NewS3Backend(client, cfg.MultipartUploadThreshold(), cfg.MultipartUploadPartSize()), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", name)
+	}
+}
+
+// JobOutputPrefix returns the key prefix every output of the Synthea run
+// jobID is stored under. Uploads, listings and deletions all derive their
+// keys from it.
+func JobOutputPrefix(jobID string) string {
+	return "synthea_output/" + jobID + "/"
+}
+
+// JobLogFilename is the object name of the compressed Synthea log under the
+// job's output prefix. It is stored alongside the outputs but is not one.
+const JobLogFilename = "synthea.log"
+
+// JobLogKey returns the key of the Synthea log of the run jobID
+func JobLogKey(jobID string) string {
+	return JobOutputPrefix(jobID) + JobLogFilename
+}
+
+// downloadURLExpiry is how long the URLs returned by ListFiles stay valid
+const downloadURLExpiry = 24 * time.Hour
+
+// ListFiles returns the files stored under prefix with download URLs that
+// save each file under its own name. Files whose URL cannot be created are
+// left out.
+func ListFiles(ctx context.Context, b Backend, prefix string) ([]models.JobFile, error) {
+	objects, err := b.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []models.JobFile
+	for _, object := range objects {
+		filename := path.Base(object.Key)
+		url, err := b.PresignGet(ctx, object.Key, downloadURLExpiry, filename)
+		if err != nil {
+			log.Printf("Failed to generate download URL for key %s: %v", object.Key, err)
+			continue
+		}
+		files = append(files, models.JobFile{
+			S3Key:    object.Key,
+			Filename: filename,
+			Size:     object.Size,
+			URL:      url,
+		})
+	}
+	return files, nil
+}
+
+// ListJobFiles returns the output files of the Synthea run jobID, as
+// ListFiles, leaving out the job's log
+func ListJobFiles(ctx context.Context, b Backend, jobID string) ([]models.JobFile, error) {
+	files, err := ListFiles(ctx, b, JobOutputPrefix(jobID))
+	if err != nil {
+		return nil, err
+	}
+	logKey := JobLogKey(jobID)
+	return slices.DeleteFunc(files, func(file models.JobFile) bool {
+		return file.S3Key == logKey
+	}), nil
+}
@@ -0,0 +1,305 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	mss3 "github.com/MediSynth-io/medisynth/internal/s3"
+)
+
+var _ Backend = (*FilesystemBackend)(nil)
+
+// FilesystemURLPath is where the API serves the download URLs created by
+// FilesystemBackend.PresignGet
+const FilesystemURLPath = "/storage"
+
+// FilesystemBackend stores objects as files under a root directory, for
+// development and tests without Spaces credentials. An object's metadata is
+// kept next to it in a hidden JSON file; hidden files are never objects
+// themselves. Download URLs point at its ServeHTTP and are signed with a key
+// generated at startup, so they stop working when the process restarts.
+type FilesystemBackend struct {
+	root    string
+	baseURL string
+	key     []byte
+}
+
+// NewFilesystemBackend returns a backend storing objects under root, which
+// is created if missing. Download URLs start with baseURL.
+func NewFilesystemBackend(root, baseURL string) (*FilesystemBackend, error) {
+	if root == "" {
+		return nil, errors.New("filesystem storage needs a root directory")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return &FilesystemBackend{root: root, baseURL: strings.TrimSuffix(baseURL, "/"), key: key}, nil
+}
+
+// path returns the file key is stored in, refusing keys that would resolve
+// outside the root or name a hidden file
+func (b *FilesystemBackend) path(key string) (string, error) {
+	trimmed := strings.TrimSuffix(key, "/")
+	if key == "" || !fs.ValidPath(trimmed) || strings.HasPrefix(trimmed, ".") || strings.Contains(trimmed, "/.") {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(b.root, filepath.FromSlash(key)), nil
+}
+
+// metadataPath returns the file holding the metadata of the object stored in p
+func metadataPath(p string) string {
+	return filepath.Join(filepath.Dir(p), "."+filepath.Base(p)+".metadata.json")
+}
+
+// Put writes body to a temporary file and renames it into place, so readers
+// never see a partly written object
+func (b *FilesystemBackend) Put(ctx context.Context, key string, body io.ReadSeeker, size int64, opts PutOptions) error {
+	dest, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, io.LimitReader(body, size))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	if written != size {
+		return fmt.Errorf("failed to write %s: got %d bytes, expected %d", key, written, size)
+	}
+
+	if len(opts.Metadata) > 0 {
+		metadata, err := json.Marshal(opts.Metadata)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(metadataPath(dest), metadata, 0o644); err != nil {
+			return fmt.Errorf("failed to write metadata of %s: %w", key, err)
+		}
+	} else if err := os.Remove(metadataPath(dest)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+func (b *FilesystemBackend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (b *FilesystemBackend) Stat(ctx context.Context, key string) (Object, error) {
+	p, err := b.path(key)
+	if err != nil {
+		return Object{}, err
+	}
+	info, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+		return Object{}, ErrNotFound
+	}
+	if err != nil {
+		return Object{}, err
+	}
+
+	object := Object{Key: key, Size: info.Size()}
+	metadata, err := os.ReadFile(metadataPath(p))
+	if errors.Is(err, fs.ErrNotExist) {
+		return object, nil
+	}
+	if err != nil {
+		return Object{}, err
+	}
+	if err := json.Unmarshal(metadata, &object.Metadata); err != nil {
+		return Object{}, fmt.Errorf("failed to read metadata of %s: %w", key, err)
+	}
+	return object, nil
+}
+
+// List walks the deepest directory prefix names and returns the files whose
+// keys start with prefix, in key order
+func (b *FilesystemBackend) List(ctx context.Context, prefix string) ([]Object, error) {
+	start := b.root
+	if dir := path.Dir(prefix + "x"); dir != "." {
+		p, err := b.path(dir)
+		if err != nil {
+			return nil, err
+		}
+		start = p
+	}
+
+	var objects []Object
+	err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(b.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Key: key, Size: info.Size()})
+		return nil
+	})
+	return objects, err
+}
+
+// Delete removes the files under prefix, and the directory prefix names when
+// it ends in a slash
+func (b *FilesystemBackend) Delete(ctx context.Context, prefix string) (int, error) {
+	if prefix == "" {
+		return 0, errors.New("refusing to delete with an empty prefix")
+	}
+	objects, err := b.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, object := range objects {
+		p, err := b.path(object.Key)
+		if err != nil {
+			return deleted, err
+		}
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return deleted, err
+		}
+		if err := os.Remove(metadataPath(p)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return deleted, err
+		}
+		deleted++
+	}
+	if strings.HasSuffix(prefix, "/") {
+		p, err := b.path(prefix)
+		if err != nil {
+			return deleted, err
+		}
+		if err := os.RemoveAll(p); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// PresignGet returns a URL served by ServeHTTP, signed so that it only
+// downloads key as filename until it expires
+func (b *FilesystemBackend) PresignGet(ctx context.Context, key string, expires time.Duration, filename string) (string, error) {
+	if _, err := b.path(key); err != nil {
+		return "", err
+	}
+	expiresAt := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	query := url.Values{
+		"expires":   {expiresAt},
+		"filename":  {filename},
+		"signature": {b.sign(key, expiresAt, filename)},
+	}
+	return b.baseURL + "/" + (&url.URL{Path: key}).EscapedPath() + "?" + query.Encode(), nil
+}
+
+func (b *FilesystemBackend) sign(key, expiresAt, filename string) string {
+	mac := hmac.New(sha256.New, b.key)
+	fmt.Fprintf(mac, "%s\n%s\n%s", key, expiresAt, filename)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeHTTP serves the URLs created by PresignGet. It expects the request
+// path to be the object key, with FilesystemURLPath already stripped.
+func (b *FilesystemBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+	expiresAt, filename := query.Get("expires"), query.Get("filename")
+
+	expiry, err := strconv.ParseInt(expiresAt, 10, 64)
+	if err != nil || !hmac.Equal([]byte(query.Get("signature")), []byte(b.sign(key, expiresAt, filename))) {
+		http.Error(w, "Invalid download link", http.StatusForbidden)
+		return
+	}
+	if time.Now().Unix() > expiry {
+		http.Error(w, "Download link expired", http.StatusForbidden)
+		return
+	}
+
+	p, err := b.path(key)
+	if err != nil {
+		http.Error(w, "Invalid download link", http.StatusForbidden)
+		return
+	}
+	file, err := os.Open(p)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", mss3.AttachmentDisposition(filename))
+	if contentType := mss3.ContentType(filename); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	http.ServeContent(w, r, filename, info.ModTime(), file)
+}
+
+// Ping confirms the root directory is still there
+func (b *FilesystemBackend) Ping(ctx context.Context) error {
+	info, err := os.Stat(b.root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", b.root)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFilesystem(t *testing.T) *FilesystemBackend {
+	t.Helper()
+	b, err := NewFilesystemBackend(t.TempDir(), "http://api.test/storage/")
+	require.NoError(t, err)
+	return b
+}
+
+func put(t *testing.T, b Backend, key, content string) {
+	t.Helper()
+	require.NoError(t, b.Put(context.Background(), key, strings.NewReader(content), int64(len(content)), PutOptions{}))
+}
+
+func TestFilesystemBackendObjects(t *testing.T) {
+	ctx := context.Background()
+	b := newTestFilesystem(t)
+	put(t, b, "synthea_output/job-1/fhir/patient.json", "patient")
+	put(t, b, "synthea_output/job-1/synthea.log", "log")
+	put(t, b, "synthea_output/job-10/fhir/other.json", "other")
+
+	body, err := b.Get(ctx, "synthea_output/job-1/fhir/patient.json")
+	require.NoError(t, err)
+	content, err := io.ReadAll(body)
+	require.NoError(t, body.Close())
+	require.NoError(t, err)
+	assert.Equal(t, "patient", string(content))
+
+	object, err := b.Stat(ctx, "synthea_output/job-1/synthea.log")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), object.Size)
+
+	_, err = b.Get(ctx, "synthea_output/job-1/missing.json")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = b.Stat(ctx, "synthea_output/job-1/fhir")
+	assert.True(t, errors.Is(err, ErrNotFound), "directories are not objects")
+
+	objects, err := b.List(ctx, "synthea_output/job-1/")
+	require.NoError(t, err)
+	assert.Equal(t, []Object{
+		{Key: "synthea_output/job-1/fhir/patient.json", Size: 7},
+		{Key: "synthea_output/job-1/synthea.log", Size: 3},
+	}, objects)
+
+	objects, err = b.List(ctx, "synthea_output/job-1")
+	require.NoError(t, err)
+	assert.Len(t, objects, 3, "prefixes need not end at a directory")
+
+	deleted, err := b.Delete(ctx, "synthea_output/job-1/")
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	objects, err = b.List(ctx, "synthea_output/")
+	require.NoError(t, err)
+	assert.Equal(t, []Object{{Key: "synthea_output/job-10/fhir/other.json", Size: 5}}, objects)
+
+	_, err = b.Delete(ctx, "")
+	assert.Error(t, err)
+}
+
+func TestFilesystemBackendMetadata(t *testing.T) {
+	ctx := context.Background()
+	b := newTestFilesystem(t)
+	metadata := JobMetadata("user-1", "job-1", "fhir")
+	require.NoError(t, b.Put(ctx, "job-1/patient.json", strings.NewReader("{}"), 2, PutOptions{Metadata: metadata}))
+
+	object, err := b.Stat(ctx, "job-1/patient.json")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"user-id": "user-1", "job-id": "job-1", "format": "fhir"}, object.Metadata)
+
+	objects, err := b.List(ctx, "job-1/")
+	require.NoError(t, err)
+	assert.Len(t, objects, 1, "metadata files are not listed as objects")
+
+	put(t, b, "job-1/patient.json", "{}")
+	object, err = b.Stat(ctx, "job-1/patient.json")
+	require.NoError(t, err)
+	assert.Empty(t, object.Metadata, "replacing an object replaces its metadata")
+}
+
+func TestJobMetadataLeavesOutEmptyValues(t *testing.T) {
+	assert.Equal(t, map[string]string{"user-id": "user-1", "job-id": "job-1"}, JobMetadata("user-1", "job-1", ""))
+}
+
+func TestFilesystemBackendRejectsKeysOutsideRoot(t *testing.T) {
+	b := newTestFilesystem(t)
+	for _, key := range []string{"../escape.json", "/etc/passwd", "a//b", "job/.hidden.json", ""} {
+		err := b.Put(context.Background(), key, strings.NewReader("x"), 1, PutOptions{})
+		assert.Error(t, err, "key %q", key)
+	}
+}
+
+func TestFilesystemBackendPutChecksSize(t *testing.T) {
+	b := newTestFilesystem(t)
+	err := b.Put(context.Background(), "job/short.json", strings.NewReader("abc"), 10, PutOptions{})
+	assert.Error(t, err)
+	_, err = b.Stat(context.Background(), "job/short.json")
+	assert.True(t, errors.Is(err, ErrNotFound), "a short write leaves nothing behind")
+}
+
+func TestFilesystemBackendPresignGet(t *testing.T) {
+	b := newTestFilesystem(t)
+	put(t, b, "job/fhir/patient record.json", `{"resourceType":"Patient"}`)
+
+	get := func(link string) *httptest.ResponseRecorder {
+		t.Helper()
+		u, err := url.Parse(link)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(u.Path, FilesystemURLPath+"/"), "got %s", link)
+		req := httptest.NewRequest(http.MethodGet, u.RequestURI(), nil)
+		rec := httptest.NewRecorder()
+		http.StripPrefix(FilesystemURLPath, b).ServeHTTP(rec, req)
+		return rec
+	}
+
+	link, err := b.PresignGet(context.Background(), "job/fhir/patient record.json", time.Hour, "patient record.json")
+	require.NoError(t, err)
+	rec := get(link)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"resourceType":"Patient"}`, rec.Body.String())
+	assert.Equal(t, `attachment; filename="patient record.json"`, rec.Header().Get("Content-Disposition"))
+
+	rec = get(strings.Replace(link, "filename=patient", "filename=other", 1))
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the signature covers the filename")
+
+	expired, err := b.PresignGet(context.Background(), "job/fhir/patient record.json", -time.Minute, "patient record.json")
+	require.NoError(t, err)
+	rec = get(expired)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
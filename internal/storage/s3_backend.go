@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	mss3 "github.com/MediSynth-io/medisynth/internal/s3"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var _ Backend = (*S3Backend)(nil)
+
+// multipartConcurrency is how many parts of one large object are uploaded at once
+const multipartConcurrency = 4
+
+// S3Backend stores objects in an S3 bucket such as a DigitalOcean Space
+type S3Backend struct {
+	client             *mss3.Client
+	multipartThreshold int64
+	partSize           int64
+}
+
+// NewS3Backend returns a backend that stores objects in client's bucket.
+// Objects of at least multipartThreshold bytes are uploaded in parts of
+// partSize.
+func NewS3Backend(client *mss3.Client, multipartThreshold, partSize int64) *S3Backend {
+	return &S3Backend{client: client, multipartThreshold: multipartThreshold, partSize: partSize}
+}
+
+// Put uploads body with its MD5 so S3 rejects a corrupted upload. Bodies of
+// at least the multipart threshold that support ReadAt, such as files, are
+// uploaded in parts, each with its own MD5.
+func (b *S3Backend) Put(ctx context.Context, key string, body io.ReadSeeker, size int64, opts PutOptions) error {
+	if readerAt, ok := body.(io.ReaderAt); ok && size >= b.multipartThreshold {
+		log.Printf("Uploading s3://%s/%s in parts", b.client.BucketName, key)
+		return mss3.UploadMultipart(ctx, b.client.Client, &s3.CreateMultipartUploadInput{
+			Bucket:      aws.String(b.client.BucketName),
+			Key:         aws.String(key),
+			ContentType: optionalString(opts.ContentType),
+			Metadata:    opts.Metadata,
+		}, readerAt, size, b.partSize, multipartConcurrency)
+	}
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, body); err != nil {
+		return err
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	log.Printf("Uploading s3://%s/%s", b.client.BucketName, key)
+	_, err := b.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(b.client.BucketName),
+		Key:           aws.String(key),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+		ContentMD5:    aws.String(base64.StdEncoding.EncodeToString(hash.Sum(nil))),
+		ContentType:   optionalString(opts.ContentType),
+		Metadata:      opts.Metadata,
+	})
+	return err
+}
+
+// optionalString returns nil for an empty s so the SDK leaves the field out
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+func (b *S3Backend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	output, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.client.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return output.Body, nil
+}
+
+func (b *S3Backend) Stat(ctx context.Context, key string) (Object, error) {
+	output, err := b.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(b.client.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return Object{}, ErrNotFound
+		}
+		return Object{}, fmt.Errorf("failed to stat s3://%s/%s: %w", b.client.BucketName, key, err)
+	}
+	return Object{Key: key, Size: aws.ToInt64(output.ContentLength), Metadata: output.Metadata}, nil
+}
+
+func (b *S3Backend) List(ctx context.Context, prefix string) ([]Object, error) {
+	listed, err := mss3.ListAllObjects(ctx, b.client.Client, b.client.BucketName, prefix)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]Object, len(listed))
+	for i, object := range listed {
+		objects[i] = Object{Key: aws.ToString(object.Key), Size: aws.ToInt64(object.Size)}
+	}
+	return objects, nil
+}
+
+func (b *S3Backend) Delete(ctx context.Context, prefix string) (int, error) {
+	return mss3.DeleteAllObjects(ctx, b.client.Client, b.client.BucketName, prefix)
+}
+
+// PresignGet returns a presigned S3 URL that overrides the stored headers so
+// browsers save the file instead of displaying JSON and XML inline
+func (b *S3Backend) PresignGet(ctx context.Context, key string, expires time.Duration, filename string) (string, error) {
+	return mss3.PresignGetObject(ctx, b.client.Client, b.client.BucketName, key, expires,
+		mss3.AttachmentDisposition(filename), mss3.ContentType(filename))
+}
+
+// Ping confirms the bucket exists and is reachable with our credentials
+func (b *S3Backend) Ping(ctx context.Context) error {
+	if b.client == nil || b.client.Client == nil {
+		return errors.New("S3 client not initialized")
+	}
+	_, err := b.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(b.client.BucketName),
+	})
+	return err
+}
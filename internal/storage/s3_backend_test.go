@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	mss3 "github.com/MediSynth-io/medisynth/internal/s3"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listingS3 answers ListObjectsV2 for a fixed set of objects, pageSize keys
+// per page, and records the prefixes it was asked for
+type listingS3 struct {
+	objects  map[string]int64
+	pageSize int
+	prefixes []string
+}
+
+func (l *listingS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if r.Method != http.MethodGet || query.Get("list-type") != "2" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	prefix := query.Get("prefix")
+	l.prefixes = append(l.prefixes, prefix)
+
+	var keys []string
+	for key := range l.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	start, _ := strconv.Atoi(query.Get("continuation-token"))
+	end := min(start+l.pageSize, len(keys))
+
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprint(w, `<ListBucketResult>`)
+	for _, key := range keys[start:end] {
+		fmt.Fprintf(w, `<Contents><Key>%s</Key><Size>%d</Size></Contents>`, key, l.objects[key])
+	}
+	if end < len(keys) {
+		fmt.Fprintf(w, `<IsTruncated>true</IsTruncated><NextContinuationToken>%d</NextContinuationToken>`, end)
+	} else {
+		fmt.Fprint(w, `<IsTruncated>false</IsTruncated>`)
+	}
+	fmt.Fprint(w, `</ListBucketResult>`)
+}
+
+func newListingBackend(t *testing.T, fake *listingS3) *S3Backend {
+	t.Helper()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	client := s3.New(s3.Options{
+		BaseEndpoint: aws.String(srv.URL),
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		UsePathStyle: true,
+	})
+	return NewS3Backend(&mss3.Client{Client: client, BucketName: "test-bucket"}, 1<<30, mss3.MinPartSize)
+}
+
+func TestListJobFilesAgainstS3(t *testing.T) {
+	fake := &listingS3{pageSize: 2, objects: map[string]int64{
+		"synthea_output/job-1/fhir/a.json":      10,
+		"synthea_output/job-1/fhir/b.json":      20,
+		"synthea_output/job-1/fhir/c.json":      30,
+		"synthea_output/job-1/synthea.log":      40,
+		"synthea_output/job-10/fhir/other.json": 50,
+	}}
+	backend := newListingBackend(t, fake)
+
+	files, err := ListJobFiles(context.Background(), backend, "job-1")
+	require.NoError(t, err)
+	prefix := JobOutputPrefix("job-1")
+	assert.Equal(t, []string{prefix, prefix}, fake.prefixes, "both pages are listed under the upload prefix")
+
+	require.Len(t, files, 3, "every page is listed, other jobs and the log are not")
+	for i, name := range []string{"a.json", "b.json", "c.json"} {
+		assert.Equal(t, name, files[i].Filename)
+		assert.Equal(t, int64(10*(i+1)), files[i].Size)
+
+		link, err := url.Parse(files[i].URL)
+		require.NoError(t, err)
+		assert.Equal(t, "/test-bucket/"+files[i].S3Key, link.Path)
+		assert.NotEmpty(t, link.Query().Get("X-Amz-Signature"), "the URL is presigned")
+	}
+}
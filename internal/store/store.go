@@ -1,6 +1,8 @@
 package store
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"time"
 
@@ -8,6 +10,32 @@ import (
 	"github.com/MediSynth-io/medisynth/internal/models"
 )
 
+// ErrDuplicateEmail is returned by CreateUser when the email is already
+// registered
+var ErrDuplicateEmail = errors.New("email already registered")
+
+// Interface is the persistence the auth package depends on. Store implements
+// it against the database; storetest.Fake keeps everything in memory.
+type Interface interface {
+	CreateUser(email, password string) (*models.User, error)
+	UserExists(email string) (bool, error)
+	GetUserByEmail(email string) (*models.User, error)
+
+	CreateToken(userID string, name, token string, expiresAt *time.Time) (*models.Token, error)
+	GetTokenByValue(token string) (*models.Token, error)
+	DeleteToken(userID string, tokenID string) error
+	DeleteUserTokens(userID string) (int64, error)
+	DeleteTokensByIDs(userID string, ids []string) (int64, error)
+	GetUserTokens(userID string) ([]*models.Token, error)
+
+	CreateSession(userID string, token string, expiresAt time.Time) error
+	ValidateSession(token string) (*models.Session, error)
+	DeleteSession(token string) error
+	CleanupExpiredSessions() error
+}
+
+var _ Interface = (*Store)(nil)
+
 // Store handles all database operations
 type Store struct{}
 
@@ -16,12 +44,22 @@ func New() *Store {
 	return &Store{}
 }
 
-// CreateUser creates a new user
+// CreateUser creates a new user. A duplicate email is reported as
+// ErrDuplicateEmail whichever database rejected it.
 func (s *Store) CreateUser(email, password string) (*models.User, error) {
-	return database.CreateUser(email, password)
+	user, err := database.CreateUser(email, password)
+	if database.IsUniqueViolation(err) {
+		return nil, fmt.Errorf("%w: %w", ErrDuplicateEmail, err)
+	}
+	return user, err
 }
 
-// GetUserByEmail retrieves a user by email
+// UserExists reports whether the email is already registered, ignoring case
+func (s *Store) UserExists(email string) (bool, error) {
+	return database.UserExists(email)
+}
+
+// GetUserByEmail retrieves a user by email, ignoring case
 func (s *Store) GetUserByEmail(email string) (*models.User, error) {
 	return database.GetUserByEmail(email)
 }
@@ -41,6 +79,16 @@ func (s *Store) DeleteToken(userID string, tokenID string) error {
 	return database.DeleteToken(userID, tokenID)
 }
 
+// DeleteUserTokens deletes all of a user's tokens
+func (s *Store) DeleteUserTokens(userID string) (int64, error) {
+	return database.DeleteUserTokens(userID)
+}
+
+// DeleteTokensByIDs deletes the listed tokens owned by the user
+func (s *Store) DeleteTokensByIDs(userID string, ids []string) (int64, error) {
+	return database.DeleteTokensByIDs(userID, ids)
+}
+
 // GetUserTokens retrieves all tokens for a user
 func (s *Store) GetUserTokens(userID string) ([]*models.Token, error) {
 	return database.GetUserTokens(userID)
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/config"
+	"github.com/MediSynth-io/medisynth/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "medisynth-store-test")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create temp dir: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := database.Init(&config.Config{DatabaseType: "sqlite", DatabasePath: filepath.Join(dir, "test.db")}); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to initialize test database: %v\n", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestCreateUserMapsUniqueViolation(t *testing.T) {
+	email := fmt.Sprintf("constraint-%d@example.com", time.Now().UnixNano())
+	s := New()
+
+	_, err := s.CreateUser(email, "hash")
+	require.NoError(t, err)
+
+	_, err = s.CreateUser(email, "hash")
+	assert.True(t, errors.Is(err, ErrDuplicateEmail))
+	assert.True(t, database.IsUniqueViolation(err), "the driver error stays wrapped for logging")
+}
@@ -0,0 +1,189 @@
+// Package storetest provides an in-memory store.Interface for tests that
+// exercise auth without a database.
+package storetest
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/models"
+	"github.com/MediSynth-io/medisynth/internal/store"
+)
+
+var _ store.Interface = (*Fake)(nil)
+
+// Fake is an in-memory store.Interface. It mirrors the database's behavior
+// where auth relies on it: unknown records are sql.ErrNoRows, duplicate emails
+// are store.ErrDuplicateEmail, emails are looked up ignoring case and expired
+// sessions are rejected. It is safe for concurrent use.
+type Fake struct {
+	mu       sync.Mutex
+	nextID   int
+	users    map[string]*models.User // by lowercased email
+	tokens   map[string]*models.Token
+	sessions map[string]*models.Session // by token
+}
+
+// NewFake returns an empty Fake
+func NewFake() *Fake {
+	return &Fake{
+		users:    map[string]*models.User{},
+		tokens:   map[string]*models.Token{},
+		sessions: map[string]*models.Session{},
+	}
+}
+
+func (f *Fake) newID() string {
+	f.nextID++
+	return fmt.Sprintf("fake-%d", f.nextID)
+}
+
+func (f *Fake) CreateUser(email, password string) (*models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.users[strings.ToLower(email)]; ok {
+		return nil, store.ErrDuplicateEmail
+	}
+	now := time.Now()
+	user := &models.User{ID: f.newID(), Email: email, Password: password, CreatedAt: now, UpdatedAt: now}
+	f.users[strings.ToLower(email)] = user
+	copied := *user
+	return &copied, nil
+}
+
+func (f *Fake) UserExists(email string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.users[strings.ToLower(email)]
+	return ok, nil
+}
+
+func (f *Fake) GetUserByEmail(email string) (*models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[strings.ToLower(email)]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (f *Fake) CreateToken(userID string, name, token string, expiresAt *time.Time) (*models.Token, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &models.Token{ID: f.newID(), UserID: userID, Token: token, Name: name, CreatedAt: time.Now(), ExpiresAt: expiresAt}
+	f.tokens[t.ID] = t
+	copied := *t
+	return &copied, nil
+}
+
+func (f *Fake) GetTokenByValue(token string) (*models.Token, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, t := range f.tokens {
+		if t.Token == token {
+			copied := *t
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (f *Fake) DeleteToken(userID string, tokenID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, ok := f.tokens[tokenID]
+	if !ok || t.UserID != userID {
+		return sql.ErrNoRows
+	}
+	delete(f.tokens, tokenID)
+	return nil
+}
+
+func (f *Fake) DeleteUserTokens(userID string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var deleted int64
+	for id, t := range f.tokens {
+		if t.UserID == userID {
+			delete(f.tokens, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (f *Fake) DeleteTokensByIDs(userID string, ids []string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var deleted int64
+	for _, id := range ids {
+		if t, ok := f.tokens[id]; ok && t.UserID == userID {
+			delete(f.tokens, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (f *Fake) GetUserTokens(userID string) ([]*models.Token, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var tokens []*models.Token
+	for _, t := range f.tokens {
+		if t.UserID == userID {
+			copied := *t
+			tokens = append(tokens, &copied)
+		}
+	}
+	return tokens, nil
+}
+
+func (f *Fake) CreateSession(userID string, token string, expiresAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.sessions[token]; ok {
+		return errors.New("session token already exists")
+	}
+	f.sessions[token] = &models.Session{ID: f.newID(), UserID: userID, Token: token, CreatedAt: time.Now(), ExpiresAt: expiresAt}
+	return nil
+}
+
+func (f *Fake) ValidateSession(token string) (*models.Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	session, ok := f.sessions[token]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	if session.ExpiresAt.Before(time.Now()) {
+		delete(f.sessions, token)
+		return nil, errors.New("session expired")
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (f *Fake) DeleteSession(token string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.sessions, token)
+	return nil
+}
+
+func (f *Fake) CleanupExpiredSessions() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	for token, session := range f.sessions {
+		if session.ExpiresAt.Before(now) {
+			delete(f.sessions, token)
+		}
+	}
+	return nil
+}
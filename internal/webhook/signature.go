@@ -0,0 +1,91 @@
+// Package webhook signs and verifies webhook payloads with HMAC-SHA256.
+//
+// A signature header has the form "t=<unix timestamp>,v1=<hex digest>", where
+// the digest is computed over "<timestamp>.<payload>". Binding the timestamp
+// into the digest lets receivers reject replays outside a tolerance window.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader is the HTTP header that carries the webhook signature
+const SignatureHeader = "X-MediSynth-Signature"
+
+// DefaultTolerance is how far a signature timestamp may drift from the receiver's clock
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	ErrInvalidHeader             = errors.New("webhook: malformed signature header")
+	ErrNoValidSignature          = errors.New("webhook: no valid signature found")
+	ErrTimestampOutsideTolerance = errors.New("webhook: timestamp outside tolerance window")
+)
+
+// computeSignature returns the hex-encoded HMAC-SHA256 of "<timestamp>.<payload>"
+func computeSignature(secret []byte, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns the signature header value for payload at the given time
+func Sign(secret []byte, payload []byte, now time.Time) string {
+	ts := now.Unix()
+	return fmt.Sprintf("t=%d,v1=%s", ts, computeSignature(secret, ts, payload))
+}
+
+// Verify checks that header is a valid signature of payload made within
+// tolerance of now. Several v1 entries may be present (e.g. during secret
+// rotation); any one matching is sufficient.
+func Verify(secret []byte, payload []byte, header string, tolerance time.Duration, now time.Time) error {
+	var timestamp int64
+	var haveTimestamp bool
+	var signatures []string
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrInvalidHeader
+		}
+		switch key {
+		case "t":
+			ts, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return ErrInvalidHeader
+			}
+			timestamp = ts
+			haveTimestamp = true
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	if !haveTimestamp || len(signatures) == 0 {
+		return ErrInvalidHeader
+	}
+
+	drift := now.Sub(time.Unix(timestamp, 0))
+	if drift < 0 {
+		drift = -drift
+	}
+	if drift > tolerance {
+		return ErrTimestampOutsideTolerance
+	}
+
+	expected := []byte(computeSignature(secret, timestamp, payload))
+	for _, sig := range signatures {
+		if hmac.Equal(expected, []byte(sig)) {
+			return nil
+		}
+	}
+	return ErrNoValidSignature
+}
@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testSecret    = []byte("whsec_test")
+	testPayload   = []byte(`{"event":"order.confirmed"}`)
+	testTimestamp = time.Unix(1700000000, 0)
+	// HMAC-SHA256("whsec_test", `1700000000.{"event":"order.confirmed"}`)
+	testHeader = "t=1700000000,v1=fabc9c3fe908b7d57177274af2b0675405fbc98c1ca1aba6f72affb7027ab60e"
+)
+
+func TestSignKnownVector(t *testing.T) {
+	assert.Equal(t, testHeader, Sign(testSecret, testPayload, testTimestamp))
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  []byte
+		payload []byte
+		header  string
+		now     time.Time
+		wantErr error
+	}{
+		{"valid", testSecret, testPayload, testHeader, testTimestamp, nil},
+		{"within tolerance", testSecret, testPayload, testHeader, testTimestamp.Add(4 * time.Minute), nil},
+		{"rotated secret", testSecret, testPayload, "t=1700000000,v1=deadbeef,v1=fabc9c3fe908b7d57177274af2b0675405fbc98c1ca1aba6f72affb7027ab60e", testTimestamp, nil},
+		{"wrong secret", []byte("other"), testPayload, testHeader, testTimestamp, ErrNoValidSignature},
+		{"tampered payload", testSecret, []byte(`{"event":"order.cancelled"}`), testHeader, testTimestamp, ErrNoValidSignature},
+		{"replayed", testSecret, testPayload, testHeader, testTimestamp.Add(10 * time.Minute), ErrTimestampOutsideTolerance},
+		{"from the future", testSecret, testPayload, testHeader, testTimestamp.Add(-10 * time.Minute), ErrTimestampOutsideTolerance},
+		{"missing timestamp", testSecret, testPayload, "v1=fabc9c3fe908b7d57177274af2b0675405fbc98c1ca1aba6f72affb7027ab60e", testTimestamp, ErrInvalidHeader},
+		{"missing signature", testSecret, testPayload, "t=1700000000", testTimestamp, ErrInvalidHeader},
+		{"garbage", testSecret, testPayload, "not a signature", testTimestamp, ErrInvalidHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.payload, tt.header, DefaultTolerance, tt.now)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestSignThenVerify(t *testing.T) {
+	now := time.Now()
+	header := Sign(testSecret, testPayload, now)
+	assert.NoError(t, Verify(testSecret, testPayload, header, DefaultTolerance, now))
+}
@@ -0,0 +1,331 @@
+// Package medisynth is a Go client for the MediSynth API. It authenticates
+// with an API token created in the portal or through Client.CreateToken.
+//
+//	client := medisynth.New("https://api.medisynth.io", token)
+//	job, err := client.GeneratePatients(ctx, medisynth.SyntheaParams{Population: &population})
+package medisynth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/internal/models"
+)
+
+// The API's own types, so values decode exactly as the server encodes them
+type (
+	Job           = models.Job
+	JobStatus     = models.JobStatus
+	JobFile       = models.JobFile
+	SyntheaParams = models.SyntheaParams
+	Token         = models.Token
+)
+
+// Job statuses reported by the API
+const (
+	JobStatusPending   = models.JobStatusPending
+	JobStatusRunning   = models.JobStatusRunning
+	JobStatusCompleted = models.JobStatusCompleted
+	JobStatusFailed    = models.JobStatusFailed
+)
+
+// DefaultTimeout bounds each HTTP request made by a Client created without
+// WithHTTPClient
+const DefaultTimeout = 30 * time.Second
+
+// maxErrorBody caps how much of an error response is read when decoding it
+const maxErrorBody = 64 << 10
+
+// Error is returned when the API answers with a 4xx or 5xx status. Code,
+// Message and Fields come from the API's JSON error body when it has one.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Fields     map[string]string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("medisynth: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("medisynth: status %d: %s", e.StatusCode, e.Message)
+}
+
+// Client calls the MediSynth API with a bearer token. It is safe for
+// concurrent use.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+
+	maxRetries int
+	backoff    time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient replaces the default http.Client
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithRetry retries a request up to maxRetries more times when the API is
+// rate limiting or temporarily unavailable, waiting backoff before the first
+// retry and doubling it each time. GET and DELETE requests are also retried
+// after network errors and gateway failures; job creation is only retried when
+// the API has said it did not start the job.
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// New returns a Client for the API at baseURL authenticated with token
+func New(baseURL, token string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// GeneratePatients starts a generation job and returns it as first stored
+func (c *Client) GeneratePatients(ctx context.Context, params SyntheaParams) (*Job, error) {
+	var accepted struct {
+		JobID string `json:"jobID"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/generate-patients", params, &accepted); err != nil {
+		return nil, err
+	}
+	return c.GetJobStatus(ctx, accepted.JobID)
+}
+
+// GetJobStatus returns the job with the given ID
+func (c *Client) GetJobStatus(ctx context.Context, jobID string) (*Job, error) {
+	return c.jobStatus(ctx, jobID, 0)
+}
+
+// WaitForJob blocks until the job completes or fails, or ctx is done. It
+// long-polls the API, so waiting costs one request per waitPoll.
+func (c *Client) WaitForJob(ctx context.Context, jobID string) (*Job, error) {
+	for {
+		job, err := c.jobStatus(ctx, jobID, waitPoll)
+		if err != nil {
+			return nil, err
+		}
+		if job.Status.IsTerminal() {
+			return job, nil
+		}
+	}
+}
+
+// waitPoll is how long each WaitForJob request asks the API to hold the
+// connection, kept under DefaultTimeout
+const waitPoll = 25 * time.Second
+
+func (c *Client) jobStatus(ctx context.Context, jobID string, wait time.Duration) (*Job, error) {
+	path := "/generation-status/" + url.PathEscape(jobID)
+	if wait > 0 {
+		path += "?wait=" + wait.String()
+	}
+	var job Job
+	if err := c.do(ctx, http.MethodGet, path, nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs returns the token owner's jobs, newest first
+func (c *Client) ListJobs(ctx context.Context) ([]Job, error) {
+	var jobs []Job
+	if err := c.do(ctx, http.MethodGet, "/jobs", nil, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// ListJobFiles returns the output files uploaded so far for a job, each with
+// a presigned download URL
+func (c *Client) ListJobFiles(ctx context.Context, jobID string) ([]JobFile, error) {
+	var files []JobFile
+	if err := c.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(jobID)+"/files", nil, &files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// DeleteJob deletes a completed or failed job together with its output
+// files. Deleting a pending or running job fails with a 409 *Error.
+func (c *Client) DeleteJob(ctx context.Context, jobID string) error {
+	return c.do(ctx, http.MethodDelete, "/jobs/"+url.PathEscape(jobID), nil, nil)
+}
+
+// CreateToken creates an API token. Its value is only returned here.
+func (c *Client) CreateToken(ctx context.Context, name string) (*Token, error) {
+	var token Token
+	in := map[string]string{"name": name}
+	if err := c.do(ctx, http.MethodPost, "/tokens", in, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ListTokens returns the token owner's API tokens
+func (c *Client) ListTokens(ctx context.Context) ([]Token, error) {
+	var tokens []Token
+	if err := c.do(ctx, http.MethodGet, "/tokens", nil, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// DeleteToken revokes an API token
+func (c *Client) DeleteToken(ctx context.Context, tokenID string) error {
+	return c.do(ctx, http.MethodDelete, "/tokens/"+url.PathEscape(tokenID), nil, nil)
+}
+
+// DeleteTokens revokes the listed API tokens and returns how many were
+// deleted. IDs of tokens the caller does not own are skipped.
+func (c *Client) DeleteTokens(ctx context.Context, tokenIDs []string) (int, error) {
+	var result struct {
+		Deleted int `json:"deleted"`
+	}
+	in := map[string][]string{"ids": tokenIDs}
+	if err := c.do(ctx, http.MethodPost, "/tokens/bulk-delete", in, &result); err != nil {
+		return 0, err
+	}
+	return result.Deleted, nil
+}
+
+// DeleteAllTokens revokes every API token of the caller, including the one
+// the Client uses, and returns how many were deleted
+func (c *Client) DeleteAllTokens(ctx context.Context) (int, error) {
+	var result struct {
+		Deleted int `json:"deleted"`
+	}
+	if err := c.do(ctx, http.MethodDelete, "/tokens", nil, &result); err != nil {
+		return 0, err
+	}
+	return result.Deleted, nil
+}
+
+// do sends a JSON request, retrying as configured. A non-nil in is encoded as
+// the request body and a non-nil out receives the decoded response.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var payload []byte
+	if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("medisynth: encode request: %w", err)
+		}
+	}
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, path, payload, out)
+		if err == nil || attempt >= c.maxRetries || !retryable(method, err) {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload []byte, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("medisynth: build %s %s: %w", method, path, err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("medisynth: %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return parseError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("medisynth: decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+// retryable reports whether a failed request may safely be sent again
+func retryable(method string, err error) bool {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		// A network error may have hit after the server acted on a POST
+		return method != http.MethodPost && !isContextError(err)
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		// Rejected before any work was done
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return method != http.MethodPost
+	}
+	return false
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// parseError builds an *Error from an error response, falling back to the
+// status text when the body is not the API's JSON error shape
+func parseError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode}
+
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	var body struct {
+		Error struct {
+			Code    string            `json:"code"`
+			Message string            `json:"message"`
+			Fields  map[string]string `json:"fields"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(raw, &body) == nil && body.Error.Message != "" {
+		apiErr.Code = body.Error.Code
+		apiErr.Message = body.Error.Message
+		apiErr.Fields = body.Error.Fields
+		return apiErr
+	}
+	apiErr.Message = http.StatusText(resp.StatusCode)
+	return apiErr
+}
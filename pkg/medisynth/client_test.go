@@ -0,0 +1,235 @@
+package medisynth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "test-token"
+
+// newTestServer serves handler behind a check for the test bearer token
+func newTestServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer "+testToken, r.Header.Get("Authorization"))
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func writeJSON(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	io.WriteString(w, body)
+}
+
+func TestGeneratePatients(t *testing.T) {
+	srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /generate-patients":
+			var params SyntheaParams
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+			if assert.NotNil(t, params.Population) {
+				assert.Equal(t, 10, *params.Population)
+			}
+			writeJSON(w, http.StatusAccepted, `{"jobID":"job-1","status":"pending","statusUrl":"/generation-status/job-1"}`)
+		case "GET /generation-status/job-1":
+			writeJSON(w, http.StatusOK, `{"id":"job-1","status":"pending","output_format":"fhir","queue_depth":0}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+
+	population := 10
+	job, err := New(srv.URL, testToken).GeneratePatients(context.Background(), SyntheaParams{Population: &population})
+	require.NoError(t, err)
+	assert.Equal(t, "job-1", job.ID)
+	assert.Equal(t, JobStatusPending, job.Status)
+	assert.Equal(t, "fhir", job.OutputFormat)
+}
+
+func TestListJobsAndFiles(t *testing.T) {
+	srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/jobs":
+			writeJSON(w, http.StatusOK, `[{"id":"job-2","status":"completed"},{"id":"job-1","status":"failed"}]`)
+		case "/jobs/job-2/files":
+			writeJSON(w, http.StatusOK, `[{"filename":"fhir/a.json","size":42,"url":"https://s3/a"}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	client := New(srv.URL+"/", testToken)
+
+	jobs, err := client.ListJobs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "job-2", jobs[0].ID)
+	assert.Equal(t, JobStatusFailed, jobs[1].Status)
+
+	files, err := client.ListJobFiles(context.Background(), "job-2")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "fhir/a.json", files[0].Filename)
+	assert.Equal(t, int64(42), files[0].Size)
+	assert.Equal(t, "https://s3/a", files[0].URL)
+}
+
+func TestDeleteJob(t *testing.T) {
+	srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		switch r.URL.Path {
+		case "/jobs/job-1":
+			w.WriteHeader(http.StatusNoContent)
+		case "/jobs/job-2":
+			writeJSON(w, http.StatusConflict, `{"error":{"code":"conflict","message":"Only completed or failed jobs can be deleted"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	client := New(srv.URL, testToken)
+
+	assert.NoError(t, client.DeleteJob(context.Background(), "job-1"))
+
+	err := client.DeleteJob(context.Background(), "job-2")
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+	assert.Equal(t, "conflict", apiErr.Code)
+}
+
+func TestTokens(t *testing.T) {
+	srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /tokens":
+			var body map[string]string
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "ci", body["name"])
+			writeJSON(w, http.StatusCreated, `{"id":"tok-1","name":"ci","token":"secret"}`)
+		case "GET /tokens":
+			writeJSON(w, http.StatusOK, `[{"id":"tok-1","name":"ci"}]`)
+		case "DELETE /tokens/tok-1":
+			w.WriteHeader(http.StatusNoContent)
+		case "POST /tokens/bulk-delete":
+			var body map[string][]string
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, []string{"tok-2", "tok-3"}, body["ids"])
+			writeJSON(w, http.StatusOK, `{"deleted":2}`)
+		case "DELETE /tokens":
+			writeJSON(w, http.StatusOK, `{"deleted":1}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+	client := New(srv.URL, testToken)
+	ctx := context.Background()
+
+	token, err := client.CreateToken(ctx, "ci")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", token.Token)
+
+	tokens, err := client.ListTokens(ctx)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.Equal(t, "tok-1", tokens[0].ID)
+
+	assert.NoError(t, client.DeleteToken(ctx, "tok-1"))
+
+	deleted, err := client.DeleteTokens(ctx, []string{"tok-2", "tok-3"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	deleted, err = client.DeleteAllTokens(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+}
+
+func TestAPIErrors(t *testing.T) {
+	srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusBadRequest, `{"error":{"code":"validation_failed","message":"population: must be at most 100","fields":{"population":"must be at most 100"}}}`)
+	})
+
+	_, err := New(srv.URL, testToken).GeneratePatients(context.Background(), SyntheaParams{})
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, "validation_failed", apiErr.Code)
+	assert.Equal(t, map[string]string{"population": "must be at most 100"}, apiErr.Fields)
+}
+
+func TestRetry(t *testing.T) {
+	var calls int32
+	srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			writeJSON(w, http.StatusServiceUnavailable, `{"error":{"code":"unavailable","message":"Job queue is full"}}`)
+			return
+		}
+		writeJSON(w, http.StatusOK, `[]`)
+	})
+
+	jobs, err := New(srv.URL, testToken, WithRetry(2, time.Millisecond)).ListJobs(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&calls, 0)
+	_, err = New(srv.URL, testToken, WithRetry(1, time.Millisecond)).ListJobs(context.Background())
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr), "the last error is returned once retries run out")
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestRetrySkipsUnsafeRequests(t *testing.T) {
+	var calls int32
+	srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	_, err := New(srv.URL, testToken, WithRetry(3, time.Millisecond)).CreateToken(context.Background(), "ci")
+	require.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "a POST that may have reached the API is not repeated")
+}
+
+func TestRetryStopsWhenContextIsDone(t *testing.T) {
+	srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := New(srv.URL, testToken, WithRetry(5, time.Hour)).ListJobs(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestWaitForJob(t *testing.T) {
+	var calls int32
+	srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/generation-status/job-1", r.URL.Path)
+		assert.Equal(t, waitPoll.String(), r.URL.Query().Get("wait"))
+		if atomic.AddInt32(&calls, 1) < 2 {
+			writeJSON(w, http.StatusOK, `{"id":"job-1","status":"running"}`)
+			return
+		}
+		writeJSON(w, http.StatusOK, `{"id":"job-1","status":"completed"}`)
+	})
+
+	job, err := New(srv.URL, testToken).WaitForJob(context.Background(), "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusCompleted, job.Status)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,38 @@
+package medisynth_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/MediSynth-io/medisynth/pkg/medisynth"
+)
+
+func Example() {
+	client := medisynth.New("https://api.medisynth.io", os.Getenv("MEDISYNTH_TOKEN"),
+		medisynth.WithRetry(3, time.Second))
+	ctx := context.Background()
+
+	population := 100
+	job, err := client.GeneratePatients(ctx, medisynth.SyntheaParams{Population: &population})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if job, err = client.WaitForJob(ctx, job.ID); err != nil {
+		log.Fatal(err)
+	}
+	if job.Status == medisynth.JobStatusFailed {
+		log.Fatalf("job %s failed", job.ID)
+	}
+
+	files, err := client.ListJobFiles(ctx, job.ID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, file := range files {
+		fmt.Println(file.Filename, file.URL)
+	}
+}